@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/patrickod/baywheels-exporter/pkg/gbfs"
+)
+
+// / API serves the merged feed state of the most recent sampling cycle as
+// / JSON under /api/v1/, and, if History is set, the recorded availability of
+// / stations at /api/v1/history.
+type API struct {
+	History *SQLiteHistory
+
+	exporter *Exporter
+}
+
+func NewAPI(exporter *Exporter) *API {
+	return &API{exporter: exporter}
+}
+
+func (a *API) Register(mux *http.ServeMux, metrics *HTTPMetrics) {
+	mux.Handle("GET /api/v1/stations", metrics.Instrument("api_stations", http.HandlerFunc(a.stations)))
+	mux.Handle("GET /api/v1/stations/{id}", metrics.Instrument("api_station", http.HandlerFunc(a.station)))
+	mux.Handle("GET /api/v1/stations.geojson", metrics.Instrument("api_stations_geojson", http.HandlerFunc(a.stationsGeoJSON)))
+	mux.Handle("GET /api/v1/bikes", metrics.Instrument("api_bikes", http.HandlerFunc(a.bikes)))
+	mux.Handle("GET /api/v1/events", metrics.Instrument("api_events", http.HandlerFunc(a.events)))
+	if a.History != nil {
+		mux.Handle("GET /api/v1/history", metrics.Instrument("api_history", http.HandlerFunc(a.history)))
+	}
+}
+
+// / Return the current snapshot, or write a 503 and return nil if the first
+// / sampling cycle hasn't completed yet.
+func (a *API) snapshot(w http.ResponseWriter) *Snapshot {
+	snapshot := a.exporter.Snapshot()
+	if snapshot == nil {
+		writeError(w, http.StatusServiceUnavailable, "no data sampled yet")
+	}
+	return snapshot
+}
+
+func (a *API) stations(w http.ResponseWriter, r *http.Request) {
+	snapshot := a.snapshot(w)
+	if snapshot == nil {
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		LastUpdated time.Time `json:"last_updated"`
+		Stations    []Station `json:"stations"`
+	}{snapshot.Time, snapshot.Stations})
+}
+
+func (a *API) station(w http.ResponseWriter, r *http.Request) {
+	snapshot := a.snapshot(w)
+	if snapshot == nil {
+		return
+	}
+	station, ok := snapshot.Station(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown station")
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		LastUpdated time.Time `json:"last_updated"`
+		Station
+	}{snapshot.Time, station})
+}
+
+func (a *API) bikes(w http.ResponseWriter, r *http.Request) {
+	snapshot := a.snapshot(w)
+	if snapshot == nil {
+		return
+	}
+	bikes := snapshot.Bikes
+	if bikes == nil {
+		bikes = []gbfs.BikeStatus{}
+	}
+	writeJSON(w, http.StatusOK, struct {
+		LastUpdated time.Time         `json:"last_updated"`
+		Bikes       []gbfs.BikeStatus `json:"bikes"`
+	}{snapshot.Time, bikes})
+}
+
+// / Serve the buffered events after since, an RFC 3339 or unix time or a
+// / duration ago such as 1h, optionally only those of a type or station.
+func (a *API) events(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var since time.Time
+	if s := query.Get("since"); s != "" {
+		if ago, err := time.ParseDuration(s); err == nil {
+			since = time.Now().Add(-ago)
+		} else if since, err = parseHistoryTime(s, time.Time{}); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid since: %s", err))
+			return
+		}
+	}
+
+	events := []Event{}
+	for _, event := range a.exporter.Events.Since(since) {
+		if t := query.Get("type"); t != "" && event.Type != t {
+			continue
+		}
+		if id := query.Get("station_id"); id != "" && event.StationId != id {
+			continue
+		}
+		events = append(events, event)
+	}
+	writeJSON(w, http.StatusOK, struct {
+		Since  time.Time `json:"since"`
+		Events []Event   `json:"events"`
+	}{since.UTC(), events})
+}
+
+// / historyRange is the time range returned when from isn't given.
+const historyRange = 24 * time.Hour
+
+func (a *API) history(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	stationID := query.Get("station_id")
+	if stationID == "" {
+		writeError(w, http.StatusBadRequest, "missing station_id")
+		return
+	}
+	to, err := parseHistoryTime(query.Get("to"), time.Now())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid to: %s", err))
+		return
+	}
+	from, err := parseHistoryTime(query.Get("from"), to.Add(-historyRange))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid from: %s", err))
+		return
+	}
+
+	points, err := a.History.Query(r.Context(), stationID, from, to)
+	if err != nil {
+		log.Printf("Error querying history %s\n", err)
+		writeError(w, http.StatusInternalServerError, "querying history failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		StationId string         `json:"station_id"`
+		From      time.Time      `json:"from"`
+		To        time.Time      `json:"to"`
+		Points    []HistoryPoint `json:"points"`
+	}{stationID, from.UTC(), to.UTC(), points})
+}
+
+// / Parse an RFC 3339 time or unix timestamp, or return def if s is empty.
+func parseHistoryTime(s string, def time.Time) (time.Time, error) {
+	if s == "" {
+		return def, nil
+	}
+	if unix, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(unix, 0), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding JSON response %s\n", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, struct {
+		Error string `json:"error"`
+	}{msg})
+}
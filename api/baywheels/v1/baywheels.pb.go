@@ -0,0 +1,644 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: api/baywheels/v1/baywheels.proto
+
+package baywheelsv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Station struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	StationId   string                 `protobuf:"bytes,1,opt,name=station_id,json=stationId,proto3" json:"station_id,omitempty"`
+	Name        string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	ShortName   string                 `protobuf:"bytes,3,opt,name=short_name,json=shortName,proto3" json:"short_name,omitempty"`
+	ExternalId  string                 `protobuf:"bytes,4,opt,name=external_id,json=externalId,proto3" json:"external_id,omitempty"`
+	StationType string                 `protobuf:"bytes,5,opt,name=station_type,json=stationType,proto3" json:"station_type,omitempty"`
+	Lat         float64                `protobuf:"fixed64,6,opt,name=lat,proto3" json:"lat,omitempty"`
+	Lon         float64                `protobuf:"fixed64,7,opt,name=lon,proto3" json:"lon,omitempty"`
+	Capacity    int32                  `protobuf:"varint,8,opt,name=capacity,proto3" json:"capacity,omitempty"`
+	HasKiosk    bool                   `protobuf:"varint,9,opt,name=has_kiosk,json=hasKiosk,proto3" json:"has_kiosk,omitempty"`
+	// Unset for stations missing from the station_status feed.
+	Status        *StationStatus `protobuf:"bytes,10,opt,name=status,proto3" json:"status,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Station) Reset() {
+	*x = Station{}
+	mi := &file_api_baywheels_v1_baywheels_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Station) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Station) ProtoMessage() {}
+
+func (x *Station) ProtoReflect() protoreflect.Message {
+	mi := &file_api_baywheels_v1_baywheels_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Station.ProtoReflect.Descriptor instead.
+func (*Station) Descriptor() ([]byte, []int) {
+	return file_api_baywheels_v1_baywheels_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Station) GetStationId() string {
+	if x != nil {
+		return x.StationId
+	}
+	return ""
+}
+
+func (x *Station) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Station) GetShortName() string {
+	if x != nil {
+		return x.ShortName
+	}
+	return ""
+}
+
+func (x *Station) GetExternalId() string {
+	if x != nil {
+		return x.ExternalId
+	}
+	return ""
+}
+
+func (x *Station) GetStationType() string {
+	if x != nil {
+		return x.StationType
+	}
+	return ""
+}
+
+func (x *Station) GetLat() float64 {
+	if x != nil {
+		return x.Lat
+	}
+	return 0
+}
+
+func (x *Station) GetLon() float64 {
+	if x != nil {
+		return x.Lon
+	}
+	return 0
+}
+
+func (x *Station) GetCapacity() int32 {
+	if x != nil {
+		return x.Capacity
+	}
+	return 0
+}
+
+func (x *Station) GetHasKiosk() bool {
+	if x != nil {
+		return x.HasKiosk
+	}
+	return false
+}
+
+func (x *Station) GetStatus() *StationStatus {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+type StationStatus struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	IsInstalled         bool                   `protobuf:"varint,1,opt,name=is_installed,json=isInstalled,proto3" json:"is_installed,omitempty"`
+	IsRenting           bool                   `protobuf:"varint,2,opt,name=is_renting,json=isRenting,proto3" json:"is_renting,omitempty"`
+	IsReturning         bool                   `protobuf:"varint,3,opt,name=is_returning,json=isReturning,proto3" json:"is_returning,omitempty"`
+	LastReported        *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=last_reported,json=lastReported,proto3" json:"last_reported,omitempty"`
+	BikesAvailable      int32                  `protobuf:"varint,5,opt,name=bikes_available,json=bikesAvailable,proto3" json:"bikes_available,omitempty"`
+	BikesDisabled       int32                  `protobuf:"varint,6,opt,name=bikes_disabled,json=bikesDisabled,proto3" json:"bikes_disabled,omitempty"`
+	DocksAvailable      int32                  `protobuf:"varint,7,opt,name=docks_available,json=docksAvailable,proto3" json:"docks_available,omitempty"`
+	DocksDisabled       int32                  `protobuf:"varint,8,opt,name=docks_disabled,json=docksDisabled,proto3" json:"docks_disabled,omitempty"`
+	EbikesAvailable     int32                  `protobuf:"varint,9,opt,name=ebikes_available,json=ebikesAvailable,proto3" json:"ebikes_available,omitempty"`
+	ScootersAvailable   int32                  `protobuf:"varint,10,opt,name=scooters_available,json=scootersAvailable,proto3" json:"scooters_available,omitempty"`
+	ScootersUnavailable int32                  `protobuf:"varint,11,opt,name=scooters_unavailable,json=scootersUnavailable,proto3" json:"scooters_unavailable,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *StationStatus) Reset() {
+	*x = StationStatus{}
+	mi := &file_api_baywheels_v1_baywheels_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StationStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StationStatus) ProtoMessage() {}
+
+func (x *StationStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_api_baywheels_v1_baywheels_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StationStatus.ProtoReflect.Descriptor instead.
+func (*StationStatus) Descriptor() ([]byte, []int) {
+	return file_api_baywheels_v1_baywheels_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *StationStatus) GetIsInstalled() bool {
+	if x != nil {
+		return x.IsInstalled
+	}
+	return false
+}
+
+func (x *StationStatus) GetIsRenting() bool {
+	if x != nil {
+		return x.IsRenting
+	}
+	return false
+}
+
+func (x *StationStatus) GetIsReturning() bool {
+	if x != nil {
+		return x.IsReturning
+	}
+	return false
+}
+
+func (x *StationStatus) GetLastReported() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastReported
+	}
+	return nil
+}
+
+func (x *StationStatus) GetBikesAvailable() int32 {
+	if x != nil {
+		return x.BikesAvailable
+	}
+	return 0
+}
+
+func (x *StationStatus) GetBikesDisabled() int32 {
+	if x != nil {
+		return x.BikesDisabled
+	}
+	return 0
+}
+
+func (x *StationStatus) GetDocksAvailable() int32 {
+	if x != nil {
+		return x.DocksAvailable
+	}
+	return 0
+}
+
+func (x *StationStatus) GetDocksDisabled() int32 {
+	if x != nil {
+		return x.DocksDisabled
+	}
+	return 0
+}
+
+func (x *StationStatus) GetEbikesAvailable() int32 {
+	if x != nil {
+		return x.EbikesAvailable
+	}
+	return 0
+}
+
+func (x *StationStatus) GetScootersAvailable() int32 {
+	if x != nil {
+		return x.ScootersAvailable
+	}
+	return 0
+}
+
+func (x *StationStatus) GetScootersUnavailable() int32 {
+	if x != nil {
+		return x.ScootersUnavailable
+	}
+	return 0
+}
+
+type ListStationsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListStationsRequest) Reset() {
+	*x = ListStationsRequest{}
+	mi := &file_api_baywheels_v1_baywheels_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListStationsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListStationsRequest) ProtoMessage() {}
+
+func (x *ListStationsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_baywheels_v1_baywheels_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListStationsRequest.ProtoReflect.Descriptor instead.
+func (*ListStationsRequest) Descriptor() ([]byte, []int) {
+	return file_api_baywheels_v1_baywheels_proto_rawDescGZIP(), []int{2}
+}
+
+type ListStationsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	LastUpdated   *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=last_updated,json=lastUpdated,proto3" json:"last_updated,omitempty"`
+	Stations      []*Station             `protobuf:"bytes,2,rep,name=stations,proto3" json:"stations,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListStationsResponse) Reset() {
+	*x = ListStationsResponse{}
+	mi := &file_api_baywheels_v1_baywheels_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListStationsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListStationsResponse) ProtoMessage() {}
+
+func (x *ListStationsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_baywheels_v1_baywheels_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListStationsResponse.ProtoReflect.Descriptor instead.
+func (*ListStationsResponse) Descriptor() ([]byte, []int) {
+	return file_api_baywheels_v1_baywheels_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ListStationsResponse) GetLastUpdated() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastUpdated
+	}
+	return nil
+}
+
+func (x *ListStationsResponse) GetStations() []*Station {
+	if x != nil {
+		return x.Stations
+	}
+	return nil
+}
+
+type GetStationRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	StationId     string                 `protobuf:"bytes,1,opt,name=station_id,json=stationId,proto3" json:"station_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStationRequest) Reset() {
+	*x = GetStationRequest{}
+	mi := &file_api_baywheels_v1_baywheels_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStationRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStationRequest) ProtoMessage() {}
+
+func (x *GetStationRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_baywheels_v1_baywheels_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStationRequest.ProtoReflect.Descriptor instead.
+func (*GetStationRequest) Descriptor() ([]byte, []int) {
+	return file_api_baywheels_v1_baywheels_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetStationRequest) GetStationId() string {
+	if x != nil {
+		return x.StationId
+	}
+	return ""
+}
+
+type WatchStationsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only stream changes for these stations; all stations if empty.
+	StationIds    []string `protobuf:"bytes,1,rep,name=station_ids,json=stationIds,proto3" json:"station_ids,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchStationsRequest) Reset() {
+	*x = WatchStationsRequest{}
+	mi := &file_api_baywheels_v1_baywheels_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchStationsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchStationsRequest) ProtoMessage() {}
+
+func (x *WatchStationsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_baywheels_v1_baywheels_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchStationsRequest.ProtoReflect.Descriptor instead.
+func (*WatchStationsRequest) Descriptor() ([]byte, []int) {
+	return file_api_baywheels_v1_baywheels_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *WatchStationsRequest) GetStationIds() []string {
+	if x != nil {
+		return x.StationIds
+	}
+	return nil
+}
+
+type StationChange struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Time      *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=time,proto3" json:"time,omitempty"`
+	StationId string                 `protobuf:"bytes,2,opt,name=station_id,json=stationId,proto3" json:"station_id,omitempty"`
+	Name      string                 `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	// Unset when the station has just appeared in station_status.
+	Previous *StationStatus `protobuf:"bytes,4,opt,name=previous,proto3" json:"previous,omitempty"`
+	// Unset when the station has disappeared from station_status.
+	Current       *StationStatus `protobuf:"bytes,5,opt,name=current,proto3" json:"current,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StationChange) Reset() {
+	*x = StationChange{}
+	mi := &file_api_baywheels_v1_baywheels_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StationChange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StationChange) ProtoMessage() {}
+
+func (x *StationChange) ProtoReflect() protoreflect.Message {
+	mi := &file_api_baywheels_v1_baywheels_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StationChange.ProtoReflect.Descriptor instead.
+func (*StationChange) Descriptor() ([]byte, []int) {
+	return file_api_baywheels_v1_baywheels_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *StationChange) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *StationChange) GetStationId() string {
+	if x != nil {
+		return x.StationId
+	}
+	return ""
+}
+
+func (x *StationChange) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *StationChange) GetPrevious() *StationStatus {
+	if x != nil {
+		return x.Previous
+	}
+	return nil
+}
+
+func (x *StationChange) GetCurrent() *StationStatus {
+	if x != nil {
+		return x.Current
+	}
+	return nil
+}
+
+var File_api_baywheels_v1_baywheels_proto protoreflect.FileDescriptor
+
+const file_api_baywheels_v1_baywheels_proto_rawDesc = "" +
+	"\n" +
+	" api/baywheels/v1/baywheels.proto\x12\fbaywheels.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xb1\x02\n" +
+	"\aStation\x12\x1d\n" +
+	"\n" +
+	"station_id\x18\x01 \x01(\tR\tstationId\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x1d\n" +
+	"\n" +
+	"short_name\x18\x03 \x01(\tR\tshortName\x12\x1f\n" +
+	"\vexternal_id\x18\x04 \x01(\tR\n" +
+	"externalId\x12!\n" +
+	"\fstation_type\x18\x05 \x01(\tR\vstationType\x12\x10\n" +
+	"\x03lat\x18\x06 \x01(\x01R\x03lat\x12\x10\n" +
+	"\x03lon\x18\a \x01(\x01R\x03lon\x12\x1a\n" +
+	"\bcapacity\x18\b \x01(\x05R\bcapacity\x12\x1b\n" +
+	"\thas_kiosk\x18\t \x01(\bR\bhasKiosk\x123\n" +
+	"\x06status\x18\n" +
+	" \x01(\v2\x1b.baywheels.v1.StationStatusR\x06status\"\xe2\x03\n" +
+	"\rStationStatus\x12!\n" +
+	"\fis_installed\x18\x01 \x01(\bR\visInstalled\x12\x1d\n" +
+	"\n" +
+	"is_renting\x18\x02 \x01(\bR\tisRenting\x12!\n" +
+	"\fis_returning\x18\x03 \x01(\bR\visReturning\x12?\n" +
+	"\rlast_reported\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\flastReported\x12'\n" +
+	"\x0fbikes_available\x18\x05 \x01(\x05R\x0ebikesAvailable\x12%\n" +
+	"\x0ebikes_disabled\x18\x06 \x01(\x05R\rbikesDisabled\x12'\n" +
+	"\x0fdocks_available\x18\a \x01(\x05R\x0edocksAvailable\x12%\n" +
+	"\x0edocks_disabled\x18\b \x01(\x05R\rdocksDisabled\x12)\n" +
+	"\x10ebikes_available\x18\t \x01(\x05R\x0febikesAvailable\x12-\n" +
+	"\x12scooters_available\x18\n" +
+	" \x01(\x05R\x11scootersAvailable\x121\n" +
+	"\x14scooters_unavailable\x18\v \x01(\x05R\x13scootersUnavailable\"\x15\n" +
+	"\x13ListStationsRequest\"\x88\x01\n" +
+	"\x14ListStationsResponse\x12=\n" +
+	"\flast_updated\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\vlastUpdated\x121\n" +
+	"\bstations\x18\x02 \x03(\v2\x15.baywheels.v1.StationR\bstations\"2\n" +
+	"\x11GetStationRequest\x12\x1d\n" +
+	"\n" +
+	"station_id\x18\x01 \x01(\tR\tstationId\"7\n" +
+	"\x14WatchStationsRequest\x12\x1f\n" +
+	"\vstation_ids\x18\x01 \x03(\tR\n" +
+	"stationIds\"\xe2\x01\n" +
+	"\rStationChange\x12.\n" +
+	"\x04time\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x12\x1d\n" +
+	"\n" +
+	"station_id\x18\x02 \x01(\tR\tstationId\x12\x12\n" +
+	"\x04name\x18\x03 \x01(\tR\x04name\x127\n" +
+	"\bprevious\x18\x04 \x01(\v2\x1b.baywheels.v1.StationStatusR\bprevious\x125\n" +
+	"\acurrent\x18\x05 \x01(\v2\x1b.baywheels.v1.StationStatusR\acurrent2\x81\x02\n" +
+	"\x0eStationService\x12U\n" +
+	"\fListStations\x12!.baywheels.v1.ListStationsRequest\x1a\".baywheels.v1.ListStationsResponse\x12D\n" +
+	"\n" +
+	"GetStation\x12\x1f.baywheels.v1.GetStationRequest\x1a\x15.baywheels.v1.Station\x12R\n" +
+	"\rWatchStations\x12\".baywheels.v1.WatchStationsRequest\x1a\x1b.baywheels.v1.StationChange0\x01BFZDgithub.com/patrickod/baywheels-exporter/api/baywheels/v1;baywheelsv1b\x06proto3"
+
+var (
+	file_api_baywheels_v1_baywheels_proto_rawDescOnce sync.Once
+	file_api_baywheels_v1_baywheels_proto_rawDescData []byte
+)
+
+func file_api_baywheels_v1_baywheels_proto_rawDescGZIP() []byte {
+	file_api_baywheels_v1_baywheels_proto_rawDescOnce.Do(func() {
+		file_api_baywheels_v1_baywheels_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_api_baywheels_v1_baywheels_proto_rawDesc), len(file_api_baywheels_v1_baywheels_proto_rawDesc)))
+	})
+	return file_api_baywheels_v1_baywheels_proto_rawDescData
+}
+
+var file_api_baywheels_v1_baywheels_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+var file_api_baywheels_v1_baywheels_proto_goTypes = []any{
+	(*Station)(nil),               // 0: baywheels.v1.Station
+	(*StationStatus)(nil),         // 1: baywheels.v1.StationStatus
+	(*ListStationsRequest)(nil),   // 2: baywheels.v1.ListStationsRequest
+	(*ListStationsResponse)(nil),  // 3: baywheels.v1.ListStationsResponse
+	(*GetStationRequest)(nil),     // 4: baywheels.v1.GetStationRequest
+	(*WatchStationsRequest)(nil),  // 5: baywheels.v1.WatchStationsRequest
+	(*StationChange)(nil),         // 6: baywheels.v1.StationChange
+	(*timestamppb.Timestamp)(nil), // 7: google.protobuf.Timestamp
+}
+var file_api_baywheels_v1_baywheels_proto_depIdxs = []int32{
+	1,  // 0: baywheels.v1.Station.status:type_name -> baywheels.v1.StationStatus
+	7,  // 1: baywheels.v1.StationStatus.last_reported:type_name -> google.protobuf.Timestamp
+	7,  // 2: baywheels.v1.ListStationsResponse.last_updated:type_name -> google.protobuf.Timestamp
+	0,  // 3: baywheels.v1.ListStationsResponse.stations:type_name -> baywheels.v1.Station
+	7,  // 4: baywheels.v1.StationChange.time:type_name -> google.protobuf.Timestamp
+	1,  // 5: baywheels.v1.StationChange.previous:type_name -> baywheels.v1.StationStatus
+	1,  // 6: baywheels.v1.StationChange.current:type_name -> baywheels.v1.StationStatus
+	2,  // 7: baywheels.v1.StationService.ListStations:input_type -> baywheels.v1.ListStationsRequest
+	4,  // 8: baywheels.v1.StationService.GetStation:input_type -> baywheels.v1.GetStationRequest
+	5,  // 9: baywheels.v1.StationService.WatchStations:input_type -> baywheels.v1.WatchStationsRequest
+	3,  // 10: baywheels.v1.StationService.ListStations:output_type -> baywheels.v1.ListStationsResponse
+	0,  // 11: baywheels.v1.StationService.GetStation:output_type -> baywheels.v1.Station
+	6,  // 12: baywheels.v1.StationService.WatchStations:output_type -> baywheels.v1.StationChange
+	10, // [10:13] is the sub-list for method output_type
+	7,  // [7:10] is the sub-list for method input_type
+	7,  // [7:7] is the sub-list for extension type_name
+	7,  // [7:7] is the sub-list for extension extendee
+	0,  // [0:7] is the sub-list for field type_name
+}
+
+func init() { file_api_baywheels_v1_baywheels_proto_init() }
+func file_api_baywheels_v1_baywheels_proto_init() {
+	if File_api_baywheels_v1_baywheels_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_api_baywheels_v1_baywheels_proto_rawDesc), len(file_api_baywheels_v1_baywheels_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   7,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_api_baywheels_v1_baywheels_proto_goTypes,
+		DependencyIndexes: file_api_baywheels_v1_baywheels_proto_depIdxs,
+		MessageInfos:      file_api_baywheels_v1_baywheels_proto_msgTypes,
+	}.Build()
+	File_api_baywheels_v1_baywheels_proto = out.File
+	file_api_baywheels_v1_baywheels_proto_goTypes = nil
+	file_api_baywheels_v1_baywheels_proto_depIdxs = nil
+}
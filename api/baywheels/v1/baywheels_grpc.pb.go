@@ -0,0 +1,215 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: api/baywheels/v1/baywheels.proto
+
+package baywheelsv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	StationService_ListStations_FullMethodName  = "/baywheels.v1.StationService/ListStations"
+	StationService_GetStation_FullMethodName    = "/baywheels.v1.StationService/GetStation"
+	StationService_WatchStations_FullMethodName = "/baywheels.v1.StationService/WatchStations"
+)
+
+// StationServiceClient is the client API for StationService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// StationService exposes the exporter's most recently sampled view of a GBFS
+// system.
+type StationServiceClient interface {
+	// ListStations returns every known station with its latest status.
+	ListStations(ctx context.Context, in *ListStationsRequest, opts ...grpc.CallOption) (*ListStationsResponse, error)
+	// GetStation returns a single station by station_id.
+	GetStation(ctx context.Context, in *GetStationRequest, opts ...grpc.CallOption) (*Station, error)
+	// WatchStations streams a StationChange whenever a station's availability
+	// differs between two sampling cycles.
+	WatchStations(ctx context.Context, in *WatchStationsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StationChange], error)
+}
+
+type stationServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewStationServiceClient(cc grpc.ClientConnInterface) StationServiceClient {
+	return &stationServiceClient{cc}
+}
+
+func (c *stationServiceClient) ListStations(ctx context.Context, in *ListStationsRequest, opts ...grpc.CallOption) (*ListStationsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListStationsResponse)
+	err := c.cc.Invoke(ctx, StationService_ListStations_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *stationServiceClient) GetStation(ctx context.Context, in *GetStationRequest, opts ...grpc.CallOption) (*Station, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Station)
+	err := c.cc.Invoke(ctx, StationService_GetStation_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *stationServiceClient) WatchStations(ctx context.Context, in *WatchStationsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StationChange], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &StationService_ServiceDesc.Streams[0], StationService_WatchStations_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchStationsRequest, StationChange]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type StationService_WatchStationsClient = grpc.ServerStreamingClient[StationChange]
+
+// StationServiceServer is the server API for StationService service.
+// All implementations must embed UnimplementedStationServiceServer
+// for forward compatibility.
+//
+// StationService exposes the exporter's most recently sampled view of a GBFS
+// system.
+type StationServiceServer interface {
+	// ListStations returns every known station with its latest status.
+	ListStations(context.Context, *ListStationsRequest) (*ListStationsResponse, error)
+	// GetStation returns a single station by station_id.
+	GetStation(context.Context, *GetStationRequest) (*Station, error)
+	// WatchStations streams a StationChange whenever a station's availability
+	// differs between two sampling cycles.
+	WatchStations(*WatchStationsRequest, grpc.ServerStreamingServer[StationChange]) error
+	mustEmbedUnimplementedStationServiceServer()
+}
+
+// UnimplementedStationServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedStationServiceServer struct{}
+
+func (UnimplementedStationServiceServer) ListStations(context.Context, *ListStationsRequest) (*ListStationsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListStations not implemented")
+}
+func (UnimplementedStationServiceServer) GetStation(context.Context, *GetStationRequest) (*Station, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStation not implemented")
+}
+func (UnimplementedStationServiceServer) WatchStations(*WatchStationsRequest, grpc.ServerStreamingServer[StationChange]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchStations not implemented")
+}
+func (UnimplementedStationServiceServer) mustEmbedUnimplementedStationServiceServer() {}
+func (UnimplementedStationServiceServer) testEmbeddedByValue()                        {}
+
+// UnsafeStationServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to StationServiceServer will
+// result in compilation errors.
+type UnsafeStationServiceServer interface {
+	mustEmbedUnimplementedStationServiceServer()
+}
+
+func RegisterStationServiceServer(s grpc.ServiceRegistrar, srv StationServiceServer) {
+	// If the following call pancis, it indicates UnimplementedStationServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&StationService_ServiceDesc, srv)
+}
+
+func _StationService_ListStations_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListStationsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StationServiceServer).ListStations(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StationService_ListStations_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StationServiceServer).ListStations(ctx, req.(*ListStationsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StationService_GetStation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StationServiceServer).GetStation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StationService_GetStation_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StationServiceServer).GetStation(ctx, req.(*GetStationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StationService_WatchStations_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchStationsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(StationServiceServer).WatchStations(m, &grpc.GenericServerStream[WatchStationsRequest, StationChange]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type StationService_WatchStationsServer = grpc.ServerStreamingServer[StationChange]
+
+// StationService_ServiceDesc is the grpc.ServiceDesc for StationService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var StationService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "baywheels.v1.StationService",
+	HandlerType: (*StationServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListStations",
+			Handler:    _StationService_ListStations_Handler,
+		},
+		{
+			MethodName: "GetStation",
+			Handler:    _StationService_GetStation_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchStations",
+			Handler:       _StationService_WatchStations_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "api/baywheels/v1/baywheels.proto",
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/patrickod/baywheels-exporter/pkg/gbfs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// / backfillSink collects the samples of every cycle of a backfill, leaving
+// / out the exporter's own metrics which describe the backfill rather than
+// / the system.
+type backfillSink struct {
+	samples []Sample
+}
+
+func (b *backfillSink) Name() string {
+	return "backfill"
+}
+
+func (b *backfillSink) Send(ctx context.Context, cycle *Cycle) error {
+	for _, sample := range flattenFamilies(cycle.Families, cycle.Time) {
+		if !isExporterMetric(sample.Name) {
+			b.samples = append(b.samples, sample)
+		}
+	}
+	return nil
+}
+
+// / Run `backfill`, replaying a -record.dir recording through the exporter
+// / one recorded station_status at a time and pushing the resulting metrics
+// / to a remote_write endpoint, stamped with the last_updated time of each
+// / station_status rather than the time of the backfill.
+func runBackfill(args []string) error {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	dir := fs.String("record.dir", "", "Directory of recorded feeds to backfill")
+	namespace := fs.String("metrics.namespace", "", "Namespace prepended to station and bike metric names, as the exporter runs with")
+	batch := fs.Int("batch", 10, "Number of recorded cycles to push per remote_write request")
+	from := fs.String("from", "", "Only backfill the recording from this RFC3339 time on")
+	to := fs.String("to", "", "Only backfill the recording up to this RFC3339 time")
+	config := RemoteWriteConfig{Labels: map[string]string{}}
+	fs.StringVar(&config.URL, "remote-write.url", "", "Prometheus remote_write endpoint to push to")
+	fs.StringVar(&config.Username, "remote-write.username", "", "Basic auth username for the remote_write endpoint")
+	fs.StringVar(&config.Password, "remote-write.password", "", "Basic auth password for the remote_write endpoint")
+	fs.StringVar(&config.BearerToken, "remote-write.bearer-token", "", "Bearer token for the remote_write endpoint")
+	fs.Var(labelsFlag(config.Labels), "remote-write.label", "Label added to every pushed series as name=value; may be repeated")
+	fs.DurationVar(&config.Timeout, "remote-write.timeout", 30*time.Second, "Timeout for remote_write requests")
+	fs.Parse(args)
+
+	if *dir == "" || config.URL == "" {
+		return errors.New("backfill requires -record.dir and -remote-write.url")
+	}
+	var start, end time.Time
+	var err error
+	if *from != "" {
+		if start, err = time.Parse(time.RFC3339, *from); err != nil {
+			return fmt.Errorf("-from: %w", err)
+		}
+	}
+	if *to != "" {
+		if end, err = time.Parse(time.RFC3339, *to); err != nil {
+			return fmt.Errorf("-to: %w", err)
+		}
+	}
+
+	replay, err := LoadReplay(*dir)
+	if err != nil {
+		return err
+	}
+	registry := prometheus.NewRegistry()
+	writer := NewRemoteWrite(config, registry)
+	sink := &backfillSink{}
+	exporter := NewExporter(*dir, registry, *namespace)
+	exporter.client = gbfs.NewClient("http://replay", &http.Client{Transport: replay})
+	exporter.Sinks = []Sink{sink}
+
+	ctx := context.Background()
+	times := replay.Times("station_status")
+	pushed, cycles := 0, 0
+	for _, t := range times {
+		if !start.IsZero() && t.Before(start) || !end.IsZero() && t.After(end) {
+			continue
+		}
+		replay.Seek(t)
+		lastUpdated, err := recordedLastUpdated(replay, t)
+		if err != nil {
+			log.Printf("Error reading station_status recorded at %s %s\n", t, err)
+			continue
+		}
+		exporter.Now = func() time.Time { return lastUpdated }
+		exporter.Sample(ctx)
+		cycles++
+
+		if cycles%*batch == 0 {
+			if err := backfillPush(ctx, writer, sink.samples, config.Labels); err != nil {
+				return err
+			}
+			pushed += len(sink.samples)
+			sink.samples = sink.samples[:0]
+			log.Printf("Backfilled %d cycles up to %s, %d samples\n", cycles, lastUpdated.Format(time.RFC3339), pushed)
+		}
+	}
+	if len(sink.samples) > 0 {
+		if err := backfillPush(ctx, writer, sink.samples, config.Labels); err != nil {
+			return err
+		}
+		pushed += len(sink.samples)
+	}
+	log.Printf("Backfilled %d cycles, %d samples\n", cycles, pushed)
+	return nil
+}
+
+// / Return the last_updated time of the station_status recorded at t, or t
+// / itself if it has none.
+func recordedLastUpdated(replay *Replay, t time.Time) (time.Time, error) {
+	body, err := replay.body("station_status", t)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var envelope gbfs.Response[json.RawMessage]
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return time.Time{}, err
+	}
+	if envelope.LastUpdated.IsZero() {
+		return t, nil
+	}
+	return envelope.LastUpdated.Time, nil
+}
+
+// / Push samples in one remote_write request, retrying failures that may
+// / succeed later a few times.
+func backfillPush(ctx context.Context, w *RemoteWrite, samples []Sample, labels map[string]string) error {
+	if len(samples) == 0 {
+		return nil
+	}
+	body := snappy.Encode(nil, encodeWriteRequest(samples, labels))
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		err := w.post(ctx, body)
+		if err == nil || errors.Is(err, errNonRetryable) || attempt == 5 {
+			return err
+		}
+		log.Printf("Error writing to remote_write endpoint, retrying in %s: %s\n", backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	batteryBuckets = []float64{0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9, 1}
+	rangeBuckets   = prometheus.LinearBuckets(5000, 5000, 10)
+)
+
+// / BatteryMetrics reports the charge and remaining range of the free bikes
+// / of the latest sample as the number of bikes at or under each bucket's le,
+// / like the buckets of a histogram. They are gauges rather than a histogram
+// / as they are rebuilt every cycle, showing the current distribution of the
+// / fleet without keeping a series per bike, and so can go down.
+type BatteryMetrics struct {
+	bikes_battery_charge prometheus.GaugeVec
+	bikes_range_meters   prometheus.GaugeVec
+}
+
+func NewBatteryMetrics(reg prometheus.Registerer) *BatteryMetrics {
+	m := &BatteryMetrics{
+		bikes_battery_charge: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "bikes_battery_charge",
+			Help: "Number of free bikes in the latest sample with a battery charge, from 0 to 1, of at most le",
+		},
+			[]string{"le"},
+		),
+		bikes_range_meters: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "bikes_range_meters",
+			Help: "Number of free bikes in the latest sample with a remaining range of at most le meters",
+		},
+			[]string{"le"},
+		),
+	}
+	reg.MustRegister(m.bikes_battery_charge)
+	reg.MustRegister(m.bikes_range_meters)
+
+	return m
+}
+
+// / Count the bikes of snapshot reporting a charge or range into the
+// / buckets.
+func (m *BatteryMetrics) Observe(snapshot *Snapshot) {
+	var battery, ranges []float64
+	for _, bike := range snapshot.Bikes {
+		if bike.CurrentFuelPercent != nil {
+			battery = append(battery, *bike.CurrentFuelPercent)
+		}
+		if bike.CurrentRangeMeters != nil {
+			ranges = append(ranges, *bike.CurrentRangeMeters)
+		}
+	}
+	setBuckets(&m.bikes_battery_charge, batteryBuckets, battery)
+	setBuckets(&m.bikes_range_meters, rangeBuckets, ranges)
+}
+
+// / Set the gauge of each bucket of vec to the number of values at or under
+// / its upper bound, and that of le="+Inf" to the number of values.
+func setBuckets(vec *prometheus.GaugeVec, uppers []float64, values []float64) {
+	for _, upper := range uppers {
+		n := 0
+		for _, v := range values {
+			if v <= upper {
+				n++
+			}
+		}
+		vec.WithLabelValues(strconv.FormatFloat(upper, 'g', -1, 64)).Set(float64(n))
+	}
+	vec.WithLabelValues("+Inf").Set(float64(len(values)))
+}
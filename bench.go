@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/patrickod/baywheels-exporter/pkg/gbfs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// / cannedFeeds serves payloads held in memory in place of the live API, as
+// / an http.RoundTripper for the gbfs.Client, so benchmarks measure parsing
+// / and updating rather than the network or disk. Each feed alternates
+// / between its variants as cycle advances, so successive cycles change.
+type cannedFeeds struct {
+	variants map[string][][]byte
+	cycle    int
+}
+
+func (c *cannedFeeds) RoundTrip(req *http.Request) (*http.Response, error) {
+	feed := strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, "/"), ".json")
+	variants, ok := c.variants[feed]
+	if !ok {
+		return &http.Response{StatusCode: http.StatusNotFound, Status: "404 Not Found", Body: http.NoBody, Request: req}, nil
+	}
+	body := variants[c.cycle%len(variants)]
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Status:        "200 OK",
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// / Run `bench`, repeatedly parsing canned feeds, sampling them through the
+// / exporter and gathering its metrics, reporting the throughput and
+// / allocations of each so performance regressions in the collector path are
+// / measurable. The feeds are either generated for -stations stations and
+// / -bikes bikes or the last two recorded in a -record.dir.
+func runBench(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	dir := fs.String("record.dir", "", "Directory of recorded feeds to benchmark with instead of generated ones")
+	stations := fs.Int("stations", 500, "Number of stations in generated feeds")
+	bikes := fs.Int("bikes", 1000, "Number of free bikes in generated feeds")
+	cycles := fs.Int("cycles", 1000, "Number of cycles to run each benchmark for")
+	fs.Parse(args)
+
+	if *cycles < 1 {
+		return errors.New("-cycles must be positive")
+	}
+	feeds := &cannedFeeds{variants: make(map[string][][]byte)}
+	if *dir != "" {
+		replay, err := LoadReplay(*dir)
+		if err != nil {
+			return err
+		}
+		for _, feed := range sampledFeeds {
+			times := replay.Times(feed)
+			for _, t := range times[max(len(times)-2, 0):] {
+				body, err := replay.body(feed, t)
+				if err != nil {
+					return err
+				}
+				feeds.variants[feed] = append(feeds.variants[feed], body)
+			}
+		}
+	} else {
+		for variant := range 2 {
+			for feed, data := range generateFeeds(*stations, *bikes, variant) {
+				body, err := json.Marshal(map[string]any{
+					"last_updated": time.Now().Unix(),
+					"ttl":          60,
+					"version":      "2.3",
+					"data":         data,
+				})
+				if err != nil {
+					return err
+				}
+				feeds.variants[feed] = append(feeds.variants[feed], body)
+			}
+		}
+	}
+	if len(feeds.variants["station_status"]) == 0 {
+		return errors.New("no station_status to benchmark with")
+	}
+	var status gbfs.Response[gbfs.StationStatusData]
+	if err := json.Unmarshal(feeds.variants["station_status"][0], &status); err != nil {
+		return fmt.Errorf("station_status: %w", err)
+	}
+	count := len(status.Data.Stations)
+
+	ctx := context.Background()
+	client := gbfs.NewClient("http://bench", &http.Client{Transport: feeds})
+	getters := map[string]func() error{
+		"station_information": func() error { _, err := client.StationInformation(ctx); return err },
+		"station_status":      func() error { _, err := client.StationStatus(ctx); return err },
+		"free_bike_status":    func() error { _, err := client.FreeBikeStatus(ctx); return err },
+		"vehicle_types":       func() error { _, err := client.VehicleTypes(ctx); return err },
+	}
+	parse := func() error {
+		for _, feed := range sampledFeeds {
+			if _, ok := feeds.variants[feed]; !ok {
+				continue
+			}
+			if err := getters[feed](); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	registry := prometheus.NewRegistry()
+	exporter := NewExporter("http://bench", registry, "")
+	exporter.client = gbfs.NewClient("http://bench", &http.Client{Transport: feeds})
+	// the exporter logs every cycle
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	sample := func() error {
+		exporter.Sample(ctx)
+		return nil
+	}
+	// as scraped by /metrics after the sampled cycles
+	gather := func() error {
+		_, err := registry.Gather()
+		return err
+	}
+
+	fmt.Printf("%d stations, %d cycles\n", count, *cycles)
+	for _, b := range []struct {
+		name string
+		run  func() error
+	}{
+		{"parse", parse},
+		{"sample", sample},
+		{"gather", gather},
+	} {
+		result, err := benchmark(*cycles, feeds, b.run)
+		if err != nil {
+			return fmt.Errorf("%s: %w", b.name, err)
+		}
+		perCycle := result.elapsed / time.Duration(*cycles)
+		fmt.Printf("%-8s %12s/cycle %10d allocs/cycle %12d B/cycle %12.0f stations/s\n",
+			b.name,
+			perCycle,
+			result.allocs/uint64(*cycles),
+			result.bytes/uint64(*cycles),
+			float64(count)*float64(*cycles)/result.elapsed.Seconds(),
+		)
+	}
+	return nil
+}
+
+type benchResult struct {
+	elapsed time.Duration
+	allocs  uint64
+	bytes   uint64
+}
+
+// / Run cycles cycles of run, after one to warm up, measuring the time
+// / taken and memory allocated.
+func benchmark(cycles int, feeds *cannedFeeds, run func() error) (benchResult, error) {
+	if err := run(); err != nil {
+		return benchResult{}, err
+	}
+	runtime.GC()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	for i := range cycles {
+		feeds.cycle = i + 1
+		if err := run(); err != nil {
+			return benchResult{}, err
+		}
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+	return benchResult{
+		elapsed: elapsed,
+		allocs:  after.Mallocs - before.Mallocs,
+		bytes:   after.TotalAlloc - before.TotalAlloc,
+	}, nil
+}
+
+// / Return the data of generated feeds for a system of stations stations
+// / and bikes free bikes. Variants differ in their availability, as
+// / successive fetches of a live system would.
+func generateFeeds(stations, bikes, variant int) map[string]any {
+	information := make([]map[string]any, stations)
+	status := make([]map[string]any, stations)
+	now := time.Now().Unix()
+	for i := range stations {
+		id := fmt.Sprintf("station-%d", i)
+		information[i] = map[string]any{
+			"station_id": id,
+			"name":       fmt.Sprintf("Station %d", i),
+			"lat":        37.7 + float64(i%100)/1000,
+			"lon":        -122.4 + float64(i/100)/1000,
+			"capacity":   20,
+		}
+		available := (i + variant) % 20
+		status[i] = map[string]any{
+			"station_id":           id,
+			"num_bikes_available":  available,
+			"num_bikes_disabled":   i % 2,
+			"num_docks_available":  20 - available,
+			"num_docks_disabled":   0,
+			"num_ebikes_available": available / 2,
+			"is_installed":         1,
+			"is_renting":           1,
+			"is_returning":         1,
+			"last_reported":        now,
+		}
+	}
+	free := make([]map[string]any, bikes)
+	for i := range bikes {
+		free[i] = map[string]any{
+			"bike_id":         fmt.Sprintf("bike-%d", i),
+			"is_reserved":     0,
+			"is_disabled":     (i + variant) % 50 / 49,
+			"lat":             37.7 + float64(i%100)/1000,
+			"lon":             -122.4 + float64((i+variant)/100)/1000,
+			"vehicle_type_id": "1",
+		}
+	}
+	return map[string]any{
+		"station_information": map[string]any{"stations": information},
+		"station_status":      map[string]any{"stations": status},
+		"free_bike_status":    map[string]any{"bikes": free},
+		"vehicle_types": map[string]any{"vehicle_types": []map[string]any{
+			{"vehicle_type_id": "1", "form_factor": "bicycle", "propulsion_type": "human"},
+		}},
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// / bikeStillMeters is how far a free bike can drift between samples, e.g.
+// / from GPS jitter, and still be considered at the same spot.
+const bikeStillMeters = 10
+
+// / bikeMemory is how long the last position of a bike that was rented is
+// / remembered, to measure how far it moved once it is listed again.
+const bikeMemory = 24 * time.Hour
+
+type bikeState struct {
+	lat, lon float64
+	since    time.Time
+	// lastSeen is the time of the last sample listing the bike
+	lastSeen time.Time
+	// disabledSince is when the bike was first seen disabled, zero while it
+	// is enabled
+	disabledSince time.Time
+}
+
+// / BikeMetrics tracks the free bikes across samples, recording how long each
+// / has been sitting at the same spot and how far bikes move when they are
+// / ridden, as a proxy for the trip lengths of the dockless fleet.
+// /
+// / Bikes that stay disabled for longer than GhostThreshold are counted as
+// / ghost bikes, which usually need to be retrieved by the operator.
+type BikeMetrics struct {
+	// IdleThreshold is how long a bike must sit still to be counted as idle.
+	IdleThreshold time.Duration
+	// GhostThreshold is how long a bike must stay disabled to be counted as
+	// a ghost bike.
+	GhostThreshold time.Duration
+	// GhostBikes exports the disabled time of every ghost bike as well.
+	GhostBikes bool
+
+	bike_idle_seconds     prometheus.GaugeVec
+	bikes_idle            prometheus.Gauge
+	bike_displacement     prometheus.Histogram
+	bike_disabled_seconds prometheus.GaugeVec
+	bikes_ghost           prometheus.Gauge
+
+	bikes map[string]*bikeState
+}
+
+func NewBikeMetrics(reg prometheus.Registerer) *BikeMetrics {
+	m := &BikeMetrics{
+		IdleThreshold:  24 * time.Hour,
+		GhostThreshold: 72 * time.Hour,
+		bike_idle_seconds: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "bike_idle_seconds",
+			Help: "Seconds the free bike has been at the same coordinates, counted from when the exporter started at the earliest",
+		},
+			[]string{"bike_id"},
+		),
+		bikes_idle: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "bikes_idle",
+			Help: "Number of free bikes that have been at the same coordinates for longer than the idle threshold",
+		}),
+		bike_displacement: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "bike_displacement_meters",
+			Help:    "Distance between the coordinates a free bike was last seen at and those it reappeared at",
+			Buckets: prometheus.ExponentialBuckets(100, 2, 8),
+		}),
+		bike_disabled_seconds: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "bike_ghost_disabled_seconds",
+			Help: "Seconds the ghost bike has been disabled, counted from when the exporter started at the earliest",
+		},
+			[]string{"bike_id"},
+		),
+		bikes_ghost: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "bikes_ghost",
+			Help: "Number of free bikes that have been disabled for longer than the ghost threshold",
+		}),
+		bikes: make(map[string]*bikeState),
+	}
+	reg.MustRegister(m.bike_idle_seconds)
+	reg.MustRegister(m.bikes_idle)
+	reg.MustRegister(m.bike_displacement)
+	reg.MustRegister(m.bike_disabled_seconds)
+	reg.MustRegister(m.bikes_ghost)
+
+	return m
+}
+
+// / Update the idle and disabled time of every bike in snapshot and record
+// / the distance moved by bikes listed at new coordinates. The idle time of
+// / bikes no longer listed is dropped, their position kept for bikeMemory.
+func (m *BikeMetrics) Observe(snapshot *Snapshot) {
+	idle, ghosts := 0, 0
+	for _, bike := range snapshot.Bikes {
+		state, ok := m.bikes[bike.BikeId]
+		if ok {
+			if moved := distance(state.lat, state.lon, bike.Lat, bike.Lon); moved > bikeStillMeters {
+				m.bike_displacement.Observe(moved)
+				state.lat, state.lon, state.since = bike.Lat, bike.Lon, snapshot.Time
+			}
+		} else {
+			state = &bikeState{lat: bike.Lat, lon: bike.Lon, since: snapshot.Time}
+			m.bikes[bike.BikeId] = state
+		}
+		state.lastSeen = snapshot.Time
+
+		idleFor := snapshot.Time.Sub(state.since)
+		if idleFor > m.IdleThreshold {
+			idle++
+		}
+		m.bike_idle_seconds.With(prometheus.Labels{"bike_id": bike.BikeId}).Set(idleFor.Seconds())
+
+		switch {
+		case bike.IsDisabled == 0:
+			state.disabledSince = time.Time{}
+		case state.disabledSince.IsZero():
+			state.disabledSince = snapshot.Time
+		}
+		if disabledFor := snapshot.Time.Sub(state.disabledSince); !state.disabledSince.IsZero() && disabledFor > m.GhostThreshold {
+			ghosts++
+			if m.GhostBikes {
+				m.bike_disabled_seconds.With(prometheus.Labels{"bike_id": bike.BikeId}).Set(disabledFor.Seconds())
+			}
+		} else {
+			m.bike_disabled_seconds.DeleteLabelValues(bike.BikeId)
+		}
+	}
+	m.bikes_idle.Set(float64(idle))
+	m.bikes_ghost.Set(float64(ghosts))
+
+	for id, state := range m.bikes {
+		if state.lastSeen.Equal(snapshot.Time) {
+			continue
+		}
+		m.bike_idle_seconds.DeleteLabelValues(id)
+		m.bike_disabled_seconds.DeleteLabelValues(id)
+		if snapshot.Time.Sub(state.lastSeen) > bikeMemory {
+			delete(m.bikes, id)
+		}
+	}
+}
+
+// / Return the great-circle distance in meters between two coordinates using
+// / the haversine formula.
+func distance(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadius = 6371000
+	rad := math.Pi / 180
+	dlat := (lat2 - lat1) * rad
+	dlon := (lon2 - lon1) * rad
+	a := math.Sin(dlat/2)*math.Sin(dlat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dlon/2)*math.Sin(dlon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
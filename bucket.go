@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"log"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+type BucketConfig struct {
+	// Endpoint of the S3 compatible API; storage.googleapis.com for GCS
+	// using HMAC keys.
+	Endpoint  string
+	Insecure  bool
+	Region    string
+	Bucket    string
+	Prefix    string
+	AccessKey string
+	SecretKey string
+	// Retention after which uploaded objects are deleted from the bucket;
+	// kept forever if zero.
+	Retention time.Duration
+}
+
+// / BucketUploader copies completed archive files to an S3 compatible bucket
+// / in the background so sampling isn't held up by slow uploads. Files are
+// / stored under <prefix>/<feed>/<file> mirroring the local archive.
+type BucketUploader struct {
+	config BucketConfig
+	client *minio.Client
+	dir    string
+	queue  chan string
+}
+
+func NewBucketUploader(config BucketConfig, dir string) (*BucketUploader, error) {
+	creds := credentials.NewStaticV4(config.AccessKey, config.SecretKey, "")
+	if config.AccessKey == "" {
+		// fall back to the usual AWS environment variables, shared
+		// credentials file and instance metadata
+		creds = credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.FileAWSCredentials{},
+			&credentials.IAM{},
+		})
+	}
+	client, err := minio.New(config.Endpoint, &minio.Options{
+		Creds:  creds,
+		Secure: !config.Insecure,
+		Region: config.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &BucketUploader{
+		config: config,
+		client: client,
+		dir:    dir,
+		queue:  make(chan string, 1024),
+	}, nil
+}
+
+// / Queue a completed file for upload. Files already in the bucket are
+// / skipped, so it is safe to queue the same file again after a restart.
+func (u *BucketUploader) Upload(path string) {
+	select {
+	case u.queue <- path:
+	default:
+		log.Printf("Error queueing %s for upload: queue full\n", path)
+	}
+}
+
+func (u *BucketUploader) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case file := <-u.queue:
+			// retry until the upload succeeds; the files stay on disk so
+			// there's nothing to lose by waiting
+			for {
+				err := u.upload(ctx, file)
+				if err == nil {
+					break
+				}
+				log.Printf("Error uploading %s %s\n", file, err)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(time.Minute):
+				}
+			}
+			if u.config.Retention > 0 && len(u.queue) == 0 {
+				if err := u.expire(ctx); err != nil {
+					log.Printf("Error expiring archived objects %s\n", err)
+				}
+			}
+		}
+	}
+}
+
+func (u *BucketUploader) key(file string) (string, error) {
+	rel, err := filepath.Rel(u.dir, file)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(u.config.Prefix, filepath.ToSlash(rel)), nil
+}
+
+func (u *BucketUploader) upload(ctx context.Context, file string) error {
+	key, err := u.key(file)
+	if err != nil {
+		return err
+	}
+	if _, err := u.client.StatObject(ctx, u.config.Bucket, key, minio.StatObjectOptions{}); err == nil {
+		return nil
+	} else if minio.ToErrorResponse(err).Code != minio.NoSuchKey {
+		return err
+	}
+	_, err = u.client.FPutObject(ctx, u.config.Bucket, key, file, minio.PutObjectOptions{
+		ContentType: "application/vnd.apache.parquet",
+	})
+	if err == nil {
+		log.Printf("Uploaded %s to %s/%s\n", file, u.config.Bucket, key)
+	}
+	return err
+}
+
+// / Delete objects under the prefix that were uploaded longer than the
+// / retention ago.
+func (u *BucketUploader) expire(ctx context.Context) error {
+	cutoff := time.Now().Add(-u.config.Retention)
+	prefix := u.config.Prefix
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	objects := u.client.ListObjects(ctx, u.config.Bucket, minio.ListObjectsOptions{
+		Prefix:    prefix,
+		Recursive: true,
+	})
+	for object := range objects {
+		if object.Err != nil {
+			return object.Err
+		}
+		// only ever touch archive files, even with an empty prefix
+		if path.Ext(object.Key) == ".parquet" && object.LastModified.Before(cutoff) {
+			if err := u.client.RemoveObject(ctx, u.config.Bucket, object.Key, minio.RemoveObjectOptions{}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/patrickod/baywheels-exporter/pkg/gbfs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// / captureFeeds are the feeds captured from a -gbfs.url that is a base URL
+// / rather than an auto-discovery file, which can't list its feeds.
+var captureFeeds = []string{
+	"system_information",
+	"station_information",
+	"station_status",
+	"free_bike_status",
+	"vehicle_types",
+	"system_hours",
+	"system_alerts",
+	"system_regions",
+	"system_pricing_plans",
+	"geofencing_zones",
+}
+
+// / Run `capture`, fetching every feed of a system once and writing it to
+// / -out as a fixture, for reproducible tests and replays. Fixtures are
+// / sanitized, with rental URIs removed and bike IDs replaced, and can be
+// / cut down to the first -stations stations and -bikes bikes.
+func runCapture(args []string) error {
+	fs := flag.NewFlagSet("capture", flag.ExitOnError)
+	gbfsURL := fs.String("gbfs.url", BaywheelsURI, "Base URL of the GBFS feeds to capture, or the URL of the system's gbfs.json")
+	out := fs.String("out", "testdata", "Directory to write the fixtures to")
+	layout := fs.String("layout", "flat", "Layout of -out: flat, as <feed>.json, or record, as <feed>/<time>.json for -replay.dir and mock-server -record.dir")
+	baseURL := fs.String("base-url", "http://localhost:8080", "Base URL the feeds are listed under in the captured gbfs.json")
+	stations := fs.Int("stations", 0, "Keep only the first this many stations; all if zero")
+	bikes := fs.Int("bikes", 0, "Keep only the first this many free bikes; all if zero")
+	sanitize := fs.Bool("sanitize", true, "Remove rental URIs and replace bike IDs, which can identify riders' trips")
+	var upstream UpstreamAuth
+	upstream.Flags(fs)
+	fs.Parse(args)
+
+	if *layout != "flat" && *layout != "record" {
+		return fmt.Errorf("unknown layout %q", *layout)
+	}
+	client, err := upstream.Client(prometheus.NewRegistry())
+	if err != nil {
+		return err
+	}
+	c := gbfs.NewClient(*gbfsURL, client)
+	bodies := make(map[string][]byte)
+	c.OnFetch = func(feed string, body []byte) {
+		bodies[feed] = bytes.Clone(body)
+	}
+
+	ctx := context.Background()
+	feeds, err := c.Feeds(ctx)
+	if err != nil {
+		return err
+	}
+	if feeds == nil {
+		feeds = captureFeeds
+	}
+	for _, feed := range feeds {
+		if feed == "gbfs" {
+			continue
+		}
+		var raw json.RawMessage
+		if err := c.Fetch(ctx, feed, &raw); err != nil {
+			log.Printf("Error capturing %s %s\n", feed, err)
+			delete(bodies, feed)
+		}
+	}
+
+	capture := &fixtureCapture{stations: *stations, bikes: *bikes, sanitize: *sanitize, bikeIds: make(map[string]string)}
+	// station_information picks the stations kept of the other feeds
+	names := append([]string{"station_information"}, feeds...)
+	if bodies["gbfs"] != nil {
+		names = append(names, "gbfs")
+	}
+	now := time.Now()
+	written := make(map[string]bool)
+	for _, feed := range names {
+		body, ok := bodies[feed]
+		if !ok || written[feed] {
+			continue
+		}
+		written[feed] = true
+		var v map[string]any
+		if err := json.Unmarshal(body, &v); err != nil {
+			return fmt.Errorf("%s: %w", feed, err)
+		}
+		if feed == "gbfs" {
+			capture.rewriteFeeds(v, strings.TrimRight(*baseURL, "/"), written)
+		} else {
+			capture.fixture(v)
+		}
+		b, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		path := filepath.Join(*out, feed+".json")
+		if *layout == "record" {
+			path = filepath.Join(*out, feed, now.UTC().Format(recordTimeFormat)+".json")
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, append(b, '\n'), 0o644); err != nil {
+			return err
+		}
+		log.Printf("Captured %s to %s\n", feed, path)
+	}
+	return nil
+}
+
+// / fixtureCapture cuts down and sanitizes captured feeds consistently
+// / across them, so station_status keeps the stations station_information
+// / does and a bike keeps the same replaced ID in every feed.
+type fixtureCapture struct {
+	stations int
+	bikes    int
+	sanitize bool
+
+	// kept are the station IDs kept, once station_information is captured
+	kept    map[string]bool
+	bikeIds map[string]string
+}
+
+func (f *fixtureCapture) fixture(v map[string]any) {
+	data, _ := v["data"].(map[string]any)
+	if list, ok := data["stations"].([]any); ok {
+		data["stations"] = f.keepStations(list)
+	}
+	for _, name := range []string{"bikes", "vehicles"} {
+		if list, ok := data[name].([]any); ok && f.bikes > 0 && len(list) > f.bikes {
+			data[name] = list[:f.bikes]
+		}
+	}
+	if f.sanitize {
+		f.scrub(v)
+	}
+}
+
+func (f *fixtureCapture) keepStations(list []any) []any {
+	if f.stations <= 0 {
+		return list
+	}
+	if f.kept == nil {
+		f.kept = make(map[string]bool)
+		for _, station := range list[:min(f.stations, len(list))] {
+			if station, ok := station.(map[string]any); ok {
+				id, _ := station["station_id"].(string)
+				f.kept[id] = true
+			}
+		}
+	}
+	var kept []any
+	for _, station := range list {
+		if station, ok := station.(map[string]any); ok {
+			if id, _ := station["station_id"].(string); f.kept[id] {
+				kept = append(kept, station)
+			}
+		}
+	}
+	return kept
+}
+
+// / Remove rental URIs and replace bike and vehicle IDs throughout v.
+func (f *fixtureCapture) scrub(v any) {
+	switch v := v.(type) {
+	case map[string]any:
+		delete(v, "rental_uris")
+		for _, key := range []string{"bike_id", "vehicle_id"} {
+			if id, ok := v[key].(string); ok {
+				if f.bikeIds[id] == "" {
+					f.bikeIds[id] = fmt.Sprintf("bike-%d", len(f.bikeIds))
+				}
+				v[key] = f.bikeIds[id]
+			}
+		}
+		for _, child := range v {
+			f.scrub(child)
+		}
+	case []any:
+		for _, child := range v {
+			f.scrub(child)
+		}
+	}
+}
+
+// / Point the feeds listed in a captured gbfs.json at base, dropping those
+// / that weren't captured.
+func (f *fixtureCapture) rewriteFeeds(v map[string]any, base string, captured map[string]bool) {
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			if list, ok := v["feeds"].([]any); ok {
+				var feeds []any
+				for _, feed := range list {
+					feed, ok := feed.(map[string]any)
+					name, _ := feed["name"].(string)
+					if !ok || !captured[name] {
+						continue
+					}
+					feed["url"] = base + "/" + name + ".json"
+					feeds = append(feeds, feed)
+				}
+				v["feeds"] = feeds
+			}
+			for key, child := range v {
+				if key != "feeds" {
+					walk(child)
+				}
+			}
+		}
+	}
+	walk(v)
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// / CatalogURL is the MobilityData catalog of public GBFS systems.
+const CatalogURL = "https://raw.githubusercontent.com/MobilityData/gbfs/master/systems.csv"
+
+// / CatalogSystem is a row of the systems catalog.
+type CatalogSystem struct {
+	CountryCode   string
+	Name          string
+	Location      string
+	ID            string
+	URL           string
+	AutoDiscovery string
+	Versions      string
+	// Authentication is the type of authentication the feeds require, e.g.
+	// api_key or oauth_client_credentials_grant, empty if they are public.
+	Authentication string
+}
+
+// / catalogFilterKeys are the keys of a -gbfs.discover filter.
+var catalogFilterKeys = map[string]bool{"country": true, "city": true, "name": true, "id": true}
+
+// / Parse a filter such as "country=US,city=San Francisco". country and id
+// / match exactly, city and name (or operator) match part of the Location
+// / and Name columns, all ignoring case.
+func parseCatalogFilter(s string) (map[string]string, error) {
+	filter := make(map[string]string)
+	if strings.TrimSpace(s) == "" {
+		return filter, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if key == "operator" {
+			key = "name"
+		}
+		if !ok || !catalogFilterKeys[key] {
+			return nil, fmt.Errorf("expected country=, city=, name= or id=, got %q", pair)
+		}
+		filter[key] = strings.ToLower(strings.TrimSpace(value))
+	}
+	return filter, nil
+}
+
+func (s CatalogSystem) matches(filter map[string]string) bool {
+	for key, value := range filter {
+		switch key {
+		case "country":
+			if strings.ToLower(s.CountryCode) != value {
+				return false
+			}
+		case "id":
+			if strings.ToLower(s.ID) != value {
+				return false
+			}
+		case "city":
+			if !strings.Contains(strings.ToLower(s.Location), value) {
+				return false
+			}
+		case "name":
+			if !strings.Contains(strings.ToLower(s.Name), value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// / Download the systems catalog at url and return the systems matching
+// / filter. Columns are looked up by their header, as the catalog gains
+// / columns over time.
+func discoverSystems(ctx context.Context, url, filter string) ([]CatalogSystem, error) {
+	parsed, err := parseCatalogFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("catalog returned %s", resp.Status)
+	}
+
+	r := csv.NewReader(resp.Body)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, name := range []string{"Country Code", "Name", "Location", "System ID", "Auto-Discovery URL"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("catalog has no %q column", name)
+		}
+	}
+
+	var systems []CatalogSystem
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		system := CatalogSystem{
+			CountryCode:    field("Country Code"),
+			Name:           field("Name"),
+			Location:       field("Location"),
+			ID:             field("System ID"),
+			URL:            field("URL"),
+			AutoDiscovery:  field("Auto-Discovery URL"),
+			Versions:       field("Supported Versions"),
+			Authentication: field("Authentication Type"),
+		}
+		if system.AutoDiscovery != "" && system.matches(parsed) {
+			systems = append(systems, system)
+		}
+	}
+	return systems, nil
+}
+
+// / Return the auto-discovery URL of the one system in the catalog matching
+// / filter, for -gbfs.discover. Several matches are an error listing them,
+// / so the filter can be narrowed down, e.g. with id=.
+func discoverURL(ctx context.Context, catalog, filter string) (string, error) {
+	systems, err := discoverSystems(ctx, catalog, filter)
+	if err != nil {
+		return "", err
+	}
+	switch len(systems) {
+	case 0:
+		return "", fmt.Errorf("no system in the catalog matches %q", filter)
+	case 1:
+		system := systems[0]
+		log.Printf("Discovered %s (%s) in %s at %s\n", system.Name, system.ID, system.Location, system.AutoDiscovery)
+		if system.Authentication != "" {
+			log.Printf("%s requires %s authentication, see -gbfs.api-key-header and -gbfs.oauth2.token-url\n", system.ID, system.Authentication)
+		}
+		return system.AutoDiscovery, nil
+	}
+	ids := make([]string, len(systems))
+	for i, system := range systems {
+		ids[i] = system.ID
+	}
+	return "", fmt.Errorf("%d systems match %q, narrow it down with id=, one of %s", len(systems), filter, strings.Join(ids, ", "))
+}
+
+// / Run `systems`, listing the systems in the catalog matching -discover,
+// / e.g. to start an exporter for each of them.
+func runSystems(args []string) error {
+	fs := flag.NewFlagSet("systems", flag.ExitOnError)
+	discover := fs.String("discover", "", "Filter of the systems to list, e.g. country=US,city=San Francisco; every system if empty")
+	catalog := fs.String("catalog-url", CatalogURL, "URL of the MobilityData systems.csv catalog")
+	format := fs.String("format", "table", "Output format: table, or urls for the auto-discovery URLs alone")
+	fs.Parse(args)
+
+	systems, err := discoverSystems(context.Background(), *catalog, *discover)
+	if err != nil {
+		return err
+	}
+	switch *format {
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tLOCATION\tAUTHENTICATION\tAUTO-DISCOVERY URL")
+		for _, system := range systems {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", system.ID, system.Name, system.Location, system.Authentication, system.AutoDiscovery)
+		}
+		return w.Flush()
+	case "urls":
+		for _, system := range systems {
+			fmt.Println(system.AutoDiscovery)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/patrickod/baywheels-exporter/pkg/gbfs"
+)
+
+// / StationChange describes a station whose availability differs between two
+// / consecutive snapshots. Previous is nil for a station that has just
+// / appeared in station_status and Current is nil for one that disappeared.
+type StationChange struct {
+	Time      time.Time           `json:"time"`
+	StationId string              `json:"station_id"`
+	Name      string              `json:"name"`
+	Previous  *gbfs.StationStatus `json:"previous"`
+	Current   *gbfs.StationStatus `json:"current"`
+}
+
+// / Report whether two statuses of the same station differ in anything a
+// / rider would care about. last_reported is deliberately ignored, as it
+// / advances on every check-in without the availability changing.
+func availabilityChanged(a, b *gbfs.StationStatus) bool {
+	return a.IsInstalled != b.IsInstalled ||
+		a.IsRenting != b.IsRenting ||
+		a.IsReturning != b.IsReturning ||
+		a.BikesAvailable != b.BikesAvailable ||
+		a.BikesDisabled != b.BikesDisabled ||
+		a.DocksAvailable != b.DocksAvailable ||
+		a.DocksDisabled != b.DocksDisabled ||
+		a.EBikesAvailable != b.EBikesAvailable ||
+		a.ScootersAvailable != b.ScootersAvailable ||
+		a.ScootersUnavailable != b.ScootersUnavailable
+}
+
+// / Compute the station changes between two snapshots. A nil prev yields no
+// / changes, so the first cycle after startup doesn't report every station.
+func diffStations(prev, cur *Snapshot) []StationChange {
+	if prev == nil {
+		return nil
+	}
+
+	var changes []StationChange
+	for _, station := range cur.Stations {
+		before, _ := prev.Station(station.StationId)
+		switch {
+		case station.Status == nil && before.Status == nil:
+			continue
+		case station.Status != nil && before.Status != nil && !availabilityChanged(before.Status, station.Status):
+			continue
+		}
+		changes = append(changes, StationChange{
+			Time:      cur.Time,
+			StationId: station.StationId,
+			Name:      string(station.Name),
+			Previous:  before.Status,
+			Current:   station.Status,
+		})
+	}
+	for _, station := range prev.Stations {
+		if _, ok := cur.Station(station.StationId); !ok && station.Status != nil {
+			changes = append(changes, StationChange{
+				Time:      cur.Time,
+				StationId: station.StationId,
+				Name:      string(station.Name),
+				Previous:  station.Status,
+			})
+		}
+	}
+	return changes
+}
+
+// / BikeEvent records a free bike appearing in or disappearing from
+// / free_bike_status between two snapshots, typically because it was returned
+// / or rented.
+type BikeEvent struct {
+	Time time.Time       `json:"time"`
+	Type string          `json:"type"`
+	Bike gbfs.BikeStatus `json:"bike"`
+}
+
+const (
+	BikeAppeared    = "appeared"
+	BikeDisappeared = "disappeared"
+)
+
+// / Compute the bikes that appeared or disappeared between two snapshots. As
+// / with diffStations a nil prev yields no events.
+func diffBikes(prev, cur *Snapshot) []BikeEvent {
+	if prev == nil {
+		return nil
+	}
+
+	before := make(map[string]bool, len(prev.Bikes))
+	for _, bike := range prev.Bikes {
+		before[bike.BikeId] = true
+	}
+	after := make(map[string]bool, len(cur.Bikes))
+	var events []BikeEvent
+	for _, bike := range cur.Bikes {
+		after[bike.BikeId] = true
+		if !before[bike.BikeId] {
+			events = append(events, BikeEvent{Time: cur.Time, Type: BikeAppeared, Bike: bike})
+		}
+	}
+	for _, bike := range prev.Bikes {
+		if !after[bike.BikeId] {
+			events = append(events, BikeEvent{Time: cur.Time, Type: BikeDisappeared, Bike: bike})
+		}
+	}
+	return events
+}
+
+// / ChangeFeed fans out station changes to any number of subscribers, such
+// / as connected WebSocket clients.
+type ChangeFeed struct {
+	mu          sync.Mutex
+	subscribers map[chan StationChange]struct{}
+}
+
+func NewChangeFeed() *ChangeFeed {
+	return &ChangeFeed{subscribers: make(map[chan StationChange]struct{})}
+}
+
+// / Subscribe to future changes. The returned function must be called to
+// / unsubscribe once the caller stops reading.
+func (f *ChangeFeed) Subscribe() (<-chan StationChange, func()) {
+	// buffer a few cycles worth of changes for a busy system
+	ch := make(chan StationChange, 1024)
+
+	f.mu.Lock()
+	f.subscribers[ch] = struct{}{}
+	f.mu.Unlock()
+
+	return ch, func() {
+		f.mu.Lock()
+		delete(f.subscribers, ch)
+		f.mu.Unlock()
+	}
+}
+
+// / Publish changes to every subscriber. Subscribers who have fallen too far
+// / behind miss changes rather than blocking the sampling loop.
+func (f *ChangeFeed) Publish(changes []StationChange) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for ch := range f.subscribers {
+		for _, change := range changes {
+			select {
+			case ch <- change:
+			default:
+				log.Printf("Dropping change to station %s for slow subscriber\n", change.StationId)
+			}
+		}
+	}
+}
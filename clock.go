@@ -0,0 +1,81 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// / ClockMetrics compares the last_updated of every feed and the latest
+// / last_reported of the stations with the local time of the cycle. Data is
+// / never newer than the time it is fetched, so timestamps ahead of the local
+// / time by more than WarnAfter mean the system's clock or this host's has
+// / drifted, which is logged as it makes freshness alerts meaningless.
+type ClockMetrics struct {
+	WarnAfter time.Duration
+
+	feed_clock_skew_seconds    prometheus.GaugeVec
+	station_clock_skew_seconds prometheus.Gauge
+
+	warned map[string]bool
+}
+
+func NewClockMetrics(reg prometheus.Registerer) *ClockMetrics {
+	m := &ClockMetrics{
+		WarnAfter: 30 * time.Second,
+		feed_clock_skew_seconds: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "baywheels_exporter_feed_clock_skew_seconds",
+			Help: "Seconds the last_updated of each GBFS feed is ahead of the local time it was fetched at; negative while the data ages",
+		},
+			[]string{"feed"},
+		),
+		station_clock_skew_seconds: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "baywheels_exporter_station_clock_skew_seconds",
+			Help: "Seconds the latest last_reported of the stations is ahead of the local time it was fetched at",
+		}),
+		warned: make(map[string]bool),
+	}
+	reg.MustRegister(m.feed_clock_skew_seconds)
+	reg.MustRegister(m.station_clock_skew_seconds)
+
+	return m
+}
+
+// / Observe the timestamps of the feeds fetched in cycle.
+func (m *ClockMetrics) Observe(cycle *Cycle, feeds []FeedStatus) {
+	for _, status := range feeds {
+		if cycle.Fetched[status.Feed] && !status.LastUpdated.IsZero() {
+			skew := status.LastUpdated.Sub(cycle.Time)
+			m.observe(status.Feed+" last_updated", skew)
+			m.feed_clock_skew_seconds.WithLabelValues(status.Feed).Set(skew.Seconds())
+		}
+	}
+
+	if !cycle.Fetched["station_status"] {
+		return
+	}
+	var latest time.Time
+	for _, station := range cycle.Snapshot.Stations {
+		if station.Status != nil && station.Status.LastReported.After(latest) {
+			latest = station.Status.LastReported.Time
+		}
+	}
+	if !latest.IsZero() {
+		skew := latest.Sub(cycle.Time)
+		m.observe("station_status last_reported", skew)
+		m.station_clock_skew_seconds.Set(skew.Seconds())
+	}
+}
+
+// / Log a warning when a timestamp first gets too far ahead, and when it is
+// / back in line.
+func (m *ClockMetrics) observe(what string, skew time.Duration) {
+	ahead := m.WarnAfter > 0 && skew > m.WarnAfter
+	if ahead && !m.warned[what] {
+		log.Printf("Warning %s is %s ahead of local time, either the system's clock or this host's is off\n", what, skew.Round(time.Second))
+	} else if !ahead && m.warned[what] {
+		log.Printf("Clock skew of %s back within %s\n", what, m.WarnAfter)
+	}
+	m.warned[what] = ahead
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/patrickod/baywheels-exporter/pkg/gbfs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// / Closure decides whether the system is closed, from its system_hours, a
+// / system_closure alert in system_alerts or a configured quiet window, so
+// / status sampling can pause rather than export a wall of zeros that
+// / triggers availability alerts every night. While closed the status
+// / metrics hold their values from before the closure and
+// / baywheels_exporter_system_closed is 1.
+type Closure struct {
+	Hours   bool
+	Alerts  bool
+	Windows []ScheduleWindow
+	// Location of the Windows; the local time if nil.
+	Location *time.Location
+
+	// time zone of system_hours, from system_information
+	system *time.Location
+	reason string
+
+	system_closed prometheus.Gauge
+}
+
+func NewClosure(reg prometheus.Registerer) *Closure {
+	c := &Closure{
+		system_closed: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "baywheels_exporter_system_closed",
+			Help: "1 while the system is closed and status sampling is paused, holding the status metrics from before the closure",
+		}),
+	}
+	reg.MustRegister(c.system_closed)
+
+	return c
+}
+
+// / Return whether the system is closed at now, logging when it closes and
+// / opens again. Feeds that fail to fetch leave the system open.
+func (c *Closure) Check(ctx context.Context, client *gbfs.Client, now time.Time) bool {
+	reason := c.closed(ctx, client, now)
+	if reason != "" && c.reason == "" {
+		log.Printf("System closed, %s; pausing status sampling\n", reason)
+	} else if reason == "" && c.reason != "" {
+		log.Println("System open again, resuming status sampling")
+	}
+	c.reason = reason
+	if reason != "" {
+		c.system_closed.Set(1)
+	} else {
+		c.system_closed.Set(0)
+	}
+	return reason != ""
+}
+
+// / Return why the system is closed at now, or "" if it is open.
+func (c *Closure) closed(ctx context.Context, client *gbfs.Client, now time.Time) string {
+	local := now
+	if c.Location != nil {
+		local = now.In(c.Location)
+	}
+	for _, window := range c.Windows {
+		if window.contains(local) {
+			return "in a quiet window"
+		}
+	}
+
+	if c.Alerts {
+		response, err := client.SystemAlerts(ctx)
+		if err != nil {
+			log.Printf("Error fetching system alerts %s\n", err)
+		} else if alert := closureAlert(response.Data.Alerts, now); alert != nil {
+			return fmt.Sprintf("alert %s %q", alert.AlertId, alert.Summary)
+		}
+	}
+
+	if c.Hours {
+		if c.system == nil {
+			c.system = time.Local
+			response, err := client.SystemInformation(ctx)
+			if err != nil {
+				log.Printf("Error fetching system information %s\n", err)
+			} else if location, err := time.LoadLocation(response.Data.Timezone); err == nil {
+				c.system = location
+			}
+		}
+		response, err := client.SystemHours(ctx)
+		if err != nil {
+			log.Printf("Error fetching system hours %s\n", err)
+		} else if hours := response.Data.RentalHours; len(hours) > 0 && !renting(hours, now.In(c.system)) {
+			return "outside its rental hours"
+		}
+	}
+	return ""
+}
+
+// / Return the system_closure alert in effect at now, if any. Alerts without
+// / times are in effect until they are removed.
+func closureAlert(alerts []gbfs.Alert, now time.Time) *gbfs.Alert {
+	for i, alert := range alerts {
+		if alert.Type != "system_closure" {
+			continue
+		}
+		if len(alert.Times) == 0 {
+			return &alerts[i]
+		}
+		for _, t := range alert.Times {
+			if !now.Before(t.Start.Time) && (t.End.IsZero() || now.Before(t.End.Time)) {
+				return &alerts[i]
+			}
+		}
+	}
+	return nil
+}
+
+// / Return whether any of the rental hours include t, in the system's time
+// / zone. End times past 24:00:00 run into the next day.
+func renting(hours []gbfs.RentalHour, t time.Time) bool {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	for _, hour := range hours {
+		start, err := parseRentalTime(hour.StartTime)
+		if err != nil {
+			continue
+		}
+		end, err := parseRentalTime(hour.EndTime)
+		if err != nil {
+			continue
+		}
+		for _, day := range hour.Days {
+			weekday, ok := weekdays[strings.ToLower(day)[:min(3, len(day))]]
+			if !ok {
+				continue
+			}
+			// rental hours starting today or yesterday may include t
+			for _, daysAgo := range []int{0, 1} {
+				date := midnight.AddDate(0, 0, -daysAgo)
+				if date.Weekday() != weekday {
+					continue
+				}
+				if !t.Before(date.Add(start)) && t.Before(date.Add(end)) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// / Parse a GBFS HH:MM:SS time, which may exceed 24 hours.
+func parseRentalTime(v string) (time.Duration, error) {
+	parts := strings.Split(v, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("expected HH:MM:SS, got %q", v)
+	}
+	var d time.Duration
+	for i, unit := range []time.Duration{time.Hour, time.Minute, time.Second} {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil {
+			return 0, fmt.Errorf("expected HH:MM:SS, got %q", v)
+		}
+		d += time.Duration(n) * unit
+	}
+	return d, nil
+}
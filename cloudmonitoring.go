@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// / Time series per timeSeries.create request, the API's limit.
+const cloudMonitoringBatch = 200
+
+type CloudMonitoringConfig struct {
+	// Project to write to; the project of the default credentials if
+	// empty.
+	Project string
+	// URL of the Monitoring API.
+	URL string
+	// Prefix of the metric types, e.g. custom.googleapis.com/baywheels.
+	Prefix string
+	// Location and TaskID identify the exporter in the generic_task
+	// monitored resource, so replicas don't write to the same series.
+	// TaskID defaults to the hostname.
+	Location string
+	TaskID   string
+	// Labels are added to every series.
+	Labels map[string]string
+	// Namespace of the exporter's metrics, left out of metric types.
+	Namespace string
+}
+
+// / CloudMonitoring is a Sink writing each cycle's samples to Google Cloud
+// / Monitoring as custom metrics, for GCP deployments that alert there. The
+// / metric descriptors are created as metrics are first seen, or gain
+// / labels, with their kind and help text, rather than left to be inferred
+// / from the first write. Counters, and the _sum, _count and _bucket series
+// / of histograms and summaries, are cumulative from when the exporter
+// / started; everything else is a gauge.
+type CloudMonitoring struct {
+	config CloudMonitoringConfig
+	client *http.Client
+	start  time.Time
+	// label keys of the descriptors created so far, by metric type
+	descriptors map[string]map[string]bool
+}
+
+func NewCloudMonitoring(ctx context.Context, config CloudMonitoringConfig) (*CloudMonitoring, error) {
+	creds, err := google.FindDefaultCredentials(ctx, "https://www.googleapis.com/auth/monitoring")
+	if err != nil {
+		return nil, err
+	}
+	if config.Project == "" {
+		config.Project = creds.ProjectID
+	}
+	if config.Project == "" {
+		return nil, errors.New("no project in the default credentials, set -cloud-monitoring.project")
+	}
+	if config.TaskID == "" {
+		if config.TaskID, err = os.Hostname(); err != nil {
+			return nil, err
+		}
+	}
+	client := oauth2.NewClient(ctx, creds.TokenSource)
+	client.Timeout = 30 * time.Second
+	return &CloudMonitoring{
+		config:      config,
+		client:      client,
+		start:       time.Now(),
+		descriptors: make(map[string]map[string]bool),
+	}, nil
+}
+
+func (c *CloudMonitoring) Name() string {
+	return "cloudmonitoring"
+}
+
+type gcmLabeled struct {
+	Type   string            `json:"type"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+type gcmPoint struct {
+	Interval struct {
+		StartTime string `json:"startTime,omitempty"`
+		EndTime   string `json:"endTime"`
+	} `json:"interval"`
+	Value struct {
+		DoubleValue float64 `json:"doubleValue"`
+	} `json:"value"`
+}
+
+type gcmTimeSeries struct {
+	Metric     gcmLabeled `json:"metric"`
+	Resource   gcmLabeled `json:"resource"`
+	MetricKind string     `json:"metricKind"`
+	ValueType  string     `json:"valueType"`
+	Points     []gcmPoint `json:"points"`
+}
+
+type gcmLabelDescriptor struct {
+	Key       string `json:"key"`
+	ValueType string `json:"valueType"`
+}
+
+type gcmMetricDescriptor struct {
+	Type        string               `json:"type"`
+	MetricKind  string               `json:"metricKind"`
+	ValueType   string               `json:"valueType"`
+	Description string               `json:"description,omitempty"`
+	DisplayName string               `json:"displayName"`
+	Labels      []gcmLabelDescriptor `json:"labels,omitempty"`
+}
+
+func (c *CloudMonitoring) Send(ctx context.Context, cycle *Cycle) error {
+	resource := gcmLabeled{
+		Type: "generic_task",
+		Labels: map[string]string{
+			"project_id": c.config.Project,
+			"location":   c.config.Location,
+			"namespace":  "baywheels",
+			"job":        "baywheels-exporter",
+			"task_id":    c.config.TaskID,
+		},
+	}
+	start := c.start.UTC().Format(time.RFC3339Nano)
+
+	var series []gcmTimeSeries
+	descriptors := make(map[string]*gcmMetricDescriptor)
+	keys := make(map[string]map[string]bool)
+	for _, family := range cycle.Families {
+		if isExporterMetric(family.GetName()) {
+			continue
+		}
+		for _, sample := range flattenFamilies([]*dto.MetricFamily{family}, cycle.Time) {
+			// JSON can't represent NaN or infinite values
+			if math.IsNaN(sample.Value) || math.IsInf(sample.Value, 0) {
+				continue
+			}
+			kind := "GAUGE"
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				kind = "CUMULATIVE"
+			case dto.MetricType_HISTOGRAM, dto.MetricType_SUMMARY:
+				if sample.Name != family.GetName() {
+					kind = "CUMULATIVE"
+				}
+			}
+			metricType := c.config.Prefix + "/" + trimNamespace(sample.Name, c.config.Namespace)
+			labels := make(map[string]string, len(sample.Labels)+len(c.config.Labels))
+			for name, value := range c.config.Labels {
+				labels[name] = value
+			}
+			for _, l := range sample.Labels {
+				if l.Value != "" {
+					labels[l.Name] = l.Value
+				}
+			}
+
+			if descriptors[metricType] == nil {
+				descriptors[metricType] = &gcmMetricDescriptor{
+					Type:        metricType,
+					MetricKind:  kind,
+					ValueType:   "DOUBLE",
+					Description: family.GetHelp(),
+					DisplayName: sample.Name,
+				}
+				keys[metricType] = make(map[string]bool)
+			}
+			for name := range labels {
+				keys[metricType][name] = true
+			}
+
+			point := gcmPoint{}
+			point.Interval.EndTime = sample.Timestamp.UTC().Format(time.RFC3339Nano)
+			if kind == "CUMULATIVE" {
+				point.Interval.StartTime = start
+			}
+			point.Value.DoubleValue = sample.Value
+			series = append(series, gcmTimeSeries{
+				Metric:     gcmLabeled{Type: metricType, Labels: labels},
+				Resource:   resource,
+				MetricKind: kind,
+				ValueType:  "DOUBLE",
+				Points:     []gcmPoint{point},
+			})
+		}
+	}
+
+	for metricType, descriptor := range descriptors {
+		if err := c.describe(ctx, descriptor, keys[metricType]); err != nil {
+			return fmt.Errorf("creating descriptor %s: %w", metricType, err)
+		}
+	}
+	for start := 0; start < len(series); start += cloudMonitoringBatch {
+		batch := map[string]any{"timeSeries": series[start:min(start+cloudMonitoringBatch, len(series))]}
+		if err := c.post(ctx, "/timeSeries", batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// / Create the metric descriptor unless one with all of keys has already
+// / been created.
+func (c *CloudMonitoring) describe(ctx context.Context, descriptor *gcmMetricDescriptor, keys map[string]bool) error {
+	created := c.descriptors[descriptor.Type]
+	missing := created == nil
+	for key := range keys {
+		if !created[key] {
+			missing = true
+		}
+	}
+	if !missing {
+		return nil
+	}
+	// keep labels the descriptor was created with before, so series
+	// without them this cycle don't drop them from it
+	union := make(map[string]bool, len(keys)+len(created))
+	for key := range created {
+		union[key] = true
+	}
+	for key := range keys {
+		union[key] = true
+	}
+	for key := range union {
+		descriptor.Labels = append(descriptor.Labels, gcmLabelDescriptor{Key: key, ValueType: "STRING"})
+	}
+	sort.Slice(descriptor.Labels, func(i, j int) bool { return descriptor.Labels[i].Key < descriptor.Labels[j].Key })
+
+	if err := c.post(ctx, "/metricDescriptors", descriptor); err != nil {
+		return err
+	}
+	c.descriptors[descriptor.Type] = union
+	return nil
+}
+
+func (c *CloudMonitoring) post(ctx context.Context, path string, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	endpoint := strings.TrimRight(c.config.URL, "/") + "/v3/projects/" + c.config.Project + path
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("server returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// / Datums per PutMetricData request, CloudWatch's limit.
+const cloudWatchBatch = 1000
+
+// / The system totals published to CloudWatch. Watched stations publish
+// / BikesAvailable, EBikesAvailable and DocksAvailable.
+var cloudWatchMetrics = []string{
+	"BikesAvailable", "EBikesAvailable", "BikesDisabled", "DocksAvailable",
+	"DocksDisabled", "StationsEmpty", "StationsFull", "StationsOffline",
+	"FreeBikesAvailable",
+}
+
+type CloudWatchConfig struct {
+	// Region and credentials default to the usual AWS environment
+	// variables, shared config files and instance role.
+	Region string
+	// Endpoint overrides the CloudWatch endpoint, e.g. for LocalStack.
+	Endpoint  string
+	Namespace string
+	// Dimensions are added to every datum as name=value.
+	Dimensions map[string]string
+	// Stations are the IDs of the watched stations published individually
+	// with a StationId dimension.
+	Stations []string
+	// Metrics are the names of the metrics to publish; all of them if
+	// empty.
+	Metrics []string
+}
+
+// / CloudWatch is a Sink publishing system totals and a few watched
+// / stations to CloudWatch, so users on AWS without Prometheus can alarm on
+// / them. Publishing every station would cost a custom metric each, so only
+// / the aggregates are sent.
+type CloudWatch struct {
+	config CloudWatchConfig
+	client *cloudwatch.Client
+}
+
+func NewCloudWatch(ctx context.Context, cfg CloudWatchConfig) (*CloudWatch, error) {
+	for _, name := range cfg.Metrics {
+		if !slices.Contains(cloudWatchMetrics, name) {
+			return nil, fmt.Errorf("unknown metric %q, expected one of %v", name, cloudWatchMetrics)
+		}
+	}
+	var opts []func(*config.LoadOptions) error
+	if cfg.Region != "" {
+		opts = append(opts, config.WithRegion(cfg.Region))
+	}
+	awsConfig, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	client := cloudwatch.NewFromConfig(awsConfig, func(o *cloudwatch.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = &cfg.Endpoint
+		}
+	})
+	return &CloudWatch{config: cfg, client: client}, nil
+}
+
+func (c *CloudWatch) Name() string {
+	return "cloudwatch"
+}
+
+// / Return whether the metric is selected for publishing.
+func (c *CloudWatch) selected(name string) bool {
+	return len(c.config.Metrics) == 0 || slices.Contains(c.config.Metrics, name)
+}
+
+func (c *CloudWatch) Send(ctx context.Context, cycle *Cycle) error {
+	dimensions := make([]types.Dimension, 0, len(c.config.Dimensions))
+	for name, value := range c.config.Dimensions {
+		dimensions = append(dimensions, types.Dimension{Name: aws.String(name), Value: aws.String(value)})
+	}
+	sort.Slice(dimensions, func(i, j int) bool { return *dimensions[i].Name < *dimensions[j].Name })
+
+	var data []types.MetricDatum
+	add := func(name string, value int, dimensions []types.Dimension) {
+		if !c.selected(name) {
+			return
+		}
+		data = append(data, types.MetricDatum{
+			MetricName: aws.String(name),
+			Dimensions: dimensions,
+			Timestamp:  aws.Time(cycle.Time),
+			Value:      aws.Float64(float64(value)),
+			Unit:       types.StandardUnitCount,
+		})
+	}
+
+	totals := make(map[string]int, len(cloudWatchMetrics))
+	for _, station := range cycle.Snapshot.Stations {
+		status := station.Status
+		if status == nil {
+			continue
+		}
+		totals["BikesAvailable"] += status.BikesAvailable
+		totals["EBikesAvailable"] += status.EBikesAvailable
+		totals["BikesDisabled"] += status.BikesDisabled
+		totals["DocksAvailable"] += status.DocksAvailable
+		totals["DocksDisabled"] += status.DocksDisabled
+		if status.IsInstalled == 0 || status.IsRenting == 0 {
+			totals["StationsOffline"]++
+			continue
+		}
+		if status.BikesAvailable == 0 {
+			totals["StationsEmpty"]++
+		}
+		if status.DocksAvailable == 0 {
+			totals["StationsFull"]++
+		}
+	}
+	for _, bike := range cycle.Snapshot.Bikes {
+		if bike.IsDisabled == 0 && bike.IsReserved == 0 {
+			totals["FreeBikesAvailable"]++
+		}
+	}
+	for _, name := range cloudWatchMetrics {
+		add(name, totals[name], dimensions)
+	}
+
+	for _, id := range c.config.Stations {
+		station, ok := cycle.Snapshot.Station(id)
+		if !ok || station.Status == nil {
+			continue
+		}
+		dimensions := append(slices.Clip(dimensions), types.Dimension{Name: aws.String("StationId"), Value: aws.String(id)})
+		add("BikesAvailable", station.Status.BikesAvailable, dimensions)
+		add("EBikesAvailable", station.Status.EBikesAvailable, dimensions)
+		add("DocksAvailable", station.Status.DocksAvailable, dimensions)
+	}
+
+	for start := 0; start < len(data); start += cloudWatchBatch {
+		_, err := c.client.PutMetricData(ctx, &cloudwatch.PutMetricDataInput{
+			Namespace:  aws.String(c.config.Namespace),
+			MetricData: data[start:min(start+cloudWatchBatch, len(data))],
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
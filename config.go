@@ -0,0 +1,28 @@
+package main
+
+import (
+	"os"
+
+	"go.yaml.in/yaml/v2"
+)
+
+// / Config is the optional YAML configuration file given by -config.file,
+// / holding the settings that don't fit on the command line.
+type Config struct {
+	Webhooks  []WebhookConfig  `yaml:"webhooks"`
+	Notifiers []NotifierConfig `yaml:"notifiers"`
+	POIs      []POIConfig      `yaml:"pois"`
+	Corridors []CorridorConfig `yaml:"corridors"`
+}
+
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config Config
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/patrickod/baywheels-exporter/pkg/gbfs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// / ConformanceRule is one of the checks of the official GBFS validator run
+// / against the fetched feeds.
+type ConformanceRule struct {
+	Id          string
+	Feed        string
+	Description string
+}
+
+// / conformanceRules are the schema checks of Validate and the validator's
+// / rules that span the feeds the exporter fetches. The validator's rules
+// / for pricing plans and regions aren't checked, as those feeds aren't.
+var conformanceRules = []ConformanceRule{
+	{"schema", "station_information", "Required fields, types and value ranges of the feed's schema"},
+	{"schema", "station_status", "Required fields, types and value ranges of the feed's schema"},
+	{"schema", "free_bike_status", "Required fields, types and value ranges of the feed's schema"},
+	{"schema", "vehicle_types", "Required fields, types and value ranges of the feed's schema"},
+	{"missing_vehicle_types_available", "station_status", "Stations must list vehicle_types_available when the system publishes vehicle_types"},
+	{"invalid_vehicle_type_reference", "station_status", "vehicle_types_available must only reference vehicle types in vehicle_types"},
+	{"missing_vehicle_type_id", "free_bike_status", "Vehicles must have a vehicle_type_id of vehicle_types when the system publishes it"},
+	{"missing_current_range_meters", "free_bike_status", "Motorized vehicles must report current_range_meters"},
+}
+
+// / Number of failing stations or vehicles listed per rule on /conformance.
+const conformanceExamples = 10
+
+// / ConformanceFailure is the outcome of a rule against the latest feeds:
+// / how many stations, vehicles or fields failed it, and some of them.
+// / Checked is false until the rule is first checked, and stays false for
+// / the schema rules unless feeds are validated.
+type ConformanceFailure struct {
+	ConformanceRule
+	Checked  bool
+	Count    int
+	Examples []string
+}
+
+// / Conformance continuously checks the fetched feeds against a subset of
+// / the official GBFS validator's rules, so upstream data quality
+// / regressions show up in metrics and on /conformance rather than as
+// / puzzling gaps in the data. Failures are counted in the most recent
+// / payload of each feed, so they drop back to zero once fixed.
+type Conformance struct {
+	mu       sync.Mutex
+	failures map[[2]string]*ConformanceFailure
+	checked  time.Time
+
+	conformance_failures *prometheus.GaugeVec
+}
+
+func NewConformance(reg prometheus.Registerer) *Conformance {
+	c := &Conformance{
+		failures: make(map[[2]string]*ConformanceFailure),
+		conformance_failures: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "baywheels_exporter_gbfs_conformance_failures",
+			Help: "Number of stations, vehicles or fields failing a GBFS validator rule in the latest payload of the feed",
+		},
+			[]string{"feed", "rule"},
+		),
+	}
+	for _, rule := range conformanceRules {
+		c.failures[[2]string{rule.Feed, rule.Id}] = &ConformanceFailure{ConformanceRule: rule}
+		// the schema rules are only exported once feeds are validated
+		if rule.Id != "schema" {
+			c.conformance_failures.WithLabelValues(rule.Feed, rule.Id)
+		}
+	}
+	reg.MustRegister(c.conformance_failures)
+
+	return c
+}
+
+// / Reset the failures of a rule and record those of the latest payload.
+func (c *Conformance) record(feed, rule string, failing []string) {
+	failure := c.failures[[2]string{feed, rule}]
+	if failure == nil {
+		return
+	}
+	failure.Checked = true
+	failure.Count = len(failing)
+	failure.Examples = failing[:min(len(failing), conformanceExamples)]
+	c.conformance_failures.WithLabelValues(feed, rule).Set(float64(len(failing)))
+}
+
+// / Record the schema problems of a fetched feed, for use as a Client's
+// / OnProblems.
+func (c *Conformance) RecordProblems(feed string, problems []gbfs.Problem) {
+	failing := make([]string, len(problems))
+	for i, problem := range problems {
+		failing[i] = problem.String()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.record(feed, "schema", failing)
+}
+
+// / Check the rules spanning feeds against the latest snapshot and vehicle
+// / types. Rules that only apply to systems publishing vehicle_types pass
+// / for those that don't.
+func (c *Conformance) Observe(snapshot *Snapshot, types []gbfs.VehicleType) {
+	known := make(map[string]gbfs.VehicleType, len(types))
+	for _, t := range types {
+		known[t.VehicleTypeId] = t
+	}
+
+	var missingAvailable, invalidReference []string
+	for _, station := range snapshot.Stations {
+		if station.Status == nil || len(types) == 0 {
+			continue
+		}
+		if station.Status.VehicleTypesAvailable == nil {
+			missingAvailable = append(missingAvailable, station.StationId)
+		}
+		for _, count := range station.Status.VehicleTypesAvailable {
+			if _, ok := known[count.VehicleTypeId]; !ok {
+				invalidReference = append(invalidReference, station.StationId+" "+count.VehicleTypeId)
+			}
+		}
+	}
+
+	var missingType, missingRange []string
+	for _, bike := range snapshot.Bikes {
+		t, ok := known[bike.VehicleTypeId]
+		if len(types) > 0 && !ok {
+			missingType = append(missingType, bike.BikeId)
+		}
+		if ok && t.PropulsionType != "human" && bike.CurrentRangeMeters == nil {
+			missingRange = append(missingRange, bike.BikeId)
+		}
+	}
+	for _, failing := range [][]string{missingAvailable, invalidReference, missingType, missingRange} {
+		sort.Strings(failing)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.record("station_status", "missing_vehicle_types_available", missingAvailable)
+	c.record("station_status", "invalid_vehicle_type_reference", invalidReference)
+	c.record("free_bike_status", "missing_vehicle_type_id", missingType)
+	c.record("free_bike_status", "missing_current_range_meters", missingRange)
+	c.checked = snapshot.Time
+}
+
+var conformanceTemplate = template.Must(template.New("conformance").Funcs(templateFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>GBFS conformance</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { text-align: left; padding: 0.25em 1em 0.25em 0; vertical-align: top; }
+.fail { color: #b00; }
+.pass { color: #080; }
+</style>
+</head>
+<body>
+<h1>GBFS conformance</h1>
+<p>{{ .URL }} checked {{ ago .Checked }} against a subset of the rules of the <a href="https://github.com/MobilityData/gbfs-validator">GBFS validator</a>.</p>
+<table>
+<tr><th>Feed</th><th>Rule</th><th>Description</th><th>Failures</th><th>Examples</th></tr>
+{{- range .Failures }}
+<tr>
+<td>{{ .Feed }}</td>
+<td>{{ .Id }}</td>
+<td>{{ .Description }}</td>
+{{- if not .Checked }}
+<td>not checked</td>
+<td>{{ if eq .Id "schema" }}set -validate to check{{ end }}</td>
+{{- else if .Count }}
+<td class="fail">{{ .Count }}</td>
+<td>{{ range .Examples }}{{ . }}<br>{{ end }}</td>
+{{- else }}
+<td class="pass">0</td>
+<td></td>
+{{- end }}
+</tr>
+{{- end }}
+</table>
+</body>
+</html>
+`))
+
+// / Serve a human readable report of the latest failures of every rule.
+func handleConformance(exporter *Exporter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := exporter.conformance
+		c.mu.Lock()
+		data := struct {
+			URL      string
+			Checked  time.Time
+			Failures []ConformanceFailure
+		}{URL: exporter.URL, Checked: c.checked}
+		for _, rule := range conformanceRules {
+			data.Failures = append(data.Failures, *c.failures[[2]string{rule.Feed, rule.Id}])
+		}
+		c.mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := conformanceTemplate.Execute(w, data); err != nil {
+			log.Printf("Error rendering conformance report %s\n", err)
+		}
+	})
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+type ConsulConfig struct {
+	// URL of the local Consul agent, e.g. http://localhost:8500.
+	URL   string
+	Token string
+	// Service is the service name, and with the port the service ID.
+	Service string
+	// Address advertised for the service; the agent's if empty.
+	Address string
+	// Labels are added to the service as name=value tags and as metadata,
+	// along with the GBFS system's.
+	Labels map[string]string
+}
+
+// / Consul registers the exporter as a service with the local Consul agent,
+// / with a TCP health check, and deregisters it on shutdown, for Prometheus
+// / servers using consul_sd_configs.
+type Consul struct {
+	config ConsulConfig
+	api    *consulAPI
+	port   int
+	id     string
+}
+
+func NewConsul(config ConsulConfig, listen string) (*Consul, error) {
+	_, portString, err := net.SplitHostPort(listen)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portString)
+	if err != nil {
+		return nil, fmt.Errorf("listen address %q without a numeric port", listen)
+	}
+	return &Consul{
+		config: config,
+		api:    newConsulAPI(config),
+		port:   port,
+		id:     fmt.Sprintf("%s-%d", config.Service, port),
+	}, nil
+}
+
+// / Register the service, retrying every minute until it succeeds, and
+// / deregister it once ctx is cancelled.
+func (c *Consul) Run(ctx context.Context, exporter *Exporter) {
+	for {
+		err := c.register(ctx, exporter)
+		if err == nil {
+			break
+		}
+		log.Printf("Error registering with Consul %s\n", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Minute):
+		}
+	}
+
+	<-ctx.Done()
+	// ctx is done, so deregister with a fresh one
+	deregisterCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.api.put(deregisterCtx, "/v1/agent/service/deregister/"+url.PathEscape(c.id), nil, nil); err != nil {
+		log.Printf("Error deregistering from Consul %s\n", err)
+	}
+}
+
+func (c *Consul) register(ctx context.Context, exporter *Exporter) error {
+	meta := map[string]string{"gbfs_url": exporter.URL}
+	if version := exporter.client.Version(); version != "" {
+		meta["gbfs_version"] = version
+	}
+	for name, value := range c.config.Labels {
+		meta[name] = value
+	}
+	tags := make([]string, 0, len(meta))
+	for name, value := range meta {
+		tags = append(tags, name+"="+value)
+	}
+	sort.Strings(tags)
+
+	checkAddress := c.config.Address
+	if checkAddress == "" {
+		checkAddress = "localhost"
+	}
+	return c.api.put(ctx, "/v1/agent/service/register", map[string]any{
+		"ID":      c.id,
+		"Name":    c.config.Service,
+		"Address": c.config.Address,
+		"Port":    c.port,
+		"Tags":    tags,
+		"Meta":    meta,
+		"Check": map[string]string{
+			"TCP":      net.JoinHostPort(checkAddress, strconv.Itoa(c.port)),
+			"Interval": "30s",
+			// clean up after exporters that died without deregistering
+			"DeregisterCriticalServiceAfter": "10m",
+		},
+	}, nil)
+}
+
+// / consulAPI calls the HTTP API of a Consul agent.
+type consulAPI struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func newConsulAPI(config ConsulConfig) *consulAPI {
+	return &consulAPI{
+		url:    config.URL,
+		token:  config.Token,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// / PUT v as JSON to path, decoding the response into out unless it is nil.
+func (c *consulAPI) put(ctx context.Context, path string, v any, out any) error {
+	var body []byte
+	if v != nil {
+		var err error
+		if body, err = json.Marshal(v); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.url+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if c.token != "" {
+		req.Header.Set("X-Consul-Token", c.token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
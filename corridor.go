@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// / CorridorConfig names a pair of stations to observe the flow between, such
+// / as a commute from the Ferry Building to Caltrain.
+type CorridorConfig struct {
+	Name string `yaml:"name"`
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+}
+
+// / CorridorMetrics estimates the flow of bikes along each of Corridors from
+// / the trip estimates of its stations. Individual trips aren't visible in
+// / GBFS, so the flow from one station to the other is bounded by the
+// / departures at the first and the arrivals at the second: a corridor is
+// / only busy when both ends are.
+type CorridorMetrics struct {
+	Corridors []CorridorConfig
+
+	corridor_flow_rate prometheus.GaugeVec
+}
+
+func NewCorridorMetrics(reg prometheus.Registerer) *CorridorMetrics {
+	m := &CorridorMetrics{
+		corridor_flow_rate: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "corridor_flow_bikes_per_hour",
+			Help: "Upper bound of the bikes per hour travelling the corridor, the lesser of the departures at its origin and the arrivals at its destination over the flux window",
+		},
+			[]string{"corridor", "direction"},
+		),
+	}
+	reg.MustRegister(m.corridor_flow_rate)
+
+	return m
+}
+
+func validateCorridors(corridors []CorridorConfig) error {
+	for _, corridor := range corridors {
+		if corridor.Name == "" {
+			return errors.New("corridor without a name")
+		}
+		if corridor.From == "" || corridor.To == "" {
+			return fmt.Errorf("corridor %s needs both from and to stations", corridor.Name)
+		}
+	}
+	return nil
+}
+
+// / Update the flow of every corridor in both directions from the rates
+// / computed by the latest trips.Observe.
+func (m *CorridorMetrics) Observe(trips *TripMetrics) {
+	for _, corridor := range m.Corridors {
+		from, okFrom := trips.rates[corridor.From]
+		to, okTo := trips.rates[corridor.To]
+		if !okFrom || !okTo {
+			continue
+		}
+		m.corridor_flow_rate.With(prometheus.Labels{"corridor": corridor.Name, "direction": "forward"}).Set(min(from.outflow, to.inflow))
+		m.corridor_flow_rate.With(prometheus.Labels{"corridor": corridor.Name, "direction": "reverse"}).Set(min(to.outflow, from.inflow))
+	}
+}
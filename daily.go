@@ -0,0 +1,81 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type dailyRange struct {
+	day      string
+	min, max int
+}
+
+// / DailyMetrics tracks the minimum and maximum bikes available at each
+// / station since the start of the day, which begins at Reset past midnight
+// / in Location, so capacity planning doesn't need min_over_time queries
+// / over a day of samples.
+type DailyMetrics struct {
+	Location *time.Location
+	// Reset is the time of day the minimum and maximum start over, e.g. 4h
+	// to count the late night trips towards the previous day.
+	Reset time.Duration
+
+	station_bikes_available_daily_min prometheus.GaugeVec
+	station_bikes_available_daily_max prometheus.GaugeVec
+
+	ranges map[string]*dailyRange
+}
+
+func NewDailyMetrics(reg prometheus.Registerer) *DailyMetrics {
+	m := &DailyMetrics{
+		Location: time.Local,
+		station_bikes_available_daily_min: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "station_bikes_available_daily_min",
+			Help: "Minimum number of bikes available at the station today",
+		},
+			[]string{"station_id", "name"},
+		),
+		station_bikes_available_daily_max: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "station_bikes_available_daily_max",
+			Help: "Maximum number of bikes available at the station today",
+		},
+			[]string{"station_id", "name"},
+		),
+		ranges: make(map[string]*dailyRange),
+	}
+	reg.MustRegister(m.station_bikes_available_daily_min)
+	reg.MustRegister(m.station_bikes_available_daily_max)
+
+	return m
+}
+
+func (m *DailyMetrics) Observe(snapshot *Snapshot) {
+	day := snapshot.Time.In(m.Location).Add(-m.Reset).Format(time.DateOnly)
+	for _, station := range snapshot.Stations {
+		if station.Status == nil {
+			continue
+		}
+		bikes := station.Status.BikesAvailable
+		r, ok := m.ranges[station.StationId]
+		if !ok || r.day != day {
+			r = &dailyRange{day: day, min: bikes, max: bikes}
+			m.ranges[station.StationId] = r
+		}
+		r.min = min(r.min, bikes)
+		r.max = max(r.max, bikes)
+
+		labels := stationLabels(station.StationId, string(station.Name))
+		m.station_bikes_available_daily_min.With(labels).Set(float64(r.min))
+		m.station_bikes_available_daily_max.With(labels).Set(float64(r.max))
+	}
+}
+
+// / Parse a time of day as HH:MM into the duration since midnight.
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
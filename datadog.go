@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// / Series per request to the Datadog series endpoint, well below its 5MB
+// / payload limit.
+const datadogBatch = 1000
+
+type DatadogConfig struct {
+	// Site is the Datadog site to submit to, e.g. datadoghq.com or
+	// datadoghq.eu, or the URL of a proxy.
+	Site   string
+	APIKey string
+	// APIKeyFile is read for the API key if APIKey is empty.
+	APIKeyFile string
+	Prefix     string
+	// Tags are added to every series as name:value.
+	Tags map[string]string
+	// Namespace of the exporter's metrics, left out of Datadog names.
+	Namespace string
+}
+
+// / Datadog is a Sink submitting each cycle's samples to the Datadog series
+// / API as gauges tagged with their labels, for teams whose observability
+// / stack is Datadog rather than Prometheus. Counters are submitted as
+// / gauges of their running total.
+type Datadog struct {
+	config DatadogConfig
+	client *http.Client
+}
+
+// / Create a Datadog sink, taking the API key from the config, else the
+// / key file, else DD_API_KEY.
+func NewDatadog(config DatadogConfig) (*Datadog, error) {
+	if config.APIKey == "" && config.APIKeyFile != "" {
+		key, err := os.ReadFile(config.APIKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.APIKey = strings.TrimSpace(string(key))
+	}
+	if config.APIKey == "" {
+		config.APIKey = os.Getenv("DD_API_KEY")
+	}
+	if config.APIKey == "" {
+		return nil, errors.New("no API key, set -datadog.api-key-file or DD_API_KEY")
+	}
+	return &Datadog{config: config, client: &http.Client{Timeout: 30 * time.Second}}, nil
+}
+
+func (d *Datadog) Name() string {
+	return "datadog"
+}
+
+type datadogPoint struct {
+	Timestamp int64   `json:"timestamp"`
+	Value     float64 `json:"value"`
+}
+
+type datadogSeries struct {
+	Metric string         `json:"metric"`
+	Type   int            `json:"type"`
+	Points []datadogPoint `json:"points"`
+	Tags   []string       `json:"tags,omitempty"`
+}
+
+// / the series API's gauge type
+const datadogGauge = 3
+
+func (d *Datadog) Send(ctx context.Context, cycle *Cycle) error {
+	extra := make([]string, 0, len(d.config.Tags))
+	for name, value := range d.config.Tags {
+		extra = append(extra, name+":"+value)
+	}
+	sort.Strings(extra)
+
+	var series []datadogSeries
+	for _, sample := range flattenFamilies(cycle.Families, cycle.Time) {
+		// JSON can't represent NaN or infinite values
+		if isExporterMetric(sample.Name) || math.IsNaN(sample.Value) || math.IsInf(sample.Value, 0) {
+			continue
+		}
+		tags := make([]string, 0, len(sample.Labels)+len(extra))
+		for _, l := range sample.Labels {
+			if l.Value != "" {
+				tags = append(tags, l.Name+":"+l.Value)
+			}
+		}
+		series = append(series, datadogSeries{
+			Metric: d.config.Prefix + trimNamespace(sample.Name, d.config.Namespace),
+			Type:   datadogGauge,
+			Points: []datadogPoint{{Timestamp: sample.Timestamp.Unix(), Value: sample.Value}},
+			Tags:   append(tags, extra...),
+		})
+	}
+
+	for start := 0; start < len(series); start += datadogBatch {
+		if err := d.submit(ctx, series[start:min(start+datadogBatch, len(series))]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Datadog) submit(ctx context.Context, series []datadogSeries) error {
+	body, err := json.Marshal(map[string]any{"series": series})
+	if err != nil {
+		return err
+	}
+	base := "https://api." + d.config.Site
+	if strings.Contains(d.config.Site, "://") {
+		base = strings.TrimRight(d.config.Site, "/")
+	}
+	endpoint := base + "/api/v2/series"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("DD-API-KEY", d.config.APIKey)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("server returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"expvar"
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+)
+
+// / Build the mux for the debug server. The pprof handlers are mounted
+// / explicitly so they are only reachable on the debug listen address.
+func newDebugMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/memstats", handleMemStats)
+	mux.HandleFunc("/debug/gc", handleGC)
+	mux.Handle("/debug/vars", expvar.Handler())
+	return mux
+}
+
+// / Dump the current runtime.MemStats as JSON.
+func handleMemStats(w http.ResponseWriter, r *http.Request) {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// / Force a garbage collection and return as much memory to the OS as
+// / possible, so heap profiles taken afterwards only show live objects.
+func handleGC(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	debug.FreeOSMemory()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func serveDebug(listen string) {
+	log.Printf("Debug server listening on %s\n", listen)
+	log.Fatal(http.ListenAndServe(listen, newDebugMux()))
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/patrickod/baywheels-exporter/pkg/gbfs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// / Run `dump`, fetching a single feed and writing it to stdout flattened
+// / into one row per station or bike, e.g. for loading into a spreadsheet or
+// / generating test fixtures.
+func runDump(args []string) error {
+	fs := flag.NewFlagSet("dump", flag.ExitOnError)
+	gbfsURL := fs.String("gbfs.url", BaywheelsURI, "Base URL of the GBFS feeds to fetch, or the URL of the system's gbfs.json")
+	feed := fs.String("feed", "station_status", "Feed to dump, e.g. station_information, station_status or free_bike_status")
+	format := fs.String("format", "csv", "Output format, either csv or jsonl")
+	var upstream UpstreamAuth
+	upstream.Flags(fs)
+	fs.Parse(args)
+
+	client, err := upstream.Client(prometheus.NewRegistry())
+	if err != nil {
+		return err
+	}
+
+	var response struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := gbfs.NewClient(*gbfsURL, client).Fetch(context.Background(), *feed, &response); err != nil {
+		return err
+	}
+	rows, err := feedRows(response.Data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", *feed, err)
+	}
+
+	switch *format {
+	case "csv":
+		return writeCSV(rows)
+	case "jsonl":
+		enc := json.NewEncoder(os.Stdout)
+		for _, row := range rows {
+			if err := enc.Encode(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+}
+
+// / Find the list of stations, bikes or other records in a feed's data. Every
+// / list feed has exactly one array in data, so the name doesn't need to be
+// / known up front.
+func feedRows(data map[string]json.RawMessage) ([]map[string]any, error) {
+	for _, raw := range data {
+		var rows []map[string]any
+		if json.Unmarshal(raw, &rows) == nil {
+			return rows, nil
+		}
+	}
+	return nil, fmt.Errorf("feed doesn't contain a list")
+}
+
+// / Write rows as CSV with a column for every field seen in any row. Nested
+// / objects are flattened into dotted column names and arrays written as
+// / JSON.
+func writeCSV(rows []map[string]any) error {
+	flat := make([]map[string]string, len(rows))
+	var columns []string
+	seen := make(map[string]bool)
+	for i, row := range rows {
+		flat[i] = make(map[string]string)
+		flatten("", row, flat[i])
+		for column := range flat[i] {
+			if !seen[column] {
+				seen[column] = true
+				columns = append(columns, column)
+			}
+		}
+	}
+	// identifiers first, so each row is easy to recognise
+	slices.SortFunc(columns, func(a, b string) int {
+		if ai, bi := strings.HasSuffix(a, "_id"), strings.HasSuffix(b, "_id"); ai != bi {
+			if ai {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a, b)
+	})
+
+	w := csv.NewWriter(os.Stdout)
+	if err := w.Write(columns); err != nil {
+		return err
+	}
+	record := make([]string, len(columns))
+	for _, row := range flat {
+		for i, column := range columns {
+			record[i] = row[column]
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func flatten(prefix string, v map[string]any, out map[string]string) {
+	for key, value := range v {
+		switch value := value.(type) {
+		case map[string]any:
+			flatten(prefix+key+".", value, out)
+		case []any:
+			b, _ := json.Marshal(value)
+			out[prefix+key] = string(b)
+		case float64:
+			out[prefix+key] = strconv.FormatFloat(value, 'f', -1, 64)
+		case nil:
+			out[prefix+key] = ""
+		default:
+			out[prefix+key] = fmt.Sprint(value)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+// / Remove the elements of items with the same id as an earlier one, keeping
+// / the first one's position and the last one's value so duplicates are
+// / resolved the same way whatever order a feed lists them in. Returns the
+// / number of duplicates removed.
+func dedupe[T any](items []T, id func(*T) string) ([]T, int) {
+	index := make(map[string]int, len(items))
+	unique := items[:0:0]
+	for i := range items {
+		key := id(&items[i])
+		if idx, ok := index[key]; ok {
+			unique[idx] = items[i]
+			continue
+		}
+		index[key] = len(unique)
+		unique = append(unique, items[i])
+	}
+	return unique, len(items) - len(unique)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDedupe(t *testing.T) {
+	type item struct {
+		id    string
+		value int
+	}
+	tests := []struct {
+		name       string
+		items      []item
+		want       []item
+		duplicates int
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:  "unique",
+			items: []item{{"a", 1}, {"b", 2}, {"c", 3}},
+			want:  []item{{"a", 1}, {"b", 2}, {"c", 3}},
+		},
+		{
+			name:       "last value at first position",
+			items:      []item{{"a", 1}, {"b", 2}, {"a", 3}},
+			want:       []item{{"a", 3}, {"b", 2}},
+			duplicates: 1,
+		},
+		{
+			name:       "repeated",
+			items:      []item{{"a", 1}, {"a", 2}, {"b", 3}, {"a", 4}, {"b", 5}},
+			want:       []item{{"a", 4}, {"b", 5}},
+			duplicates: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items := append([]item(nil), tt.items...)
+			got, duplicates := dedupe(items, func(i *item) string { return i.id })
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("dedupe(%v) = %v, want %v", tt.items, got, tt.want)
+			}
+			if duplicates != tt.duplicates {
+				t.Errorf("dedupe(%v) removed %d duplicates, want %d", tt.items, duplicates, tt.duplicates)
+			}
+			// the feed's slice is left as fetched
+			if !reflect.DeepEqual(items, tt.items) {
+				t.Errorf("dedupe modified its input to %v", items)
+			}
+		})
+	}
+}
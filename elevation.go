@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// / ElevationSource looks up the elevation in meters of coordinates given as
+// / latitude, longitude. Coordinates it has no elevation for are NaN.
+type ElevationSource interface {
+	Elevations(ctx context.Context, coords [][2]float64) ([]float64, error)
+}
+
+// / OpenMeteoElevation looks elevations up with the Open-Meteo elevation API,
+// / https://open-meteo.com/en/docs/elevation-api, or a compatible server.
+type OpenMeteoElevation struct {
+	URL    string
+	client *http.Client
+}
+
+func NewOpenMeteoElevation(url string) *OpenMeteoElevation {
+	return &OpenMeteoElevation{URL: url, client: &http.Client{}}
+}
+
+// / the API takes at most 100 coordinates per request
+const openMeteoBatch = 100
+
+func (o *OpenMeteoElevation) Elevations(ctx context.Context, coords [][2]float64) ([]float64, error) {
+	var elevations []float64
+	for start := 0; start < len(coords); start += openMeteoBatch {
+		batch := coords[start:min(start+openMeteoBatch, len(coords))]
+		lats := make([]string, len(batch))
+		lons := make([]string, len(batch))
+		for i, c := range batch {
+			lats[i] = strconv.FormatFloat(c[0], 'f', -1, 64)
+			lons[i] = strconv.FormatFloat(c[1], 'f', -1, 64)
+		}
+		query := url.Values{"latitude": {strings.Join(lats, ",")}, "longitude": {strings.Join(lons, ",")}}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.URL+"?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := o.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		var body struct {
+			Elevation []float64 `json:"elevation"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+		if resp.StatusCode > 299 {
+			return nil, fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(body.Elevation) != len(batch) {
+			return nil, fmt.Errorf("got %d elevations for %d coordinates", len(body.Elevation), len(batch))
+		}
+		elevations = append(elevations, body.Elevation...)
+	}
+	return elevations, nil
+}
+
+// / ASCIIGrid is an offline digital elevation model in the ESRI ASCII grid
+// / format, which most GIS tools can export a DEM to, e.g.
+// / `gdal_translate -of AAIGrid dem.tif dem.asc`. Its cells must be in
+// / latitude and longitude degrees.
+type ASCIIGrid struct {
+	cols, rows     int
+	xll, yll, cell float64
+	nodata         float64
+	values         []float64
+}
+
+func LoadASCIIGrid(path string) (*ASCIIGrid, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	g := &ASCIIGrid{nodata: math.NaN()}
+	center := false
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<24)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		// header lines are a keyword and a value, followed by the rows of
+		// values from north to south
+		if key := strings.ToLower(fields[0]); len(fields) == 2 && key[0] >= 'a' && key[0] <= 'z' {
+			v, err := strconv.ParseFloat(fields[1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("grid header %s: %w", key, err)
+			}
+			switch key {
+			case "ncols":
+				g.cols = int(v)
+			case "nrows":
+				g.rows = int(v)
+			case "xllcorner":
+				g.xll = v
+			case "yllcorner":
+				g.yll = v
+			case "xllcenter":
+				g.xll, center = v, true
+			case "yllcenter":
+				g.yll, center = v, true
+			case "cellsize":
+				g.cell = v
+			case "nodata_value":
+				g.nodata = v
+			default:
+				return nil, fmt.Errorf("unknown grid header %q", key)
+			}
+			continue
+		}
+		for _, field := range fields {
+			v, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				return nil, err
+			}
+			g.values = append(g.values, v)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if g.cols <= 0 || g.rows <= 0 || g.cell <= 0 {
+		return nil, errors.New("grid header without ncols, nrows or cellsize")
+	}
+	if len(g.values) != g.cols*g.rows {
+		return nil, fmt.Errorf("expected %d grid values, got %d", g.cols*g.rows, len(g.values))
+	}
+	if center {
+		g.xll -= g.cell / 2
+		g.yll -= g.cell / 2
+	}
+	return g, nil
+}
+
+func (g *ASCIIGrid) Elevations(ctx context.Context, coords [][2]float64) ([]float64, error) {
+	elevations := make([]float64, len(coords))
+	for i, c := range coords {
+		col := int(math.Floor((c[1] - g.xll) / g.cell))
+		row := g.rows - 1 - int(math.Floor((c[0]-g.yll)/g.cell))
+		elevations[i] = math.NaN()
+		if col < 0 || col >= g.cols || row < 0 || row >= g.rows {
+			continue
+		}
+		if v := g.values[row*g.cols+col]; v != g.nodata {
+			elevations[i] = v
+		}
+	}
+	return elevations, nil
+}
+
+// / ElevationMetrics exports the elevation of every station, looked up from
+// / Source once per location. Lookups are cached in memory and, if set, in
+// / the JSON file at CachePath so restarts don't repeat them.
+type ElevationMetrics struct {
+	Source    ElevationSource
+	CachePath string
+
+	station_elevation_meters prometheus.GaugeVec
+
+	cache map[string]float64
+}
+
+func NewElevationMetrics(reg prometheus.Registerer) *ElevationMetrics {
+	m := &ElevationMetrics{
+		station_elevation_meters: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "station_elevation_meters",
+			Help: "Elevation of the station above sea level",
+		},
+			[]string{"station_id", "name"},
+		),
+		cache: make(map[string]float64),
+	}
+	reg.MustRegister(m.station_elevation_meters)
+
+	return m
+}
+
+func elevationKey(lat, lon float64) string {
+	return strconv.FormatFloat(lat, 'f', -1, 64) + "," + strconv.FormatFloat(lon, 'f', -1, 64)
+}
+
+// / Load the elevations cached by earlier runs from CachePath, if it exists.
+func (m *ElevationMetrics) LoadCache() error {
+	data, err := os.ReadFile(m.CachePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &m.cache)
+}
+
+func (m *ElevationMetrics) saveCache() error {
+	data, err := json.Marshal(m.cache)
+	if err != nil {
+		return err
+	}
+	tmp := m.CachePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.CachePath)
+}
+
+// / Look up the stations of snapshot without a cached elevation and export
+// / the elevation of every station. Failed lookups are retried next cycle.
+func (m *ElevationMetrics) Observe(ctx context.Context, snapshot *Snapshot) {
+	if m.Source == nil {
+		return
+	}
+
+	var missing [][2]float64
+	for _, station := range snapshot.Stations {
+		// stations only known from station_status have no location
+		if station.Lat == 0 && station.Lon == 0 {
+			continue
+		}
+		if _, ok := m.cache[elevationKey(station.Lat, station.Lon)]; !ok {
+			missing = append(missing, [2]float64{station.Lat, station.Lon})
+		}
+	}
+	if len(missing) > 0 {
+		elevations, err := m.Source.Elevations(ctx, missing)
+		if err != nil {
+			log.Printf("Error looking up station elevations %s\n", err)
+		}
+		for i, elevation := range elevations {
+			if !math.IsNaN(elevation) {
+				m.cache[elevationKey(missing[i][0], missing[i][1])] = elevation
+			}
+		}
+		if len(elevations) > 0 && m.CachePath != "" {
+			if err := m.saveCache(); err != nil {
+				log.Printf("Error saving elevation cache %s\n", err)
+			}
+		}
+	}
+
+	for _, station := range snapshot.Stations {
+		if elevation, ok := m.cache[elevationKey(station.Lat, station.Lon)]; ok {
+			m.station_elevation_meters.With(stationLabels(station.StationId, string(station.Name))).Set(elevation)
+		}
+	}
+}
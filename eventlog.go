@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// / EventLog is a Sink appending every cycle's events to a JSONL file, one
+// / JSON object per line, as an audit trail of the system's behaviour that
+// / outlives the retention of the metrics and can be searched with grep or
+// / jq. Once the file reaches MaxSize it is rotated to <path>.1, shifting
+// / older files up to <path>.<MaxFiles>, the oldest of which is deleted.
+type EventLog struct {
+	// MaxSize is the size in bytes the file is rotated at; never if zero.
+	MaxSize int64
+	// MaxFiles is the number of rotated files kept.
+	MaxFiles int
+
+	path string
+	file *os.File
+	size int64
+}
+
+func NewEventLog(path string) (*EventLog, error) {
+	l := &EventLog{path: path}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *EventLog) Name() string {
+	return "eventlog"
+}
+
+func (l *EventLog) open() error {
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	l.file, l.size = file, info.Size()
+	return nil
+}
+
+func (l *EventLog) Send(ctx context.Context, cycle *Cycle) error {
+	if len(cycle.Events) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, event := range cycle.Events {
+		if err := enc.Encode(event); err != nil {
+			return err
+		}
+	}
+	if l.MaxSize > 0 && l.size > 0 && l.size+int64(buf.Len()) > l.MaxSize {
+		if err := l.rotate(); err != nil {
+			return fmt.Errorf("rotating %s: %w", l.path, err)
+		}
+	}
+	n, err := l.file.Write(buf.Bytes())
+	l.size += int64(n)
+	return err
+}
+
+func (l *EventLog) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	if l.MaxFiles > 0 {
+		for i := l.MaxFiles - 1; i > 0; i-- {
+			err := os.Rename(fmt.Sprintf("%s.%d", l.path, i), fmt.Sprintf("%s.%d", l.path, i+1))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if err := os.Rename(l.path, l.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(l.path); err != nil {
+		return err
+	}
+	return l.open()
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/patrickod/baywheels-exporter/pkg/gbfs"
+)
+
+// / Event is a state transition of a station or bike worth noting on its own,
+// / rather than as a change in a metric: a station running out of bikes,
+// / stopping or resuming renting, a bike being disabled, or the operator
+// / rebalancing a station.
+type Event struct {
+	Time      time.Time `json:"time"`
+	Type      string    `json:"type"`
+	StationId string    `json:"station_id,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	BikeId    string    `json:"bike_id,omitempty"`
+	Lat       float64   `json:"lat,omitempty"`
+	Lon       float64   `json:"lon,omitempty"`
+	// Bikes is the change in bikes available of a rebalancing, negative for
+	// pickups.
+	Bikes int `json:"bikes,omitempty"`
+}
+
+const (
+	StationEmpty          = "station_empty"
+	StationFull           = "station_full"
+	StationStoppedRenting = "station_stopped_renting"
+	StationResumedRenting = "station_resumed_renting"
+	StationRebalanced     = "station_rebalanced"
+	BikeDisabled          = "bike_disabled"
+)
+
+// / Derive the events of a cycle from its station changes and the bikes of
+// / the previous and current snapshots. Rebalancing is a change of at least
+// / rebalancingThreshold bikes, as counted by TripMetrics; disabled if zero.
+// / As with diffStations a nil prev yields no events.
+func detectEvents(prev, cur *Snapshot, changes []StationChange, rebalancingThreshold int) []Event {
+	if prev == nil {
+		return nil
+	}
+
+	var events []Event
+	for _, change := range changes {
+		if change.Previous == nil || change.Current == nil {
+			continue
+		}
+		before, after := change.Previous, change.Current
+		event := func(kind string) Event {
+			return Event{Time: change.Time, Type: kind, StationId: change.StationId, Name: change.Name}
+		}
+		if before.BikesAvailable > 0 && after.BikesAvailable == 0 {
+			events = append(events, event(StationEmpty))
+		}
+		if before.DocksAvailable > 0 && after.DocksAvailable == 0 {
+			events = append(events, event(StationFull))
+		}
+		if before.IsRenting != 0 && after.IsRenting == 0 {
+			events = append(events, event(StationStoppedRenting))
+		}
+		if before.IsRenting == 0 && after.IsRenting != 0 {
+			events = append(events, event(StationResumedRenting))
+		}
+		delta := after.BikesAvailable - before.BikesAvailable
+		if rebalancingThreshold > 0 && max(delta, -delta) >= rebalancingThreshold {
+			e := event(StationRebalanced)
+			e.Bikes = delta
+			events = append(events, e)
+		}
+	}
+
+	disabled := make(map[string]gbfs.Flag, len(prev.Bikes))
+	for _, bike := range prev.Bikes {
+		disabled[bike.BikeId] = bike.IsDisabled
+	}
+	for _, bike := range cur.Bikes {
+		// including bikes that appear already disabled, e.g. returned broken
+		if was, ok := disabled[bike.BikeId]; bike.IsDisabled != 0 && (!ok || was == 0) {
+			events = append(events, Event{Time: cur.Time, Type: BikeDisabled, BikeId: bike.BikeId, Lat: bike.Lat, Lon: bike.Lon})
+		}
+	}
+	return events
+}
+
+// / eventBufferSize is the number of events kept for /api/v1/events by
+// / default, several hours' worth for a large system.
+const eventBufferSize = 10000
+
+// / EventBuffer keeps the most recent events in a ring buffer, for
+// / /api/v1/events to show what changed recently without a log to read
+// / back. Once full the oldest events are overwritten.
+type EventBuffer struct {
+	mu     sync.Mutex
+	events []Event
+	// next is the index the next event is written to, and the oldest
+	// event once full
+	next int
+	full bool
+}
+
+func NewEventBuffer(size int) *EventBuffer {
+	return &EventBuffer{events: make([]Event, size)}
+}
+
+func (b *EventBuffer) Record(events []Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.events) == 0 {
+		return
+	}
+	for _, event := range events {
+		b.events[b.next] = event
+		b.next = (b.next + 1) % len(b.events)
+		b.full = b.full || b.next == 0
+	}
+}
+
+// / Return the buffered events after since, oldest first.
+func (b *EventBuffer) Since(since time.Time) []Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ordered := b.events[:b.next]
+	if b.full {
+		ordered = append(slices.Clone(b.events[b.next:]), b.events[:b.next]...)
+	}
+	// events are recorded in time order, so skip to the first one after since
+	start := sort.Search(len(ordered), func(i int) bool { return ordered[i].Time.After(since) })
+	return slices.Clone(ordered[start:])
+}
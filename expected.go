@@ -0,0 +1,114 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// / ExpectedMetrics keeps an exponentially weighted moving average of each
+// / station's bikes available per hour of the day, and reports it along with
+// / how far the current value deviates from it, so "unusually empty" alerts
+// / can compare against what is normal for the time of day without long range
+// / queries. The samples of an hour are averaged and folded into that hour's
+// / moving average once it is over, so each day weighs the same however
+// / often the feeds are sampled.
+type ExpectedMetrics struct {
+	// Alpha is the weight of each day's mean of an hour in its average.
+	Alpha float64
+	// Location is the time zone the hours of the day are in.
+	Location *time.Location
+
+	station_bikes_expected  prometheus.GaugeVec
+	station_bikes_deviation prometheus.GaugeVec
+
+	stations map[string]*expectedState
+}
+
+// / expectedState is a station's moving averages per hour of the day, and
+// / the samples of the hour in progress.
+type expectedState struct {
+	averages [24]float64
+	seen     [24]bool
+
+	// sum and count are of the samples since hour, the start of the hour
+	// in progress
+	hour  time.Time
+	sum   float64
+	count int
+}
+
+func NewExpectedMetrics(reg prometheus.Registerer) *ExpectedMetrics {
+	m := &ExpectedMetrics{
+		Alpha:    0.2,
+		Location: time.Local,
+		station_bikes_expected: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "station_bikes_expected",
+			Help: "Moving average over the previous days of the bikes available at the station at this hour of the day",
+		},
+			[]string{"station_id", "name"},
+		),
+		station_bikes_deviation: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "station_bikes_deviation",
+			Help: "Bikes available at the station minus station_bikes_expected",
+		},
+			[]string{"station_id", "name"},
+		),
+		stations: make(map[string]*expectedState),
+	}
+	reg.MustRegister(m.station_bikes_expected)
+	reg.MustRegister(m.station_bikes_deviation)
+
+	return m
+}
+
+// / Compare each station's bikes available with the average of the current
+// / hour of the day over the previous days. Once an hour is over, the mean
+// / of its samples is folded into its average, the first day's seeding it,
+// / so nothing is reported for an hour until it has been seen once.
+func (m *ExpectedMetrics) Observe(snapshot *Snapshot) {
+	local := snapshot.Time.In(m.Location)
+	hour := local.Truncate(time.Hour)
+	for _, station := range snapshot.Stations {
+		if station.Status == nil {
+			continue
+		}
+		state, ok := m.stations[station.StationId]
+		if !ok {
+			state = &expectedState{hour: hour}
+			m.stations[station.StationId] = state
+		}
+		if !hour.Equal(state.hour) {
+			m.fold(state)
+			state.hour, state.sum, state.count = hour, 0, 0
+		}
+		current := float64(station.Status.BikesAvailable)
+		state.sum += current
+		state.count++
+
+		labels := stationLabels(station.StationId, string(station.Name))
+		if !state.seen[local.Hour()] {
+			m.station_bikes_expected.Delete(labels)
+			m.station_bikes_deviation.Delete(labels)
+			continue
+		}
+		expected := state.averages[local.Hour()]
+		m.station_bikes_expected.With(labels).Set(expected)
+		m.station_bikes_deviation.With(labels).Set(current - expected)
+	}
+}
+
+// / Fold the mean of the samples of the hour in progress into the average
+// / of its hour of the day.
+func (m *ExpectedMetrics) fold(state *expectedState) {
+	if state.count == 0 {
+		return
+	}
+	h := state.hour.Hour()
+	mean := state.sum / float64(state.count)
+	if !state.seen[h] {
+		state.averages[h], state.seen[h] = mean, true
+		return
+	}
+	state.averages[h] = m.Alpha*mean + (1-m.Alpha)*state.averages[h]
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"expvar"
+	"runtime"
+	"time"
+)
+
+// / Publish the exporter's internal state as the "baywheels" expvar, served
+// / on the debug server's /debug/vars next to the standard cmdline and
+// / memstats vars, e.g. for `expvarmon` or a quick curl.
+func publishExpvars(e *Exporter) {
+	expvar.Publish("baywheels", expvar.Func(func() any {
+		vars := struct {
+			Cycles     int64
+			LastCycle  time.Time
+			Stations   int
+			Bikes      int
+			Feeds      []FeedStatus
+			Goroutines int
+		}{
+			Cycles:     e.cycles.Load(),
+			Feeds:      e.status.Feeds(),
+			Goroutines: runtime.NumGoroutine(),
+		}
+		if snapshot := e.Snapshot(); snapshot != nil {
+			vars.LastCycle = snapshot.Time
+			vars.Stations = len(snapshot.Stations)
+			vars.Bikes = len(snapshot.Bikes)
+		}
+		return vars
+	}))
+}
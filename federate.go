@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/exporter-toolkit/web"
+	"google.golang.org/protobuf/proto"
+)
+
+// / Federation scrapes the /metrics of several downstream exporters, one per
+// / system, and merges them into one view with a label naming the system of
+// / every series, for hub-and-spoke deployments where a central Prometheus
+// / scrapes one exporter rather than one per city. Downstream exporters are
+// / scraped as the federation is, so the merged view is as fresh as theirs.
+// / Series already carrying the label keep it, so federations can be
+// / federated in turn.
+type Federation struct {
+	// Targets are the base URLs of the downstream exporters by system.
+	Targets map[string]string
+	// Label is the name of the label holding the system.
+	Label string
+
+	client *http.Client
+
+	up              *prometheus.GaugeVec
+	scrape_duration *prometheus.GaugeVec
+	series          *prometheus.GaugeVec
+}
+
+func NewFederation(targets map[string]string, label string, timeout time.Duration, reg prometheus.Registerer) *Federation {
+	f := &Federation{
+		Targets: targets,
+		Label:   label,
+		client:  &http.Client{Timeout: timeout},
+		up: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "baywheels_exporter_federate_up",
+			Help: "Whether the last scrape of a downstream exporter succeeded",
+		},
+			[]string{label},
+		),
+		scrape_duration: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "baywheels_exporter_federate_scrape_duration_seconds",
+			Help: "Duration of the last scrape of a downstream exporter",
+		},
+			[]string{label},
+		),
+		series: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "baywheels_exporter_federate_series",
+			Help: "Number of series merged from a downstream exporter in the last scrape",
+		},
+			[]string{label},
+		),
+	}
+	reg.MustRegister(f.up)
+	reg.MustRegister(f.scrape_duration)
+	reg.MustRegister(f.series)
+
+	return f
+}
+
+// / Gather scrapes every target concurrently and merges their metric
+// / families. A target that fails is left out, and reported by
+// / baywheels_exporter_federate_up, rather than failing the whole scrape;
+// / so is a family whose type differs from the one other targets export.
+func (f *Federation) Gather() ([]*dto.MetricFamily, error) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	scraped := make(map[string]map[string]*dto.MetricFamily, len(f.Targets))
+	for system, url := range f.Targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			families, err := f.scrape(url)
+			f.scrape_duration.WithLabelValues(system).Set(time.Since(start).Seconds())
+			if err != nil {
+				log.Printf("Error scraping %s exporter %s\n", system, err)
+				f.up.WithLabelValues(system).Set(0)
+				f.series.WithLabelValues(system).Set(0)
+				return
+			}
+			f.up.WithLabelValues(system).Set(1)
+			mu.Lock()
+			scraped[system] = families
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	// merge in system order, so which target's help text wins is stable
+	systems := make([]string, 0, len(scraped))
+	for system := range scraped {
+		systems = append(systems, system)
+	}
+	sort.Strings(systems)
+	merged := make(map[string]*dto.MetricFamily)
+	for _, system := range systems {
+		series := 0
+		for name, family := range scraped[system] {
+			existing := merged[name]
+			if existing == nil {
+				existing = &dto.MetricFamily{Name: family.Name, Help: family.Help, Type: family.Type, Unit: family.Unit}
+				merged[name] = existing
+			} else if existing.GetType() != family.GetType() {
+				log.Printf("Error merging %s from %s exporter, is a %s rather than a %s\n", name, system, family.GetType(), existing.GetType())
+				continue
+			}
+			for _, metric := range family.Metric {
+				existing.Metric = append(existing.Metric, f.relabel(metric, system))
+			}
+			series += len(family.Metric)
+		}
+		f.series.WithLabelValues(system).Set(float64(series))
+	}
+
+	families := make([]*dto.MetricFamily, 0, len(merged))
+	for _, family := range merged {
+		families = append(families, family)
+	}
+	return families, nil
+}
+
+// / Add the system label to metric unless it already has one.
+func (f *Federation) relabel(metric *dto.Metric, system string) *dto.Metric {
+	for _, label := range metric.Label {
+		if label.GetName() == f.Label {
+			return metric
+		}
+	}
+	metric.Label = append(metric.Label, &dto.LabelPair{Name: proto.String(f.Label), Value: proto.String(system)})
+	sort.Slice(metric.Label, func(i, j int) bool { return metric.Label[i].GetName() < metric.Label[j].GetName() })
+	return metric
+}
+
+func (f *Federation) scrape(url string) (map[string]*dto.MetricFamily, error) {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimRight(url, "/")+"/metrics", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", string(expfmt.NewFormat(expfmt.TypeTextPlain)))
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned %s", resp.Status)
+	}
+	parser := expfmt.NewTextParser(model.UTF8Validation)
+	return parser.TextToMetricFamilies(resp.Body)
+}
+
+// / Serve the stations or bikes of every target's JSON API merged into one
+// / list at path, each with the system it belongs to. Targets that fail are
+// / listed under errors rather than failing the request.
+func (f *Federation) handleAPI(path, list string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		var lastUpdated time.Time
+		items := []map[string]any{}
+		failed := make(map[string]string)
+		for system, url := range f.Targets {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				var response map[string]json.RawMessage
+				var updated time.Time
+				var listed []map[string]any
+				err := f.fetchJSON(r.Context(), strings.TrimRight(url, "/")+path, &response)
+				if err == nil {
+					err = json.Unmarshal(response["last_updated"], &updated)
+				}
+				if err == nil {
+					err = json.Unmarshal(response[list], &listed)
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					failed[system] = err.Error()
+					return
+				}
+				if updated.After(lastUpdated) {
+					lastUpdated = updated
+				}
+				for _, item := range listed {
+					item[f.Label] = system
+					items = append(items, item)
+				}
+			}()
+		}
+		wg.Wait()
+
+		writeJSON(w, http.StatusOK, map[string]any{
+			"last_updated": lastUpdated,
+			list:           items,
+			"errors":       failed,
+		})
+	})
+}
+
+func (f *Federation) fetchJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// / Run `federate`, serving the merged metrics of the downstream exporters
+// / given with -target at /metrics, and their merged stations and bikes at
+// / /api/v1/stations and /api/v1/bikes.
+func runFederate(args []string) error {
+	fs := flag.NewFlagSet("federate", flag.ExitOnError)
+	listen := stringsFlag{values: []string{":9100"}}
+	fs.Var(&listen, "listen", "Listen address; may be repeated to listen on several")
+	targets := labelsFlag{}
+	fs.Var(targets, "target", "Downstream exporter as system=base URL, e.g. sf=http://sf-exporter:9100; may be repeated")
+	label := fs.String("label", "system", "Label naming the system of every merged series")
+	timeout := fs.Duration("scrape.timeout", 10*time.Second, "Timeout of the scrapes of the downstream exporters")
+	webConfig := fs.String("web.config.file", "", "Path to an exporter-toolkit web configuration file enabling TLS or authentication")
+	accessLog := fs.String("web.access-log", "plain", "Format of the access log of the HTTP server: plain, logfmt, json or off")
+	fs.Parse(args)
+
+	if len(targets) == 0 {
+		return fmt.Errorf("no -target to federate")
+	}
+	if !model.LabelName(*label).IsValid() {
+		return fmt.Errorf("invalid -label %q", *label)
+	}
+
+	registry := prometheus.NewRegistry()
+	federation := NewFederation(targets, *label, *timeout, registry)
+	gatherers := prometheus.Gatherers{registry, federation}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(gatherers, promhttp.HandlerOpts{}))
+	mux.Handle("GET /api/v1/stations", federation.handleAPI("/api/v1/stations", "stations"))
+	mux.Handle("GET /api/v1/bikes", federation.handleAPI("/api/v1/bikes", "bikes"))
+	handler, err := logRequests(mux, *accessLog)
+	if err != nil {
+		return err
+	}
+
+	systemdSocket := false
+	flags := &web.FlagConfig{
+		WebListenAddresses: &listen.values,
+		WebSystemdSocket:   &systemdSocket,
+		WebConfigFile:      webConfig,
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	return web.ListenAndServe(&http.Server{Handler: handler}, flags, logger)
+}
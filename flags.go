@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// / labelsFlag collects repeated name=value flags into a label set.
+type labelsFlag map[string]string
+
+func (l labelsFlag) String() string {
+	pairs := make([]string, 0, len(l))
+	for name, value := range l {
+		pairs = append(pairs, name+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (l labelsFlag) Set(v string) error {
+	name, value, ok := strings.Cut(v, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("expected name=value, got %q", v)
+	}
+	l[name] = value
+	return nil
+}
+
+// / windowsFlag collects repeated "[days] HH:MM-HH:MM" flags into daily
+// / windows.
+type windowsFlag []ScheduleWindow
+
+func (w *windowsFlag) String() string {
+	if w == nil {
+		return ""
+	}
+	windows := make([]string, len(*w))
+	for i, window := range *w {
+		windows[i] = clockTime(window.Start) + "-" + clockTime(window.End)
+	}
+	return strings.Join(windows, ",")
+}
+
+func (w *windowsFlag) Set(v string) error {
+	window, err := parseWindow(v)
+	if err != nil {
+		return err
+	}
+	*w = append(*w, window)
+	return nil
+}
+
+// / stringsFlag collects repeated flags into a list, replacing its default
+// / the first time it is set.
+type stringsFlag struct {
+	values []string
+	set    bool
+}
+
+func (s *stringsFlag) String() string {
+	if s == nil {
+		return ""
+	}
+	return strings.Join(s.values, ",")
+}
+
+func (s *stringsFlag) Set(v string) error {
+	if !s.set {
+		s.values, s.set = nil, true
+	}
+	s.values = append(s.values, v)
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+)
+
+type GeoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []GeoJSONFeature `json:"features"`
+}
+
+type GeoJSONFeature struct {
+	Type       string         `json:"type"`
+	ID         string         `json:"id,omitempty"`
+	Geometry   GeoJSONPoint   `json:"geometry"`
+	Properties map[string]any `json:"properties"`
+}
+
+type GeoJSONPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+func newPoint(lat, lon float64) GeoJSONPoint {
+	// GeoJSON positions are longitude first
+	return GeoJSONPoint{Type: "Point", Coordinates: [2]float64{lon, lat}}
+}
+
+// / Build a FeatureCollection of the stations in snapshot, and its free
+// / bikes if includeBikes is set. Every feature has a "kind" property of
+// / either "station" or "bike" so consumers can style them separately.
+func snapshotGeoJSON(snapshot *Snapshot, includeBikes bool) GeoJSONFeatureCollection {
+	fc := GeoJSONFeatureCollection{Type: "FeatureCollection", Features: []GeoJSONFeature{}}
+	for _, station := range snapshot.Stations {
+		// stations only known from station_status have no location
+		if station.Lat == 0 && station.Lon == 0 {
+			continue
+		}
+		props := map[string]any{
+			"kind":       "station",
+			"station_id": station.StationId,
+			"name":       station.Name,
+			"short_name": station.ShortName,
+			"capacity":   station.Capacity,
+		}
+		if status := station.Status; status != nil {
+			props["bikes_available"] = status.BikesAvailable
+			props["ebikes_available"] = status.EBikesAvailable
+			props["bikes_disabled"] = status.BikesDisabled
+			props["docks_available"] = status.DocksAvailable
+			props["docks_disabled"] = status.DocksDisabled
+			props["is_installed"] = status.IsInstalled
+			props["is_renting"] = status.IsRenting
+			props["is_returning"] = status.IsReturning
+			props["last_reported"] = status.LastReported.Seconds()
+		}
+		fc.Features = append(fc.Features, GeoJSONFeature{
+			Type:       "Feature",
+			ID:         station.StationId,
+			Geometry:   newPoint(station.Lat, station.Lon),
+			Properties: props,
+		})
+	}
+
+	if includeBikes {
+		for _, bike := range snapshot.Bikes {
+			fc.Features = append(fc.Features, GeoJSONFeature{
+				Type:     "Feature",
+				ID:       bike.BikeId,
+				Geometry: newPoint(bike.Lat, bike.Lon),
+				Properties: map[string]any{
+					"kind":        "bike",
+					"bike_id":     bike.BikeId,
+					"is_disabled": bike.IsDisabled,
+					"is_reserved": bike.IsReserved,
+				},
+			})
+		}
+	}
+	return fc
+}
+
+func (a *API) stationsGeoJSON(w http.ResponseWriter, r *http.Request) {
+	snapshot := a.snapshot(w)
+	if snapshot == nil {
+		return
+	}
+	includeBikes, _ := strconv.ParseBool(r.URL.Query().Get("bikes"))
+
+	w.Header().Set("Content-Type", "application/geo+json")
+	writeJSON(w, http.StatusOK, snapshotGeoJSON(snapshot, includeBikes))
+}
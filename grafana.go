@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// / Run `grafana-dashboard`, writing a dashboard for the exporter's metrics
+// / to stdout that can be imported into Grafana as is. The metric names and
+// / label selectors follow the same -metrics.namespace as the exporter, plus
+// / any labels the scrape config or remote write adds, e.g. -label job=baywheels.
+func runGrafanaDashboard(args []string) error {
+	fs := flag.NewFlagSet("grafana-dashboard", flag.ExitOnError)
+	namespace := fs.String("metrics.namespace", "", "Namespace the exporter prepends to station and bike metric names")
+	title := fs.String("title", "Bay Wheels", "Title of the dashboard")
+	labels := labelsFlag{}
+	fs.Var(labels, "label", "Label every query is restricted to as name=value; may be repeated")
+	fs.Parse(args)
+
+	d := grafanaDashboard{namespace: *namespace, selector: promSelector(labels)}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d.build(*title))
+}
+
+type grafanaDashboard struct {
+	namespace string
+	selector  string
+	panels    []map[string]any
+}
+
+func (d *grafanaDashboard) metric(name string) string {
+	return promMetric(d.namespace, name, d.selector)
+}
+
+// / Return a station or bike metric name with the namespace and label
+// / selector applied, as used by the generated dashboards and rules.
+func promMetric(namespace, name, selector string) string {
+	if namespace != "" {
+		name = namespace + "_" + name
+	}
+	return name + selector
+}
+
+func promSelector(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	matchers := make([]string, 0, len(labels))
+	for name, value := range labels {
+		matchers = append(matchers, fmt.Sprintf("%s=%q", name, value))
+	}
+	sort.Strings(matchers)
+	return "{" + strings.Join(matchers, ",") + "}"
+}
+
+func (d *grafanaDashboard) add(title, kind string, x, y, w, h int, targets []map[string]any, extra map[string]any) {
+	panel := map[string]any{
+		"id":         len(d.panels) + 1,
+		"title":      title,
+		"type":       kind,
+		"datasource": grafanaDatasource,
+		"gridPos":    map[string]int{"x": x, "y": y, "w": w, "h": h},
+		"targets":    targets,
+	}
+	for k, v := range extra {
+		panel[k] = v
+	}
+	d.panels = append(d.panels, panel)
+}
+
+var grafanaDatasource = map[string]string{"type": "prometheus", "uid": "${datasource}"}
+
+func target(expr, legend string) map[string]any {
+	return map[string]any{
+		"datasource":   grafanaDatasource,
+		"refId":        "A",
+		"expr":         expr,
+		"legendFormat": legend,
+	}
+}
+
+// / An instant query formatted as a table, as the geomap and bar gauge
+// / panels want a single value per station.
+func instant(expr, legend string) map[string]any {
+	t := target(expr, legend)
+	t["instant"] = true
+	t["range"] = false
+	t["format"] = "table"
+	return t
+}
+
+func fieldConfig(unit string, extra map[string]any) map[string]any {
+	defaults := map[string]any{"unit": unit}
+	for k, v := range extra {
+		defaults[k] = v
+	}
+	return map[string]any{"defaults": defaults, "overrides": []any{}}
+}
+
+func (d *grafanaDashboard) build(title string) map[string]any {
+	bikes := d.metric("station_bikes_available")
+	ebikes := d.metric("station_ebikes_available")
+	docks := d.metric("station_docks_available")
+
+	stats := []struct{ title, expr string }{
+		{"Bikes available", fmt.Sprintf("sum(%s)", bikes)},
+		{"E-bikes available", fmt.Sprintf("sum(%s)", ebikes)},
+		{"Docks available", fmt.Sprintf("sum(%s)", docks)},
+		{"Empty stations", fmt.Sprintf("count(%s == 0) or vector(0)", bikes)},
+		{"Full stations", fmt.Sprintf("count(%s == 0) or vector(0)", docks)},
+	}
+	for i, stat := range stats {
+		d.add(stat.title, "stat", i*24/len(stats), 0, 24/len(stats), 4,
+			[]map[string]any{target(stat.expr, "")},
+			map[string]any{"fieldConfig": fieldConfig("short", nil)})
+	}
+
+	// station_info carries the coordinates, joined onto availability by
+	// station_id
+	d.add("Availability map", "geomap", 0, 4, 16, 16,
+		[]map[string]any{instant(fmt.Sprintf("%s * on(station_id) group_left(lat, lon) %s", bikes, d.metric("station_info")), "")},
+		map[string]any{
+			"fieldConfig": fieldConfig("short", map[string]any{
+				"color": map[string]string{"mode": "continuous-RdYlGr"},
+			}),
+			"options": map[string]any{
+				"view": map[string]any{"id": "fit", "allLayers": true},
+				"layers": []map[string]any{{
+					"type": "markers",
+					"name": "Stations",
+					"location": map[string]string{
+						"mode":      "coords",
+						"latitude":  "lat",
+						"longitude": "lon",
+					},
+					"config": map[string]any{
+						"showLegend": true,
+						"style": map[string]any{
+							"color": map[string]string{"field": "Value"},
+							"size":  map[string]any{"field": "Value", "min": 3, "max": 12, "fixed": 5},
+							"text":  map[string]string{"mode": "field", "field": "name"},
+						},
+					},
+					"tooltip": true,
+				}},
+			},
+		})
+
+	// share of the dashboard's time range each station spent empty or full
+	for i, top := range []struct{ title, metric string }{
+		{"Most often empty", bikes},
+		{"Most often full", docks},
+	} {
+		d.add(top.title, "bargauge", 16, 4+i*8, 8, 8,
+			[]map[string]any{instant(fmt.Sprintf("topk(10, avg_over_time((%s == bool 0)[$__range:]))", top.metric), "{{name}}")},
+			map[string]any{
+				"fieldConfig": fieldConfig("percentunit", map[string]any{"min": 0, "max": 1}),
+				"options": map[string]any{
+					"orientation":   "horizontal",
+					"displayMode":   "basic",
+					"reduceOptions": map[string]any{"calcs": []string{"lastNotNull"}, "values": true},
+				},
+				"transformations": []map[string]any{
+					{"id": "sortBy", "options": map[string]any{"sort": []map[string]any{{"field": "Value", "desc": true}}}},
+				},
+			})
+	}
+
+	d.add("Bikes by type", "timeseries", 0, 20, 12, 8,
+		[]map[string]any{
+			target(fmt.Sprintf("sum(%s)", ebikes), "E-bikes"),
+			target(fmt.Sprintf("sum(%s) - sum(%s)", bikes, ebikes), "Classic bikes"),
+		},
+		map[string]any{"fieldConfig": fieldConfig("short", map[string]any{
+			"custom": map[string]any{"stacking": map[string]string{"mode": "normal"}, "fillOpacity": 20},
+		})})
+	d.add("Empty and full stations", "timeseries", 12, 20, 12, 8,
+		[]map[string]any{
+			target(fmt.Sprintf("count(%s == 0) or vector(0)", bikes), "Empty"),
+			target(fmt.Sprintf("count(%s == 0) or vector(0)", docks), "Full"),
+		},
+		map[string]any{"fieldConfig": fieldConfig("short", nil)})
+
+	// refIds must be unique within a panel
+	for _, panel := range d.panels {
+		for i, t := range panel["targets"].([]map[string]any) {
+			t["refId"] = string(rune('A' + i))
+		}
+	}
+
+	return map[string]any{
+		"title":         title,
+		"uid":           "baywheels",
+		"schemaVersion": 39,
+		"editable":      true,
+		"time":          map[string]string{"from": "now-24h", "to": "now"},
+		"refresh":       "1m",
+		"tags":          []string{"baywheels", "gbfs"},
+		"templating": map[string]any{
+			"list": []map[string]any{{
+				"name":  "datasource",
+				"label": "Data source",
+				"type":  "datasource",
+				"query": "prometheus",
+			}},
+		},
+		"panels": d.panels,
+	}
+}
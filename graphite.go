@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type GraphiteConfig struct {
+	Address string
+	Prefix  string
+	// Interval is the resolution of the Carbon retention schema. Points are
+	// stamped with the start of their interval and at most one cycle is
+	// written per interval.
+	Interval time.Duration
+	// Namespace of the exporter's metrics, left out of Graphite paths.
+	Namespace string
+}
+
+// / Graphite is a Sink writing metrics to Carbon using the plaintext
+// / protocol, one dotted path per station or bike:
+// /
+// /	<prefix>.stations.<station_id>.bikes_available 7 1700000000
+type Graphite struct {
+	config GraphiteConfig
+	last   time.Time
+}
+
+func NewGraphite(config GraphiteConfig) *Graphite {
+	return &Graphite{config: config}
+}
+
+func (g *Graphite) Name() string {
+	return "graphite"
+}
+
+func (g *Graphite) Send(ctx context.Context, cycle *Cycle) error {
+	bucket := cycle.Time.Truncate(g.config.Interval)
+	if bucket.Equal(g.last) {
+		return nil
+	}
+
+	dialer := net.Dialer{Timeout: 10 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", g.config.Address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetWriteDeadline(time.Now().Add(30 * time.Second))
+
+	w := bufio.NewWriter(conn)
+	ts := strconv.FormatInt(bucket.Unix(), 10)
+	for _, sample := range flattenFamilies(cycle.Families, cycle.Time) {
+		if isExporterMetric(sample.Name) {
+			continue
+		}
+		w.WriteString(g.path(sample))
+		w.WriteByte(' ')
+		w.WriteString(strconv.FormatFloat(sample.Value, 'f', -1, 64))
+		w.WriteByte(' ')
+		w.WriteString(ts)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	g.last = bucket
+	return nil
+}
+
+var graphiteSanitizer = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// / Build the dotted path of a sample. Station and bike metrics are grouped
+// / under their ID; anything else gets its label values appended in label
+// / name order. The human readable station name is left out as it is
+// / neither stable nor path safe.
+func (g *Graphite) path(sample Sample) string {
+	sample.Name = trimNamespace(sample.Name, g.config.Namespace)
+	parts := []string{g.config.Prefix}
+	if id, rest, ok := graphiteEntity(sample, "station_", "station_id"); ok {
+		parts = append(parts, "stations", id, rest)
+	} else if id, rest, ok := graphiteEntity(sample, "bike_", "bike_id"); ok {
+		parts = append(parts, "bikes", id, rest)
+	} else {
+		parts = append(parts, sample.Name)
+		for _, l := range sample.Labels {
+			if l.Name != "name" {
+				parts = append(parts, graphiteSanitizer.ReplaceAllString(l.Value, "_"))
+			}
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+func graphiteEntity(sample Sample, prefix, idLabel string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(sample.Name, prefix)
+	if !ok {
+		return "", "", false
+	}
+	for _, l := range sample.Labels {
+		if l.Name == idLabel {
+			return graphiteSanitizer.ReplaceAllString(l.Value, "_"), rest, true
+		}
+	}
+	return "", "", false
+}
@@ -0,0 +1,137 @@
+package main
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative api/baywheels/v1/baywheels.proto
+
+import (
+	"context"
+	"log"
+	"net"
+	"time"
+
+	pb "github.com/patrickod/baywheels-exporter/api/baywheels/v1"
+	"github.com/patrickod/baywheels-exporter/pkg/gbfs"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// / StationService implements the gRPC API on top of the exporter's latest
+// / snapshot and change feed.
+type StationService struct {
+	pb.UnimplementedStationServiceServer
+	exporter *Exporter
+}
+
+func serveGRPC(listen string, exporter *Exporter) {
+	lis, err := net.Listen("tcp", listen)
+	if err != nil {
+		log.Fatal(err)
+	}
+	server := grpc.NewServer()
+	pb.RegisterStationServiceServer(server, &StationService{exporter: exporter})
+	reflection.Register(server)
+
+	log.Printf("gRPC server listening on %s\n", listen)
+	log.Fatal(server.Serve(lis))
+}
+
+func (s *StationService) snapshot() (*Snapshot, error) {
+	snapshot := s.exporter.Snapshot()
+	if snapshot == nil {
+		return nil, status.Error(codes.Unavailable, "no data sampled yet")
+	}
+	return snapshot, nil
+}
+
+func (s *StationService) ListStations(ctx context.Context, req *pb.ListStationsRequest) (*pb.ListStationsResponse, error) {
+	snapshot, err := s.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	resp := &pb.ListStationsResponse{
+		LastUpdated: timestamppb.New(snapshot.Time),
+		Stations:    make([]*pb.Station, 0, len(snapshot.Stations)),
+	}
+	for _, station := range snapshot.Stations {
+		resp.Stations = append(resp.Stations, stationToProto(station))
+	}
+	return resp, nil
+}
+
+func (s *StationService) GetStation(ctx context.Context, req *pb.GetStationRequest) (*pb.Station, error) {
+	snapshot, err := s.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	station, ok := snapshot.Station(req.GetStationId())
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown station %q", req.GetStationId())
+	}
+	return stationToProto(station), nil
+}
+
+func (s *StationService) WatchStations(req *pb.WatchStationsRequest, stream grpc.ServerStreamingServer[pb.StationChange]) error {
+	wanted := make(map[string]bool, len(req.GetStationIds()))
+	for _, id := range req.GetStationIds() {
+		wanted[id] = true
+	}
+
+	changes, unsubscribe := s.exporter.Changes.Subscribe()
+	defer unsubscribe()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case change := <-changes:
+			if len(wanted) > 0 && !wanted[change.StationId] {
+				continue
+			}
+			err := stream.Send(&pb.StationChange{
+				Time:      timestamppb.New(change.Time),
+				StationId: change.StationId,
+				Name:      change.Name,
+				Previous:  statusToProto(change.Previous),
+				Current:   statusToProto(change.Current),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func stationToProto(station Station) *pb.Station {
+	return &pb.Station{
+		StationId:   station.StationId,
+		Name:        string(station.Name),
+		ShortName:   string(station.ShortName),
+		ExternalId:  station.ExternalId,
+		StationType: station.StationType,
+		Lat:         station.Lat,
+		Lon:         station.Lon,
+		Capacity:    int32(station.Capacity),
+		HasKiosk:    station.HasKiosk,
+		Status:      statusToProto(station.Status),
+	}
+}
+
+func statusToProto(s *gbfs.StationStatus) *pb.StationStatus {
+	if s == nil {
+		return nil
+	}
+	return &pb.StationStatus{
+		IsInstalled:         s.IsInstalled != 0,
+		IsRenting:           s.IsRenting != 0,
+		IsReturning:         s.IsReturning != 0,
+		LastReported:        timestamppb.New(time.Unix(s.LastReported.Seconds(), 0)),
+		BikesAvailable:      int32(s.BikesAvailable),
+		BikesDisabled:       int32(s.BikesDisabled),
+		DocksAvailable:      int32(s.DocksAvailable),
+		DocksDisabled:       int32(s.DocksDisabled),
+		EbikesAvailable:     int32(s.EBikesAvailable),
+		ScootersAvailable:   int32(s.ScootersAvailable),
+		ScootersUnavailable: int32(s.ScootersUnavailable),
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// / Colours of the heatmap, those of the map: the fill ratio runs from red
+// / when empty through orange to green when full.
+var (
+	heatmapEmpty      = color.RGBA{0xd7, 0x19, 0x1c, 0xff}
+	heatmapHalf       = color.RGBA{0xfd, 0xae, 0x61, 0xff}
+	heatmapFull       = color.RGBA{0x1a, 0x96, 0x41, 0xff}
+	heatmapClosed     = color.RGBA{0x88, 0x88, 0x88, 0xff}
+	heatmapBackground = color.RGBA{0xf4, 0xf2, 0xee, 0xff}
+	heatmapGraticule  = color.RGBA{0xe0, 0xdd, 0xd6, 0xff}
+)
+
+// / Largest width or height of /heatmap.png, so a request can't exhaust the
+// / exporter's memory.
+const heatmapMaxSize = 2048
+
+// / heatmapOptions are the query parameters of /heatmap.png.
+type heatmapOptions struct {
+	width, height int
+	// grid is the number of cells across, or zero to draw every station
+	grid int
+	// docks colours by the fill ratio of docks rather than bikes
+	docks bool
+	gray  bool
+}
+
+func parseHeatmapOptions(r *http.Request) (heatmapOptions, string) {
+	opts := heatmapOptions{width: 800, height: 800}
+	q := r.URL.Query()
+	for _, param := range []struct {
+		name  string
+		value *int
+		min   int
+		max   int
+	}{
+		{"width", &opts.width, 16, heatmapMaxSize},
+		{"height", &opts.height, 16, heatmapMaxSize},
+		{"grid", &opts.grid, 0, 512},
+	} {
+		if s := q.Get(param.name); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < param.min || n > param.max {
+				return opts, "invalid " + param.name
+			}
+			*param.value = n
+		}
+	}
+	switch q.Get("metric") {
+	case "", "bikes":
+	case "docks":
+		opts.docks = true
+	default:
+		return opts, "unknown metric"
+	}
+	opts.gray = q.Get("gray") == "true"
+	return opts, ""
+}
+
+// / Serve a PNG of the stations coloured by how full they are, as dots on a
+// / plain background with a graticule, or with ?grid=N averaged over a grid
+// / N cells across, for dashboards, e-ink displays and chat bots that can
+// / show an image but not run the map. ?metric=docks colours by the docks
+// / available instead, ?gray=true renders in grayscale, and ?width= and
+// / ?height= size the image.
+func handleHeatmap(exporter *Exporter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		opts, errMsg := parseHeatmapOptions(r)
+		if errMsg != "" {
+			writeError(w, http.StatusBadRequest, errMsg)
+			return
+		}
+		snapshot := exporter.Snapshot()
+		if snapshot == nil {
+			writeError(w, http.StatusServiceUnavailable, "no data sampled yet")
+			return
+		}
+
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, renderHeatmap(snapshot, opts)); err != nil {
+			log.Printf("Error encoding heatmap %s\n", err)
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Cache-Control", "no-cache")
+		http.ServeContent(w, r, "", snapshot.Time.Truncate(time.Second), bytes.NewReader(buf.Bytes()))
+	})
+}
+
+// / heatmapProjection maps coordinates onto the image with an
+// / equirectangular projection scaled for the latitude, which is accurate
+// / enough at the size of a city, fitted to the stations with a margin.
+type heatmapProjection struct {
+	minLat, minLon float64
+	scale, xScale  float64
+	offX, offY     float64
+	height         int
+}
+
+func newHeatmapProjection(stations []Station, width, height int) heatmapProjection {
+	minLat, maxLat := math.Inf(1), math.Inf(-1)
+	minLon, maxLon := math.Inf(1), math.Inf(-1)
+	for _, station := range stations {
+		minLat, maxLat = min(minLat, station.Lat), max(maxLat, station.Lat)
+		minLon, maxLon = min(minLon, station.Lon), max(maxLon, station.Lon)
+	}
+	if len(stations) == 0 {
+		minLat, maxLat, minLon, maxLon = 0, 0, 0, 0
+	}
+	p := heatmapProjection{minLat: minLat, minLon: minLon, height: height}
+	p.xScale = math.Cos((minLat + maxLat) / 2 * math.Pi / 180)
+	spanX := max((maxLon-minLon)*p.xScale, 1e-6)
+	spanY := max(maxLat-minLat, 1e-6)
+	margin := 0.05 * float64(min(width, height))
+	p.scale = min((float64(width)-2*margin)/spanX, (float64(height)-2*margin)/spanY)
+	p.offX = (float64(width) - spanX*p.scale) / 2
+	p.offY = (float64(height) - spanY*p.scale) / 2
+	return p
+}
+
+func (p heatmapProjection) point(lat, lon float64) (float64, float64) {
+	x := p.offX + (lon-p.minLon)*p.xScale*p.scale
+	y := float64(p.height) - p.offY - (lat-p.minLat)*p.scale
+	return x, y
+}
+
+// / Return the colour of a fill ratio between 0 and 1.
+func heatmapColor(ratio float64, gray bool) color.RGBA {
+	if gray {
+		v := uint8(math.Round(255 * (1 - ratio) * 0.85))
+		return color.RGBA{v, v, v, 0xff}
+	}
+	from, to, t := heatmapEmpty, heatmapHalf, ratio*2
+	if ratio > 0.5 {
+		from, to, t = heatmapHalf, heatmapFull, (ratio-0.5)*2
+	}
+	lerp := func(a, b uint8) uint8 {
+		return uint8(math.Round(float64(a) + (float64(b)-float64(a))*t))
+	}
+	return color.RGBA{lerp(from.R, to.R), lerp(from.G, to.G), lerp(from.B, to.B), 0xff}
+}
+
+// / Return the fraction of a station's bikes and docks available that are
+// / bikes, or docks with docks set, and whether it has any and is renting.
+func fillRatio(station Station, docks bool) (float64, bool) {
+	status := station.Status
+	if status == nil || status.IsRenting == 0 || status.BikesAvailable+status.DocksAvailable == 0 {
+		return 0, false
+	}
+	ratio := float64(status.BikesAvailable) / float64(status.BikesAvailable+status.DocksAvailable)
+	if docks {
+		ratio = 1 - ratio
+	}
+	return ratio, true
+}
+
+func renderHeatmap(snapshot *Snapshot, opts heatmapOptions) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, opts.width, opts.height))
+	background := heatmapBackground
+	if opts.gray {
+		background = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	}
+	fillRect(img, img.Bounds(), background)
+
+	var stations []Station
+	for _, station := range snapshot.Stations {
+		if station.Lat != 0 || station.Lon != 0 {
+			stations = append(stations, station)
+		}
+	}
+	p := newHeatmapProjection(stations, opts.width, opts.height)
+
+	if opts.grid > 0 {
+		renderHeatmapGrid(img, p, stations, opts)
+		return img
+	}
+
+	// a graticule every 0.01°, roughly a kilometer, in place of a basemap
+	if !opts.gray {
+		for lat := math.Floor(p.minLat*100) / 100; ; lat += 0.01 {
+			_, y := p.point(lat, p.minLon)
+			if y < 0 {
+				break
+			}
+			fillRect(img, image.Rect(0, int(y), opts.width, int(y)+1), heatmapGraticule)
+		}
+		for lon := math.Floor(p.minLon*100) / 100; ; lon += 0.01 {
+			x, _ := p.point(p.minLat, lon)
+			if x > float64(opts.width) {
+				break
+			}
+			fillRect(img, image.Rect(int(x), 0, int(x)+1, opts.height), heatmapGraticule)
+		}
+	}
+
+	radius := max(2, float64(min(opts.width, opts.height))/120)
+	for _, station := range stations {
+		c := heatmapClosed
+		if ratio, ok := fillRatio(station, opts.docks); ok {
+			c = heatmapColor(ratio, opts.gray)
+		} else if opts.gray {
+			c = color.RGBA{0xcc, 0xcc, 0xcc, 0xff}
+		}
+		x, y := p.point(station.Lat, station.Lon)
+		fillCircle(img, x, y, radius, c)
+	}
+	return img
+}
+
+// / Colour each cell of a grid opts.grid cells across by the fill ratio of
+// / the stations in it combined, weighting them by their bikes and docks.
+func renderHeatmapGrid(img *image.RGBA, p heatmapProjection, stations []Station, opts heatmapOptions) {
+	cell := float64(opts.width) / float64(opts.grid)
+	rows := int(math.Ceil(float64(opts.height) / cell))
+	type totals struct{ filled, total int }
+	cells := make([]totals, opts.grid*rows)
+	for _, station := range stations {
+		if _, ok := fillRatio(station, opts.docks); !ok {
+			continue
+		}
+		x, y := p.point(station.Lat, station.Lon)
+		col, row := int(x/cell), int(y/cell)
+		if col < 0 || col >= opts.grid || row < 0 || row >= rows {
+			continue
+		}
+		status := station.Status
+		filled := status.BikesAvailable
+		if opts.docks {
+			filled = status.DocksAvailable
+		}
+		cells[row*opts.grid+col].filled += filled
+		cells[row*opts.grid+col].total += status.BikesAvailable + status.DocksAvailable
+	}
+	for i, t := range cells {
+		if t.total == 0 {
+			continue
+		}
+		col, row := i%opts.grid, i/opts.grid
+		r := image.Rect(int(float64(col)*cell), int(float64(row)*cell), int(float64(col+1)*cell), int(float64(row+1)*cell))
+		fillRect(img, r, heatmapColor(float64(t.filled)/float64(t.total), opts.gray))
+	}
+}
+
+func fillRect(img *image.RGBA, r image.Rectangle, c color.RGBA) {
+	r = r.Intersect(img.Bounds())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+}
+
+func fillCircle(img *image.RGBA, cx, cy, radius float64, c color.RGBA) {
+	r := image.Rect(int(cx-radius), int(cy-radius), int(cx+radius)+1, int(cy+radius)+1)
+	r = r.Intersect(img.Bounds())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			dx, dy := float64(x)+0.5-cx, float64(y)+0.5-cy
+			if dx*dx+dy*dy <= radius*radius {
+				img.SetRGBA(x, y, c)
+			}
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const historySchema = `
+CREATE TABLE IF NOT EXISTS station_status (
+	sampled_at           INTEGER NOT NULL,
+	station_id           TEXT    NOT NULL,
+	name                 TEXT    NOT NULL,
+	is_installed         INTEGER NOT NULL,
+	is_renting           INTEGER NOT NULL,
+	is_returning         INTEGER NOT NULL,
+	last_reported        INTEGER NOT NULL,
+	bikes_available      INTEGER NOT NULL,
+	bikes_disabled       INTEGER NOT NULL,
+	docks_available      INTEGER NOT NULL,
+	docks_disabled       INTEGER NOT NULL,
+	ebikes_available     INTEGER NOT NULL,
+	scooters_available   INTEGER NOT NULL,
+	scooters_unavailable INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS station_status_station_sampled_at ON station_status (station_id, sampled_at);
+
+CREATE TABLE IF NOT EXISTS bike_status (
+	sampled_at  INTEGER NOT NULL,
+	bike_id     TEXT    NOT NULL,
+	is_disabled INTEGER NOT NULL,
+	is_reserved INTEGER NOT NULL,
+	lat         REAL    NOT NULL,
+	lon         REAL    NOT NULL
+);
+CREATE INDEX IF NOT EXISTS bike_status_bike_sampled_at ON bike_status (bike_id, sampled_at);
+`
+
+// / SQLiteHistory is a Sink appending every sampled station_status row, and
+// / optionally every free bike, to a local SQLite database. sampled_at is the
+// / unix time of the sampling cycle. Feeds that failed to fetch or weren't due
+// / in a cycle are skipped rather than recorded again from the carried over
+// / snapshot.
+type SQLiteHistory struct {
+	db    *sql.DB
+	bikes bool
+}
+
+func NewSQLiteHistory(path string, bikes bool) (*SQLiteHistory, error) {
+	// WAL lets readers such as the sqlite3 shell query the database while
+	// the exporter is writing to it
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+	// sqlite only supports a single writer
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(historySchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteHistory{db: db, bikes: bikes}, nil
+}
+
+func (h *SQLiteHistory) Name() string {
+	return "sqlite"
+}
+
+func (h *SQLiteHistory) Send(ctx context.Context, cycle *Cycle) error {
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	sampledAt := cycle.Time.Unix()
+	if cycle.Fetched["station_status"] {
+		stmt, err := tx.PrepareContext(ctx, `INSERT INTO station_status VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for _, station := range cycle.Snapshot.Stations {
+			s := station.Status
+			if s == nil {
+				continue
+			}
+			_, err := stmt.ExecContext(ctx, sampledAt, station.StationId, station.Name,
+				s.IsInstalled, s.IsRenting, s.IsReturning, s.LastReported.Seconds(),
+				s.BikesAvailable, s.BikesDisabled, s.DocksAvailable, s.DocksDisabled,
+				s.EBikesAvailable, s.ScootersAvailable, s.ScootersUnavailable)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if h.bikes && cycle.Fetched["free_bike_status"] {
+		stmt, err := tx.PrepareContext(ctx, `INSERT INTO bike_status VALUES (?, ?, ?, ?, ?, ?)`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for _, bike := range cycle.Snapshot.Bikes {
+			_, err := stmt.ExecContext(ctx, sampledAt, bike.BikeId, bike.IsDisabled, bike.IsReserved, bike.Lat, bike.Lon)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// / HistoryPoint is the availability of a station at one sampling cycle.
+type HistoryPoint struct {
+	Time            time.Time `json:"time"`
+	IsRenting       int       `json:"is_renting"`
+	IsReturning     int       `json:"is_returning"`
+	BikesAvailable  int       `json:"num_bikes_available"`
+	EBikesAvailable int       `json:"num_ebikes_available"`
+	DocksAvailable  int       `json:"num_docks_available"`
+}
+
+// / Return the recorded availability of a station sampled in [from, to],
+// / oldest first.
+func (h *SQLiteHistory) Query(ctx context.Context, stationID string, from, to time.Time) ([]HistoryPoint, error) {
+	rows, err := h.db.QueryContext(ctx, `
+		SELECT sampled_at, is_renting, is_returning, bikes_available, ebikes_available, docks_available
+		FROM station_status
+		WHERE station_id = ? AND sampled_at BETWEEN ? AND ?
+		ORDER BY sampled_at`,
+		stationID, from.Unix(), to.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := []HistoryPoint{}
+	for rows.Next() {
+		var p HistoryPoint
+		var sampledAt int64
+		if err := rows.Scan(&sampledAt, &p.IsRenting, &p.IsReturning, &p.BikesAvailable, &p.EBikesAvailable, &p.DocksAvailable); err != nil {
+			return nil, err
+		}
+		p.Time = time.Unix(sampledAt, 0).UTC()
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
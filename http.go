@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// / HTTPMetrics instruments the handlers of the exporter's own HTTP server.
+type HTTPMetrics struct {
+	in_flight     prometheus.Gauge
+	requests      *prometheus.CounterVec
+	duration      *prometheus.HistogramVec
+	response_size *prometheus.HistogramVec
+}
+
+func NewHTTPMetrics(reg prometheus.Registerer) *HTTPMetrics {
+	m := &HTTPMetrics{
+		in_flight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "baywheels_exporter_http_requests_in_flight",
+			Help: "Number of HTTP requests currently being served",
+		}),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "baywheels_exporter_http_requests_total",
+			Help: "Total number of HTTP requests served",
+		},
+			[]string{"handler", "code", "method"},
+		),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "baywheels_exporter_http_request_duration_seconds",
+			Help:    "Latency of HTTP requests served",
+			Buckets: prometheus.DefBuckets,
+		},
+			[]string{"handler", "code", "method"},
+		),
+		response_size: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "baywheels_exporter_http_response_size_bytes",
+			Help: "Size of HTTP responses served",
+			// 256B to 16MB, the size of /metrics for the largest systems
+			Buckets: prometheus.ExponentialBuckets(256, 4, 9),
+		},
+			[]string{"handler", "code", "method"},
+		),
+	}
+	reg.MustRegister(m.in_flight)
+	reg.MustRegister(m.requests)
+	reg.MustRegister(m.duration)
+	reg.MustRegister(m.response_size)
+
+	return m
+}
+
+// / Wrap handler so its requests are counted, timed and their responses
+// / sized under the given handler label.
+func (m *HTTPMetrics) Instrument(name string, handler http.Handler) http.Handler {
+	labels := prometheus.Labels{"handler": name}
+	return promhttp.InstrumentHandlerInFlight(m.in_flight,
+		promhttp.InstrumentHandlerDuration(m.duration.MustCurryWith(labels),
+			promhttp.InstrumentHandlerCounter(m.requests.MustCurryWith(labels),
+				promhttp.InstrumentHandlerResponseSize(m.response_size.MustCurryWith(labels), handler),
+			),
+		),
+	)
+}
+
+// / statusRecorder captures the status code and size of a response for the
+// / access log.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+func (r *statusRecorder) Flush() {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	http.NewResponseController(r.ResponseWriter).Flush()
+}
+
+// / Hijack is needed for WebSocket upgrades.
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(r.ResponseWriter).Hijack()
+	if err == nil {
+		r.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// / Wrap handler logging every request it serves in format: plain lines,
+// / logfmt or JSON for log pipelines to parse, or off. Structured logs carry
+// / the user agent and basic auth user, showing who scrapes the exporter.
+func logRequests(handler http.Handler, format string) (http.Handler, error) {
+	var logger *slog.Logger
+	switch format {
+	case "plain":
+	case "logfmt":
+		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	case "json":
+		logger = slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	case "off":
+		return handler, nil
+	default:
+		return nil, fmt.Errorf("unknown access log format %q, expected plain, logfmt, json or off", format)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		handler.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		if logger == nil {
+			log.Printf("%s %s %s %d %d %s\n", r.RemoteAddr, r.Method, r.URL.RequestURI(), rec.status, rec.bytes, time.Since(start))
+			return
+		}
+		attrs := []slog.Attr{
+			slog.String("remote", r.RemoteAddr),
+			slog.String("method", r.Method),
+			slog.String("uri", r.URL.RequestURI()),
+			// the route matched by the mux, set once it has served r
+			slog.String("pattern", r.Pattern),
+			slog.Int("status", rec.status),
+			slog.Int("bytes", rec.bytes),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("user_agent", r.UserAgent()),
+		}
+		if user, _, ok := r.BasicAuth(); ok {
+			attrs = append(attrs, slog.String("user", user))
+		}
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			attrs = append(attrs, slog.String("forwarded_for", forwarded))
+		}
+		logger.LogAttrs(r.Context(), slog.LevelInfo, "request", attrs...)
+	}), nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type InfluxDBConfig struct {
+	URL    string
+	Org    string
+	Bucket string
+	Token  string
+}
+
+// / InfluxDB is a Sink writing each cycle's station and bike measurements to
+// / an InfluxDB v2 bucket using the line protocol. Every metric becomes a
+// / measurement of the same name with its labels as tags and a single
+// / "value" field.
+type InfluxDB struct {
+	config InfluxDBConfig
+	client *http.Client
+}
+
+func NewInfluxDB(config InfluxDBConfig) *InfluxDB {
+	return &InfluxDB{config: config, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+func (i *InfluxDB) Name() string {
+	return "influxdb"
+}
+
+func (i *InfluxDB) Send(ctx context.Context, cycle *Cycle) error {
+	var body bytes.Buffer
+	for _, sample := range flattenFamilies(cycle.Families, cycle.Time) {
+		// the line protocol can't represent NaN or infinite field values
+		if isExporterMetric(sample.Name) || math.IsNaN(sample.Value) || math.IsInf(sample.Value, 0) {
+			continue
+		}
+		writeLine(&body, sample)
+	}
+
+	query := url.Values{}
+	query.Set("org", i.config.Org)
+	query.Set("bucket", i.config.Bucket)
+	query.Set("precision", "s")
+	endpoint := strings.TrimRight(i.config.URL, "/") + "/api/v2/write?" + query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if i.config.Token != "" {
+		req.Header.Set("Authorization", "Token "+i.config.Token)
+	}
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("server returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	tagEscaper         = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+)
+
+// / Append sample to buf as a line protocol point with second precision.
+func writeLine(buf *bytes.Buffer, sample Sample) {
+	buf.WriteString(measurementEscaper.Replace(sample.Name))
+	for _, l := range sample.Labels {
+		// the line protocol has no notion of empty tags
+		if l.Value == "" {
+			continue
+		}
+		buf.WriteByte(',')
+		buf.WriteString(tagEscaper.Replace(l.Name))
+		buf.WriteByte('=')
+		buf.WriteString(tagEscaper.Replace(l.Value))
+	}
+	buf.WriteString(" value=")
+	buf.WriteString(strconv.FormatFloat(sample.Value, 'g', -1, 64))
+	buf.WriteByte(' ')
+	buf.WriteString(strconv.FormatInt(sample.Timestamp.Unix(), 10))
+	buf.WriteByte('\n')
+}
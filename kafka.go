@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/patrickod/baywheels-exporter/pkg/gbfs"
+	"github.com/segmentio/kafka-go"
+)
+
+type KafkaConfig struct {
+	Brokers []string
+	Topic   string
+	// Mode is "station" to produce one message per station keyed by
+	// station_id, or "snapshot" to produce the whole cycle as one message.
+	Mode string
+}
+
+// / Kafka is a Sink producing the availability of every station to a Kafka
+// / topic after each cycle.
+type Kafka struct {
+	config KafkaConfig
+	writer *kafka.Writer
+}
+
+func NewKafka(config KafkaConfig) (*Kafka, error) {
+	if config.Mode != "station" && config.Mode != "snapshot" {
+		return nil, fmt.Errorf("unknown Kafka mode %q", config.Mode)
+	}
+	return &Kafka{
+		config: config,
+		writer: &kafka.Writer{
+			Addr:  kafka.TCP(config.Brokers...),
+			Topic: config.Topic,
+			// hash the key so each station's messages stay ordered within
+			// a single partition
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+			BatchTimeout: 100 * time.Millisecond,
+		},
+	}, nil
+}
+
+func (k *Kafka) Name() string {
+	return "kafka"
+}
+
+func (k *Kafka) Send(ctx context.Context, cycle *Cycle) error {
+	snapshot := cycle.Snapshot
+	if k.config.Mode == "snapshot" {
+		value, err := json.Marshal(struct {
+			LastUpdated time.Time         `json:"last_updated"`
+			Stations    []Station         `json:"stations"`
+			Bikes       []gbfs.BikeStatus `json:"bikes"`
+		}{snapshot.Time, snapshot.Stations, snapshot.Bikes})
+		if err != nil {
+			return err
+		}
+		return k.writer.WriteMessages(ctx, kafka.Message{Value: value, Time: snapshot.Time})
+	}
+
+	msgs := make([]kafka.Message, 0, len(snapshot.Stations))
+	for _, station := range snapshot.Stations {
+		value, err := json.Marshal(struct {
+			LastUpdated time.Time `json:"last_updated"`
+			Station
+		}{snapshot.Time, station})
+		if err != nil {
+			return err
+		}
+		msgs = append(msgs, kafka.Message{Key: []byte(station.StationId), Value: value, Time: snapshot.Time})
+	}
+	return k.writer.WriteMessages(ctx, msgs...)
+}
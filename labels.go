@@ -0,0 +1,39 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// / stationLabelSets interns the station_id and name labels of every
+// / station by id, so the collectors labelling per-station series every
+// / cycle share one prometheus.Labels map per station rather than each
+// / allocating their own. A renamed station replaces its entry, keeping the
+// / cache to one entry per station.
+var stationLabelSets = struct {
+	sync.Mutex
+	labels map[string]prometheus.Labels
+}{labels: make(map[string]prometheus.Labels)}
+
+// / stationVec is a metric vector labelled by stationLabels.
+type stationVec interface {
+	DeletePartialMatch(labels prometheus.Labels) int
+}
+
+// / Return the station_id and name labels of a station, naming stations
+// / without station_information "unknown" like the availability metrics.
+// / The returned map is shared and must not be modified.
+func stationLabels(id, name string) prometheus.Labels {
+	if name == "" {
+		name = "unknown"
+	}
+	stationLabelSets.Lock()
+	defer stationLabelSets.Unlock()
+	if labels, ok := stationLabelSets.labels[id]; ok && labels["name"] == name {
+		return labels
+	}
+	labels := prometheus.Labels{"station_id": id, "name": name}
+	stationLabelSets.labels[id] = labels
+	return labels
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/common/version"
+)
+
+// / Functions shared by the HTML pages' templates.
+var templateFuncs = template.FuncMap{
+	"ago": func(t time.Time) string {
+		if t.IsZero() {
+			return "never"
+		}
+		return time.Since(t).Round(time.Second).String() + " ago"
+	},
+	"rfc3339": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	},
+}
+
+var landingTemplate = template.Must(template.New("landing").Funcs(templateFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Baywheels Exporter</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { text-align: left; padding: 0.25em 1em 0.25em 0; }
+.error { color: #b00; }
+</style>
+</head>
+<body>
+<h1>Baywheels Exporter</h1>
+<p>Prometheus exporter for <a href="https://github.com/MobilityData/gbfs">GBFS</a> bikeshare feeds.</p>
+<ul>
+{{- range .Links }}
+<li><a href="{{ .Path }}">{{ .Path }}</a> &mdash; {{ .Description }}</li>
+{{- end }}
+</ul>
+<h2>Systems</h2>
+<p><a href="{{ .URL }}">{{ .URL }}</a></p>
+<table>
+<tr><th>Feed</th><th>Version</th><th>Last updated</th><th>TTL</th><th>Last success</th><th>Last attempt</th><th>Error</th></tr>
+{{- range .Feeds }}
+<tr>
+<td>{{ .Feed }}</td>
+<td>{{ .Version }}</td>
+<td title="{{ rfc3339 .LastUpdated }}">{{ ago .LastUpdated }}</td>
+<td>{{ .TTL }}s</td>
+<td title="{{ rfc3339 .LastSuccess }}">{{ ago .LastSuccess }}</td>
+<td title="{{ rfc3339 .LastAttempt }}">{{ ago .LastAttempt }}</td>
+<td class="error">{{ .LastError }}</td>
+</tr>
+{{- end }}
+</table>
+<h2>Version</h2>
+<pre>{{ .Version }}</pre>
+</body>
+</html>
+`))
+
+type LandingLink struct {
+	Path        string
+	Description string
+}
+
+// / LandingPage renders a human readable overview of the exporter at /.
+type LandingPage struct {
+	exporter *Exporter
+	Links    []LandingLink
+}
+
+func NewLandingPage(exporter *Exporter) *LandingPage {
+	return &LandingPage{
+		exporter: exporter,
+		Links: []LandingLink{
+			{Path: "/metrics", Description: "Prometheus metrics"},
+			{Path: "/map", Description: "Map of stations and free bikes"},
+			{Path: "/heatmap.png", Description: "PNG of the stations coloured by how full they are; add ?grid=32 to average over a grid"},
+			{Path: "/api/v1/stations", Description: "Current station information and status as JSON"},
+			{Path: "/api/v1/stations.geojson", Description: "Stations as a GeoJSON FeatureCollection; add ?bikes=true to include free bikes"},
+			{Path: "/api/v1/bikes", Description: "Current free bike status as JSON"},
+			{Path: "/api/v1/events?since=1h", Description: "Recent station and bike events, e.g. stations going empty, as JSON"},
+			{Path: "/conformance", Description: "Failures of the fetched feeds against GBFS validator rules"},
+			{Path: "/sd", Description: "Prometheus HTTP service discovery target for this exporter"},
+			{Path: "/ws", Description: "WebSocket stream of station availability changes"},
+			{Path: "/events", Description: "Server-Sent Events stream of station availability changes"},
+		},
+	}
+}
+
+func (p *LandingPage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	data := struct {
+		Links   []LandingLink
+		URL     string
+		Feeds   []FeedStatus
+		Version string
+	}{
+		Links:   p.Links,
+		URL:     p.exporter.URL,
+		Feeds:   p.exporter.status.Feeds(),
+		Version: version.Info() + "\n" + version.BuildContext(),
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := landingTemplate.Execute(w, data); err != nil {
+		log.Printf("Error rendering landing page %s\n", err)
+	}
+}
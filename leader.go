@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// / Interval between attempts to acquire or renew the leader lock.
+const leaderInterval = 5 * time.Second
+
+// / A Lock is held by at most one exporter replica at a time.
+type Lock interface {
+	Name() string
+	// Acquire the lock, or renew it if already held, returning whether it
+	// is held.
+	Acquire(ctx context.Context) (bool, error)
+	Release(ctx context.Context) error
+}
+
+// / Leader elects one of the exporter replicas sharing a Lock as the leader,
+// / so active/standby pairs can both sample and serve /metrics while only
+// / the leader pushes to sinks, avoiding duplicate writes. A standby takes
+// / over once the leader exits or stops renewing the lock.
+type Leader struct {
+	lock    Lock
+	leading atomic.Bool
+
+	leader prometheus.Gauge
+}
+
+func NewLeader(lock Lock, reg prometheus.Registerer) *Leader {
+	l := &Leader{
+		lock: lock,
+		leader: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "baywheels_exporter_leader",
+			Help: "Whether this exporter replica holds the leader lock and pushes to sinks",
+		}),
+	}
+	reg.MustRegister(l.leader)
+
+	return l
+}
+
+// / Return whether this replica is the leader.
+func (l *Leader) Leading() bool {
+	return l.leading.Load()
+}
+
+// / Keep contending for the lock after the first attempt until ctx is
+// / cancelled, then release it if held.
+func (l *Leader) Run(ctx context.Context) {
+	ticker := time.NewTicker(leaderInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			// ctx is done, so release with a fresh one
+			releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := l.lock.Release(releaseCtx); err != nil {
+				log.Printf("Error releasing %s leader lock %s\n", l.lock.Name(), err)
+			}
+			return
+		case <-ticker.C:
+		}
+		l.attempt(ctx)
+	}
+}
+
+// / Attempt to acquire or renew the lock.
+func (l *Leader) attempt(ctx context.Context) {
+	held, err := l.lock.Acquire(ctx)
+	if err != nil {
+		log.Printf("Error acquiring %s leader lock %s\n", l.lock.Name(), err)
+	}
+	if held && !l.Leading() {
+		log.Printf("Acquired %s leader lock, pushing to sinks\n", l.lock.Name())
+	} else if !held && l.Leading() {
+		log.Printf("Lost %s leader lock, standing by\n", l.lock.Name())
+	}
+	l.leading.Store(held)
+	if held {
+		l.leader.Set(1)
+	} else {
+		l.leader.Set(0)
+	}
+}
+
+// / Standby wraps a sink so it is skipped while this replica isn't the
+// / leader.
+func (l *Leader) Standby(sink Sink) Sink {
+	return &standbySink{Sink: sink, leader: l}
+}
+
+type standbySink struct {
+	Sink
+	leader *Leader
+}
+
+func (s *standbySink) Active() bool {
+	return s.leader.Leading()
+}
+
+// / ConsulLock is a Lock on a key of the Consul KV store, held through a
+// / session that Consul invalidates when it isn't renewed within its TTL,
+// / releasing the lock of a leader that died.
+type ConsulLock struct {
+	api     *consulAPI
+	key     string
+	session string
+}
+
+func NewConsulLock(config ConsulConfig, key string) *ConsulLock {
+	return &ConsulLock{api: newConsulAPI(config), key: key}
+}
+
+func (c *ConsulLock) Name() string {
+	return "consul"
+}
+
+func (c *ConsulLock) Acquire(ctx context.Context) (bool, error) {
+	if c.session != "" {
+		// a session that can't be renewed may have been invalidated
+		if err := c.api.put(ctx, "/v1/session/renew/"+c.session, nil, nil); err != nil {
+			c.session = ""
+		}
+	}
+	if c.session == "" {
+		var created struct{ ID string }
+		err := c.api.put(ctx, "/v1/session/create", map[string]string{
+			"Name":      "baywheels-exporter leader",
+			"TTL":       (3 * leaderInterval).String(),
+			"Behavior":  "release",
+			"LockDelay": "1s",
+		}, &created)
+		if err != nil {
+			return false, err
+		}
+		c.session = created.ID
+	}
+
+	hostname, _ := os.Hostname()
+	var held bool
+	err := c.api.put(ctx, "/v1/kv/"+c.key+"?acquire="+url.QueryEscape(c.session), hostname, &held)
+	return held, err
+}
+
+func (c *ConsulLock) Release(ctx context.Context) error {
+	if c.session == "" {
+		return nil
+	}
+	// destroying the session releases the lock
+	return c.api.put(ctx, "/v1/session/destroy/"+c.session, nil, nil)
+}
+
+// / FileLock is a Lock on a file, e.g. on storage shared by the replicas,
+// / held until the process holding it exits.
+type FileLock struct {
+	Path string
+
+	file *os.File
+}
+
+func (f *FileLock) Name() string {
+	return "file"
+}
+
+func (f *FileLock) Acquire(ctx context.Context) (bool, error) {
+	if f.file != nil {
+		return true, nil
+	}
+	file, err := os.OpenFile(f.Path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return false, err
+	}
+	held, err := tryLock(file)
+	if !held {
+		file.Close()
+		return false, err
+	}
+	f.file = file
+	return true, nil
+}
+
+func (f *FileLock) Release(ctx context.Context) error {
+	if f.file == nil {
+		return nil
+	}
+	// closing the file releases the lock
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
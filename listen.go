@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+)
+
+// / Listen on address, a unix socket given as unix:///path or else a TCP
+// / host:port. Unix sockets are created with
+// / mode, so a local reverse proxy can connect without the exporter opening a
+// / TCP port, replacing a socket left behind by a previous run.
+func listenOn(address string, mode fs.FileMode) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(address, "unix://"); ok {
+		if info, err := os.Stat(path); err == nil && info.Mode()&fs.ModeSocket != 0 {
+			if err := os.Remove(path); err != nil {
+				return nil, err
+			}
+		}
+		listener, err := net.Listen("unix", path)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			listener.Close()
+			return nil, err
+		}
+		return listener, nil
+	}
+	return net.Listen("tcp", address)
+}
+
+// / Return the first TCP address of addresses, e.g. to advertise to service
+// / discovery.
+func tcpAddress(addresses []string) (string, error) {
+	for _, address := range addresses {
+		if !strings.HasPrefix(address, "unix://") {
+			return address, nil
+		}
+	}
+	return "", errors.New("no TCP listen address")
+}
@@ -1,74 +1,39 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"flag"
 	"fmt"
-	"io"
+	"io/fs"
 	"log"
+	"log/slog"
+	"math/rand/v2"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/patrickod/baywheels-exporter/pkg/gbfs"
 	"github.com/prometheus/client_golang/prometheus"
+	versioncollector "github.com/prometheus/client_golang/prometheus/collectors/version"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/exporter-toolkit/web"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
 const BaywheelsURI = "https://gbfs.baywheels.com/gbfs/en"
 const ListenPort = 8080
+const SampleInterval = 60 * time.Second
 
-type StationInformation struct {
-	Name                        string  `json:"name"`
-	ShortName                   string  `json:"short_name"`
-	StationId                   string  `json:"station_id"`
-	StationType                 string  `json:"station_type"`
-	Lat                         float64 `json:"lat"`
-	Lon                         float64 `json:"lon"`
-	ExternalId                  string  `json:"external_id"`
-	Capacity                    int     `json:"capacity"`
-	HasKiosk                    bool    `json:"has_kiosk"`
-	ElectricBikeSurchargeWaiver bool    `json:"electric_bike_surcharge_waiver"`
-}
-
-type StationInformationResponse struct {
-	Data struct {
-		Stations []StationInformation `json:"stations"`
-	} `json:"data"`
-}
-
-type BikeStatus struct {
-	BikeId     string  `json:"bike_id"`
-	IsDisabled int     `json:"is_disabled"`
-	IsReserved int     `json:"is_reserved"`
-	Lat        float64 `json:"lat"`
-	Lon        float64 `json:"lon"`
-}
-
-type BikeStatusResponse struct {
-	Data struct {
-		Bikes []BikeStatus `json:"bikes"`
-	} `json:"data"`
-}
-
-type StationStatus struct {
-	StationId           string `json:"station_id"`
-	IsInstalled         int    `json:"is_installed"`
-	IsRenting           int    `json:"is_renting"`
-	IsReturning         int    `json:"is_returning"`
-	LastReported        int    `json:"last_reported"`
-	BikesAvailable      int    `json:"num_bikes_available"`
-	BikesDisabled       int    `json:"num_bikes_disabled"`
-	DocksAvailable      int    `json:"num_docks_available"`
-	DocksDisabled       int    `json:"num_docks_disabled"`
-	EBikesAvailable     int    `json:"num_ebikes_available"`
-	ScootersAvailable   int    `json:"num_scooters_available"`
-	ScootersUnavailable int    `json:"num_scooters_unavailable"`
-}
-
-type StationStatusResponse struct {
-	Data struct {
-		Stations []StationStatus `json:"stations"`
-	} `json:"data"`
-}
+// / sampledFeeds are the feeds every cycle fetches when due.
+var sampledFeeds = []string{"station_information", "station_status", "free_bike_status", "vehicle_types"}
 
 type BaywheelsMetrics struct {
 	station_capacity         prometheus.GaugeVec
@@ -83,6 +48,27 @@ type BaywheelsMetrics struct {
 	station_docks_available  prometheus.GaugeVec
 	station_docks_disabled   prometheus.GaugeVec
 	station_ebikes_available prometheus.GaugeVec
+	station_info             prometheus.GaugeVec
+
+	// station_status gauges of every station by id
+	stations map[string]*stationGauges
+}
+
+// / stationGauges are the station_status gauges of a station, resolved from
+// / their vectors when the station is first seen under its name and reused
+// / every cycle after, rather than looking them up by label values each time.
+type stationGauges struct {
+	name string
+
+	last_report      prometheus.Gauge
+	is_returning     prometheus.Gauge
+	is_renting       prometheus.Gauge
+	is_installed     prometheus.Gauge
+	bikes_available  prometheus.Gauge
+	bikes_disabled   prometheus.Gauge
+	docks_available  prometheus.Gauge
+	docks_disabled   prometheus.Gauge
+	ebikes_available prometheus.Gauge
 }
 
 func NewMetrics(reg prometheus.Registerer) *BaywheelsMetrics {
@@ -160,6 +146,13 @@ func NewMetrics(reg prometheus.Registerer) *BaywheelsMetrics {
 		},
 			[]string{"station_id", "name"},
 		),
+		station_info: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "station_info",
+			Help: "Always 1, labelled with the station's location for joining onto other metrics",
+		},
+			[]string{"station_id", "name", "lat", "lon"},
+		),
+		stations: make(map[string]*stationGauges),
 	}
 	reg.MustRegister(m.station_capacity)
 	reg.MustRegister(m.bike_disabled)
@@ -173,156 +166,1314 @@ func NewMetrics(reg prometheus.Registerer) *BaywheelsMetrics {
 	reg.MustRegister(m.station_docks_available)
 	reg.MustRegister(m.station_docks_disabled)
 	reg.MustRegister(m.station_ebikes_available)
+	reg.MustRegister(m.station_info)
 
 	return m
 }
 
-// / Sample the station information and return a map of station_id to station
-// / name that will be used to label other metrics.
-func sampleStationInformation(metrics *BaywheelsMetrics) map[string]string {
-	stationInformation, err := http.Get(fmt.Sprintf("%s/station_information.json", BaywheelsURI))
-	stationIdToName := make(map[string]string)
-	if err != nil {
-		log.Printf("Error sampling station information %s\n", err)
-		return stationIdToName
-	}
-	if stationInformation.StatusCode > 299 {
-		log.Printf("Error sampling station information %s\n", err)
-		return stationIdToName
+// / Return the metric families set from a feed.
+func (m *BaywheelsMetrics) families(feed string) []*prometheus.GaugeVec {
+	switch feed {
+	case "station_information":
+		return []*prometheus.GaugeVec{&m.station_capacity, &m.station_info}
+	case "station_status":
+		return []*prometheus.GaugeVec{
+			&m.station_last_report, &m.station_is_returning, &m.station_is_renting,
+			&m.station_is_installed, &m.station_bikes_available, &m.station_bikes_disabled,
+			&m.station_docks_available, &m.station_docks_disabled, &m.station_ebikes_available,
+		}
+	case "free_bike_status":
+		return []*prometheus.GaugeVec{&m.bike_disabled, &m.bike_reserved}
 	}
+	return nil
+}
 
-	body, err := io.ReadAll(stationInformation.Body)
-	defer stationInformation.Body.Close()
-	if err != nil {
-		log.Printf("Error sampling station information %s\n", err)
-		return stationIdToName
+// / Delete the series of a station labelled with a name it no longer has.
+func (m *BaywheelsMetrics) deleteStation(id, name string) {
+	labels := prometheus.Labels{"station_id": id, "name": name}
+	for _, feed := range []string{"station_information", "station_status"} {
+		for _, vec := range m.families(feed) {
+			vec.DeletePartialMatch(labels)
+		}
+	}
+	if gauges, ok := m.stations[id]; ok && gauges.name == name {
+		delete(m.stations, id)
 	}
+}
 
-	var response StationInformationResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		log.Printf("Error sampling station information %s\n", err)
-		return stationIdToName
-	} else {
-		for _, station := range response.Data.Stations {
-			// record the capacity metric
-			metrics.station_capacity.With(prometheus.Labels{"station_id": station.StationId, "name": station.Name}).Set(float64(station.Capacity))
+// / Delete the series set from a feed.
+func (m *BaywheelsMetrics) reset(feed string) {
+	for _, vec := range m.families(feed) {
+		vec.Reset()
+	}
+	if feed == "station_status" {
+		clear(m.stations)
+	}
+}
 
-			// map ID to name for later use
-			stationIdToName[station.StationId] = station.Name
+// / Return the station_status gauges of a station labelled with name.
+func (m *BaywheelsMetrics) station(id, name string) (*stationGauges, error) {
+	if gauges, ok := m.stations[id]; ok && gauges.name == name {
+		return gauges, nil
+	}
+	gauges := &stationGauges{name: name}
+	for _, child := range []struct {
+		gauge *prometheus.Gauge
+		vec   *prometheus.GaugeVec
+	}{
+		{&gauges.last_report, &m.station_last_report},
+		{&gauges.is_returning, &m.station_is_returning},
+		{&gauges.is_renting, &m.station_is_renting},
+		{&gauges.is_installed, &m.station_is_installed},
+		{&gauges.bikes_available, &m.station_bikes_available},
+		{&gauges.bikes_disabled, &m.station_bikes_disabled},
+		{&gauges.docks_available, &m.station_docks_available},
+		{&gauges.docks_disabled, &m.station_docks_disabled},
+		{&gauges.ebikes_available, &m.station_ebikes_available},
+	} {
+		var err error
+		if *child.gauge, err = child.vec.GetMetricWithLabelValues(id, name); err != nil {
+			return nil, err
 		}
 	}
+	m.stations[id] = gauges
+	return gauges, nil
+}
 
-	return stationIdToName
+// / Exporter samples a GBFS system and records the results as prometheus
+// / metrics.
+type Exporter struct {
+	URL string
+	// Sinks are handed the gathered metrics after every sampling cycle.
+	Sinks []Sink
+	// Changes publishes the stations whose availability changed each cycle.
+	Changes *ChangeFeed
+	// Events buffers the most recent events for /api/v1/events.
+	Events *EventBuffer
+	// Trends keeps recent availability per station when set.
+	Trends *Trends
+	// Systemd is notified of every cycle when run under systemd.
+	Systemd *Systemd
+	// Now returns the time of a sampling cycle; the replayed time when
+	// replaying recorded feeds.
+	Now func() time.Time
+	// FailurePolicy is what happens to the metrics of a feed that failed
+	// to fetch: "hold" keeps serving its last values, "clear" deletes them
+	// and "ttl" holds them for HoldTTL after the last success, then
+	// deletes them.
+	FailurePolicy string
+	HoldTTL       time.Duration
+	// Intervals is the minimum time between fetches of each feed, for feeds
+	// that change less often than others. Feeds without one are fetched
+	// every cycle.
+	Intervals map[string]time.Duration
+	// Shard limits the stations and bikes sampled to those owned by this
+	// replica.
+	Shard Shard
+	// Closure, if set, pauses sampling the status feeds while the system is
+	// closed.
+	Closure *Closure
+	// Stagger spreads the fetches of a cycle evenly over this long rather
+	// than making them back to back.
+	Stagger time.Duration
+
+	gatherer     prometheus.Gatherer
+	metrics      *BaywheelsMetrics
+	trips        *TripMetrics
+	fleet        *BikeMetrics
+	occupancy    *OccupancyMetrics
+	battery      *BatteryMetrics
+	zones        *ZoneMetrics
+	pois         *POIMetrics
+	expected     *ExpectedMetrics
+	daily        *DailyMetrics
+	stale        *StaleMetrics
+	conformance  *Conformance
+	corridors    *CorridorMetrics
+	slo          *SLOMetrics
+	elevation    *ElevationMetrics
+	vehicleTypes *VehicleTypeMetrics
+	clock        *ClockMetrics
+	sink_metrics *SinkMetrics
+	status       *ScrapeStatus
+	names        *StationNames
+
+	client *gbfs.Client
+
+	// last successfully fetched payload of each feed
+	information []gbfs.StationInformation
+	statuses    []gbfs.StationStatus
+	bikes       []gbfs.BikeStatus
+	types       []gbfs.VehicleType
+	snapshot    atomic.Pointer[Snapshot]
+	cycles      atomic.Int64
+	// when each feed was last fetched successfully, and last attempted
+	fetchedAt map[string]time.Time
+	triedAt   map[string]time.Time
 }
 
-func sampleFreeBikeStatus(metrics *BaywheelsMetrics) {
-	freeBikeStatus, err := http.Get(fmt.Sprintf("%s/free_bike_status.json", BaywheelsURI))
-	if err != nil {
-		log.Printf("Error sampling bike status %s\n", err)
-		return
+// / Create an Exporter registering its metrics with registry. A non-empty
+// / namespace is prepended to the station and bike metric names.
+func NewExporter(url string, registry *prometheus.Registry, namespace string) *Exporter {
+	var reg prometheus.Registerer = registry
+	if namespace != "" {
+		reg = prometheus.WrapRegistererWithPrefix(namespace+"_", registry)
+	}
+	status := NewScrapeStatus()
+	registry.MustRegister(status)
+	return &Exporter{
+		URL:           url,
+		client:        gbfs.NewClient(url, nil),
+		Changes:       NewChangeFeed(),
+		Events:        NewEventBuffer(eventBufferSize),
+		Now:           time.Now,
+		FailurePolicy: "hold",
+		Intervals:     make(map[string]time.Duration),
+		fetchedAt:     make(map[string]time.Time),
+		triedAt:       make(map[string]time.Time),
+		gatherer:      registry,
+		metrics:       NewMetrics(reg),
+		trips:         NewTripMetrics(reg),
+		fleet:         NewBikeMetrics(reg),
+		occupancy:     NewOccupancyMetrics(reg),
+		battery:       NewBatteryMetrics(reg),
+		zones:         NewZoneMetrics(reg),
+		pois:          NewPOIMetrics(reg),
+		expected:      NewExpectedMetrics(reg),
+		daily:         NewDailyMetrics(reg),
+		stale:         NewStaleMetrics(reg),
+		conformance:   NewConformance(registry),
+		corridors:     NewCorridorMetrics(reg),
+		slo:           NewSLOMetrics(reg),
+		elevation:     NewElevationMetrics(reg),
+		vehicleTypes:  NewVehicleTypeMetrics(reg),
+		clock:         NewClockMetrics(registry),
+		sink_metrics:  NewSinkMetrics(registry),
+		status:        status,
+		names:         NewStationNames(),
 	}
-	if freeBikeStatus.StatusCode > 299 {
-		log.Printf("Error sampling free bike status %s\n", freeBikeStatus.Status)
+}
+
+// / Record the outcome of fetching a feed so it can be reported on the
+// / landing page and as metrics, and apply the FailurePolicy if it failed.
+func (e *Exporter) record(feed string, err error) {
+	e.status.Record(feed, err)
+	if err == nil {
 		return
 	}
+	drop := e.FailurePolicy == "clear"
+	if e.FailurePolicy == "ttl" {
+		drop = time.Since(e.status.LastSuccess(feed)) > e.HoldTTL
+	}
+	if drop {
+		e.metrics.reset(feed)
+	}
+}
 
-	body, err := io.ReadAll(freeBikeStatus.Body)
-	defer freeBikeStatus.Body.Close()
+// / Return the vectors of the collectors labelled by stationLabels, whose
+// / series of a station are deleted when its name changes so they don't
+// / linger under the old name or "unknown".
+func (e *Exporter) stationVecs() []stationVec {
+	return []stationVec{
+		&e.trips.station_bikes_departed_total, &e.trips.station_bikes_arrived_total,
+		&e.trips.station_outflow_rate, &e.trips.station_inflow_rate,
+		&e.trips.station_dock_changes_total, &e.trips.station_dock_turnover_rate,
+		&e.trips.station_rebalancing_events, &e.trips.station_last_rebalancing,
+		&e.occupancy.station_empty_seconds_total, &e.occupancy.station_full_seconds_total,
+		&e.stale.station_stale, &e.stale.station_stale_episodes_total,
+		&e.expected.station_bikes_expected, &e.expected.station_bikes_deviation,
+		&e.daily.station_bikes_available_daily_min, &e.daily.station_bikes_available_daily_max,
+		&e.slo.station_availability_ratio, &e.slo.station_bike_availability_ratio,
+		&e.slo.station_dock_availability_ratio,
+		&e.elevation.station_elevation_meters,
+	}
+}
+
+// / Sample the station information, updating the station names that will be
+// / used to label other metrics.
+func (e *Exporter) sampleStationInformation(ctx context.Context) ([]gbfs.StationInformation, error) {
+	response, err := e.client.StationInformation(ctx)
+	e.record("station_information", err)
 	if err != nil {
-		log.Printf("Error sampling bike status %s\n", err)
-		return
+		return nil, err
+	}
+	e.status.RecordEnvelope("station_information", response.LastUpdated.Time, response.TTL, response.Version)
+	stations, duplicates := dedupe(response.Data.Stations, func(s *gbfs.StationInformation) string { return s.StationId })
+	e.status.RecordDuplicates("station_information", duplicates)
+	stations = owned(e.Shard, stations, func(s *gbfs.StationInformation) string { return s.StationId })
+	for i := range stations {
+		stations[i].Name = gbfs.LocalizedString(e.names.normalize(string(stations[i].Name)))
 	}
 
-	var response BikeStatusResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		log.Printf("Error sampling bike status %s\n", err)
-		return
-	} else {
-		for _, bike := range response.Data.Bikes {
-			metrics.bike_disabled.With(prometheus.Labels{"bike_id": bike.BikeId}).Set(float64(bike.IsDisabled))
-			metrics.bike_reserved.With(prometheus.Labels{"bike_id": bike.BikeId}).Set(float64(bike.IsReserved))
+	// move the series of renamed stations, and those labelled "unknown"
+	// before their name was known, to their new name
+	changed, err := e.names.Update(stations)
+	if err != nil {
+		log.Printf("Error saving station names %s\n", err)
+	}
+	for id, previous := range changed {
+		e.metrics.deleteStation(id, previous)
+		for _, vec := range e.stationVecs() {
+			vec.DeletePartialMatch(prometheus.Labels{"station_id": id})
+		}
+	}
+	// stations only recased keep their earlier name
+	for i := range stations {
+		if stations[i].Name != "" {
+			stations[i].Name = gbfs.LocalizedString(e.names.Name(stations[i].StationId))
 		}
 	}
+
+	for _, station := range stations {
+		// record the capacity metric
+		e.metrics.station_capacity.WithLabelValues(station.StationId, string(station.Name)).Set(float64(station.Capacity))
+		e.metrics.station_info.WithLabelValues(
+			station.StationId,
+			string(station.Name),
+			strconv.FormatFloat(station.Lat, 'f', -1, 64),
+			strconv.FormatFloat(station.Lon, 'f', -1, 64),
+		).Set(1)
+	}
+
+	return stations, nil
 }
 
-func sampleStationStatus(metrics *BaywheelsMetrics, stationIdToName map[string]string) {
-	stationStatus, err := http.Get(fmt.Sprintf("%s/station_status.json", BaywheelsURI))
+func (e *Exporter) sampleFreeBikeStatus(ctx context.Context) ([]gbfs.BikeStatus, error) {
+	response, err := e.client.FreeBikeStatus(ctx)
+	e.record("free_bike_status", err)
 	if err != nil {
-		log.Printf("Error sampling station status %s\n", err)
-		return
+		return nil, err
 	}
-	if stationStatus.StatusCode > 299 {
-		log.Printf("Error sampling station status %s\n", stationStatus.Status)
-		return
+	e.status.RecordEnvelope("free_bike_status", response.LastUpdated.Time, response.TTL, response.Version)
+	bikes, duplicates := dedupe(response.Data.Bikes, func(b *gbfs.BikeStatus) string { return b.BikeId })
+	e.status.RecordDuplicates("free_bike_status", duplicates)
+	bikes = owned(e.Shard, bikes, func(b *gbfs.BikeStatus) string { return b.BikeId })
+
+	for _, bike := range bikes {
+		e.metrics.bike_disabled.WithLabelValues(bike.BikeId).Set(float64(bike.IsDisabled))
+		e.metrics.bike_reserved.WithLabelValues(bike.BikeId).Set(float64(bike.IsReserved))
+	}
+
+	return bikes, nil
+}
+
+func (e *Exporter) sampleVehicleTypes(ctx context.Context) ([]gbfs.VehicleType, error) {
+	response, err := e.client.VehicleTypes(ctx)
+	e.record("vehicle_types", err)
+	if err != nil {
+		return nil, err
 	}
+	e.status.RecordEnvelope("vehicle_types", response.LastUpdated.Time, response.TTL, response.Version)
+	return response.Data.VehicleTypes, nil
+}
 
-	body, err := io.ReadAll(stationStatus.Body)
-	defer stationStatus.Body.Close()
+func (e *Exporter) sampleStationStatus(ctx context.Context) ([]gbfs.StationStatus, error) {
+	response, err := e.client.StationStatus(ctx)
+	e.record("station_status", err)
 	if err != nil {
-		log.Printf("Error sampling station status %s\n", err)
-		return
+		return nil, err
 	}
+	e.status.RecordEnvelope("station_status", response.LastUpdated.Time, response.TTL, response.Version)
+	stations, duplicates := dedupe(response.Data.Stations, func(s *gbfs.StationStatus) string { return s.StationId })
+	e.status.RecordDuplicates("station_status", duplicates)
+	stations = owned(e.Shard, stations, func(s *gbfs.StationStatus) string { return s.StationId })
 
-	var response StationStatusResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		log.Printf("Error sampling station status %s\n", err)
-		return
-	} else {
-		for _, station := range response.Data.Stations {
-			// get human readable station name
-			stationName, ok := stationIdToName[station.StationId]
-			if !ok {
-				stationName = "unknown"
+	for _, station := range stations {
+		// get human readable station name
+		gauges, err := e.metrics.station(station.StationId, e.names.Name(station.StationId))
+		if err != nil {
+			log.Printf("Error labelling station %s %s\n", station.StationId, err)
+			continue
+		}
+
+		// station stats
+		gauges.last_report.Set(float64(station.LastReported.Seconds()))
+		gauges.is_returning.Set(float64(station.IsReturning))
+		gauges.is_renting.Set(float64(station.IsRenting))
+		gauges.is_installed.Set(float64(station.IsInstalled))
+
+		// pedal bike stats
+		gauges.bikes_available.Set(float64(station.BikesAvailable))
+		gauges.bikes_disabled.Set(float64(station.BikesDisabled))
+
+		// dock stats
+		gauges.docks_available.Set(float64(station.DocksAvailable))
+		gauges.docks_disabled.Set(float64(station.DocksDisabled))
+
+		// e-bike stats
+		gauges.ebikes_available.Set(float64(station.EBikesAvailable))
+	}
+
+	return stations, nil
+}
+
+func (e *Exporter) Sample(ctx context.Context) {
+	log.Println("Sampling GBFS API")
+	cycle := &Cycle{Errors: make(map[string]error), Fetched: make(map[string]bool)}
+	defer e.cycles.Add(1)
+	ctx, span := tracer.Start(ctx, "cycle")
+	defer func() {
+		span.SetAttributes(
+			attribute.Bool("baywheels.closed", cycle.Closed),
+			attribute.Int("baywheels.feeds_fetched", len(cycle.Fetched)),
+			attribute.Int("baywheels.feeds_failed", len(cycle.Errors)),
+		)
+		if len(cycle.Errors) > 0 {
+			span.SetStatus(codes.Error, fmt.Sprintf("%d feeds failed", len(cycle.Errors)))
+		}
+		span.End()
+	}()
+	now := e.Now()
+	fetches := 0
+	stagger := func() {
+		if fetches > 0 && e.Stagger > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(e.Stagger / time.Duration(len(sampledFeeds))):
 			}
+		}
+		fetches++
+	}
 
-			// station stats
-			metrics.station_last_report.With(prometheus.Labels{"station_id": station.StationId, "name": stationName}).Set(float64(station.LastReported))
-			metrics.station_is_returning.With(prometheus.Labels{"station_id": station.StationId, "name": stationName}).Set(float64(station.IsReturning))
-			metrics.station_is_renting.With(prometheus.Labels{"station_id": station.StationId, "name": stationName}).Set(float64(station.IsRenting))
-			metrics.station_is_installed.With(prometheus.Labels{"station_id": station.StationId, "name": stationName}).Set(float64(station.IsInstalled))
+	if e.due("station_information", now) {
+		stagger()
+		e.tried("station_information", now)
+		if information, err := e.sampleStationInformation(ctx); err != nil {
+			log.Printf("Error sampling station information %s\n", err)
+			cycle.Errors["station_information"] = err
+		} else {
+			e.information = information
+			e.fetched(cycle, "station_information", now)
+		}
+	}
+	cycle.Closed = e.Closure != nil && e.Closure.Check(ctx, e.client, now)
+	if !cycle.Closed && e.due("station_status", now) {
+		stagger()
+		e.tried("station_status", now)
+		if statuses, err := e.sampleStationStatus(ctx); err != nil {
+			log.Printf("Error sampling station status %s\n", err)
+			cycle.Errors["station_status"] = err
+		} else {
+			e.statuses = statuses
+			e.fetched(cycle, "station_status", now)
+		}
+	}
+	if !cycle.Closed && e.due("free_bike_status", now) {
+		stagger()
+		e.tried("free_bike_status", now)
+		if bikes, err := e.sampleFreeBikeStatus(ctx); err != nil {
+			log.Printf("Error sampling bike status %s\n", err)
+			cycle.Errors["free_bike_status"] = err
+		} else {
+			e.bikes = bikes
+			e.fetched(cycle, "free_bike_status", now)
+		}
+	}
+	// while closed the status feeds are retried along with the closure check
+	for _, feed := range []string{"station_status", "free_bike_status"} {
+		if cycle.Closed && e.due(feed, now) {
+			e.tried(feed, now)
+		}
+	}
+	if e.due("vehicle_types", now) {
+		stagger()
+		e.tried("vehicle_types", now)
+		if types, err := e.sampleVehicleTypes(ctx); err != nil {
+			log.Printf("Error sampling vehicle types %s\n", err)
+			cycle.Errors["vehicle_types"] = err
+		} else {
+			e.types = types
+			e.fetched(cycle, "vehicle_types", now)
+		}
+	}
 
-			// pedal bike stats
-			metrics.station_bikes_available.With(prometheus.Labels{"station_id": station.StationId, "name": stationName}).Set(float64(station.BikesAvailable))
-			metrics.station_bikes_disabled.With(prometheus.Labels{"station_id": station.StationId, "name": stationName}).Set(float64(station.BikesDisabled))
+	// feeds that failed or weren't due this cycle are represented by their
+	// last good payload so the API doesn't flap between empty and populated
+	cycle.Time = e.Now()
+	cycle.Snapshot = NewSnapshot(cycle.Time, e.information, e.statuses, e.bikes)
+	e.names.fill(cycle.Snapshot.Stations)
+	prev := e.snapshot.Swap(cycle.Snapshot)
+	cycle.Changes = diffStations(prev, cycle.Snapshot)
+	cycle.BikeEvents = diffBikes(prev, cycle.Snapshot)
+	cycle.Events = detectEvents(prev, cycle.Snapshot, cycle.Changes, e.trips.RebalancingThreshold)
+	// statuses held over a closure or a failed fetch aren't new samples of
+	// the stations, and mustn't count towards their time empty, trips or
+	// availability
+	fresh := cycle.Fetched["station_status"]
+	held := cycle.Closed || cycle.Errors["station_status"] != nil
+	if fresh {
+		e.trips.Observe(cycle.Snapshot, cycle.Changes)
+		e.corridors.Observe(e.trips)
+		e.expected.Observe(cycle.Snapshot)
+		e.slo.Observe(cycle.Snapshot)
+	}
+	e.fleet.Observe(cycle.Snapshot)
+	e.occupancy.Observe(cycle.Snapshot, fresh, held)
+	e.battery.Observe(cycle.Snapshot)
+	e.zones.Observe(cycle.Snapshot)
+	e.pois.Observe(cycle.Snapshot)
+	e.daily.Observe(cycle.Snapshot)
+	e.elevation.Observe(ctx, cycle.Snapshot)
+	e.vehicleTypes.Observe(cycle.Snapshot, e.types)
+	e.stale.Observe(cycle.Snapshot, fresh)
+	e.conformance.Observe(cycle.Snapshot, e.types)
+	e.clock.Observe(cycle, e.status.Feeds())
+	e.Changes.Publish(cycle.Changes)
+	e.Events.Record(cycle.Events)
+	if e.Trends != nil {
+		e.Trends.Record(cycle.Snapshot)
+	}
+	if e.Systemd != nil {
+		e.Systemd.Observe(cycle)
+	}
 
-			// dock stats
-			metrics.station_docks_available.With(prometheus.Labels{"station_id": station.StationId, "name": stationName}).Set(float64(station.DocksAvailable))
-			metrics.station_docks_disabled.With(prometheus.Labels{"station_id": station.StationId, "name": stationName}).Set(float64(station.DocksDisabled))
+	if len(e.Sinks) == 0 {
+		return
+	}
+	var err error
+	if cycle.Families, err = e.gatherer.Gather(); err != nil {
+		log.Printf("Error gathering metrics for sinks %s\n", err)
+		return
+	}
+	dispatch(ctx, e.sink_metrics, e.Sinks, cycle)
+}
 
-			// e-bike stats
-			metrics.station_ebikes_available.With(prometheus.Labels{"station_id": station.StationId, "name": stationName}).Set(float64(station.EBikesAvailable))
+// / Return the shortest TTL the sampled feeds report their data stays valid
+// / for, or 0 if none do.
+func (e *Exporter) TTL() time.Duration {
+	var ttl time.Duration
+	for _, status := range e.status.Feeds() {
+		feedTTL := time.Duration(status.TTL) * time.Second
+		if feedTTL > 0 && (ttl == 0 || feedTTL < ttl) {
+			ttl = feedTTL
 		}
 	}
+	return ttl
 }
 
-func sampleBaywheelsMetrics(metrics *BaywheelsMetrics) {
-	log.Println("Sampling GBFS API")
-	stationIdToName := sampleStationInformation(metrics)
-	sampleStationStatus(metrics, stationIdToName)
-	sampleFreeBikeStatus(metrics)
+// / Report whether a feed's interval has passed since it was last fetched.
+// / Feeds without an interval are fetched every cycle.
+func (e *Exporter) due(feed string, now time.Time) bool {
+	// allow for rounding when a replay scales the wait
+	return !now.Add(time.Millisecond).Before(e.nextDue(feed))
+}
+
+// / Return when a feed is next due: its interval after it was last fetched
+// / or, once an attempt has failed or was skipped while the system is
+// / closed, the shortest interval of any feed after that attempt.
+func (e *Exporter) nextDue(feed string) time.Time {
+	tried := e.triedAt[feed]
+	if !tried.After(e.fetchedAt[feed]) {
+		return e.fetchedAt[feed].Add(e.Intervals[feed])
+	}
+	retry := e.Intervals[feed]
+	for _, interval := range e.Intervals {
+		retry = min(retry, interval)
+	}
+	return tried.Add(retry)
+}
+
+// / Return the time from now until the next feed is due, which the sampling
+// / loop waits before the next cycle so each feed keeps its own interval.
+func (e *Exporter) Wait(now time.Time) time.Duration {
+	wait := time.Duration(-1)
+	for feed := range e.Intervals {
+		if d := e.nextDue(feed).Sub(now); wait < 0 || d < wait {
+			wait = d
+		}
+	}
+	return max(wait, 0)
+}
+
+func (e *Exporter) tried(feed string, now time.Time) {
+	e.triedAt[feed] = now
+}
+
+func (e *Exporter) fetched(cycle *Cycle, feed string, now time.Time) {
+	cycle.Fetched[feed] = true
+	e.fetchedAt[feed] = now
+}
+
+// / Return the Snapshot of the most recent sampling cycle, or nil before the
+// / first cycle has completed.
+func (e *Exporter) Snapshot() *Snapshot {
+	return e.snapshot.Load()
+}
+
+// / Subcommands run instead of the exporter when named as the first argument.
+var commands = map[string]func(args []string) error{
+	"backfill":          runBackfill,
+	"bench":             runBench,
+	"capture":           runCapture,
+	"dump":              runDump,
+	"federate":          runFederate,
+	"grafana-dashboard": runGrafanaDashboard,
+	"mock-server":       runMockServer,
+	"nearest":           runNearest,
+	"rules":             runRules,
+	"service":           runService,
+	"systems":           runSystems,
+	"top":               runTop,
+	"watch":             runWatch,
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			if err := command(os.Args[2:]); err != nil {
+				log.Fatalf("Error running %s %s\n", os.Args[1], err)
+			}
+			return
+		}
+	}
+	// ctx is cancelled on SIGINT or SIGTERM, or when the Windows service is
+	// stopped, after which the process exits once the goroutines tracked by
+	// shutdown have cleaned up
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	stopped := startService(stop)
+	var shutdown sync.WaitGroup
+	go func() {
+		<-ctx.Done()
+		// a second signal exits right away
+		stop()
+		shutdown.Wait()
+		stopped()
+		os.Exit(0)
+	}()
+
 	registry := prometheus.NewRegistry()
-	metrics := NewMetrics(registry)
-	ticker := time.NewTicker(60 * time.Second)
+	registry.MustRegister(versioncollector.NewCollector("baywheels_exporter"))
+
+	listen := stringsFlag{values: []string{":9100"}}
+	flag.Var(&listen, "listen", "Listen address, as host:port or unix:///path/to/socket; may be repeated to listen on several")
+	socketMode := flag.String("web.socket-mode", "0660", "Permissions of the unix sockets listened on, in octal")
+	gbfsURL := flag.String("gbfs.url", BaywheelsURI, "Base URL of the GBFS feeds to sample, or the URL of the system's gbfs.json to discover them")
+	discover := flag.String("gbfs.discover", "", "Sample the one system in the MobilityData catalog matching this filter instead of -gbfs.url, e.g. country=US,city=San Francisco")
+	catalogURL := flag.String("gbfs.catalog-url", CatalogURL, "URL of the MobilityData systems.csv catalog -gbfs.discover searches")
+	var upstream UpstreamAuth
+	upstream.Flags(flag.CommandLine)
+	configFile := flag.String("config.file", "", "Path to a YAML configuration file for webhooks and other settings that don't fit in flags")
+	systemdSocket := flag.Bool("web.systemd-socket", false, "Serve on the sockets passed by systemd socket activation instead of -listen; the default when started by a systemd socket unit")
+	watchdogStall := flag.Duration("systemd.watchdog-stall", 5*time.Minute, "Stop pinging the systemd watchdog when no sampling cycle has completed for this long after it was due")
+	sdTarget := flag.String("sd.target", "", "host:port Prometheus should scrape, as listed on /sd; the host /sd is requested on if empty")
+	accessLog := flag.String("web.access-log", "plain", "Format of the access log of the HTTP server: plain, logfmt, json or off")
+	webConfig := flag.String("web.config.file", "", "Path to an exporter-toolkit web configuration file enabling TLS or authentication")
+	fluxWindow := flag.Duration("trips.window", time.Hour, "Sliding window the station inflow and outflow rates are averaged over")
+	rebalancingThreshold := flag.Int("trips.rebalancing-threshold", 8, "Change in a station's bikes available within one sample counted as rebalancing rather than trips, 0 to disable")
+	idleThreshold := flag.Duration("bikes.idle-threshold", 24*time.Hour, "How long a free bike must stay at the same coordinates to be counted as idle")
+	zonesFile := flag.String("zones.file", "", "GeoJSON file of Polygon or MultiPolygon features to aggregate availability into")
+	zonesName := flag.String("zones.name-property", "name", "Feature property holding the name of each zone in -zones.file")
+	expectedAlpha := flag.Float64("expected.alpha", 0.2, "Weight of each day's mean of an hour in the moving average of station_bikes_expected, between 0 and 1")
+	timezone := flag.String("timezone", "", "IANA time zone of the system, e.g. America/Los_Angeles, for the hours of the day; the host's if empty")
+	dailyReset := flag.String("daily.reset", "00:00", "Time of day, as HH:MM in -timezone, the daily minimum and maximum availability start over")
+	staleSamples := flag.Int("stale.samples", 10, "Consecutive samples without last_reported advancing after which a station is flagged by station_stale, 0 to disable")
+	ghostThreshold := flag.Duration("bikes.ghost-threshold", 72*time.Hour, "How long a free bike must stay disabled to be counted as a ghost bike")
+	ghostBikes := flag.Bool("bikes.ghost-per-bike", false, "Export how long each ghost bike has been disabled as bike_ghost_disabled_seconds")
+	sloWindow := flag.Duration("slo.window", 24*time.Hour, "Rolling window of the station availability ratios")
+	elevationDEM := flag.String("elevation.dem", "", "ESRI ASCII grid elevation model in degrees to look up station elevations in")
+	elevationURL := flag.String("elevation.url", "", "Open-Meteo compatible elevation API to look up station elevations with, e.g. https://api.open-meteo.com/v1/elevation")
+	elevationCache := flag.String("elevation.cache", "elevations.json", "File caching the elevations looked up with -elevation.url")
+	replayDir := flag.String("replay.dir", "", "Sample the feeds recorded in this -record.dir instead of the live API")
+	replaySpeed := flag.Float64("replay.speed", 1, "How many times faster than real time to replay -replay.dir")
+	replayLoop := flag.Bool("replay.loop", false, "Start over after replaying the last recorded feeds rather than holding them")
+	recordDir := flag.String("record.dir", "", "Directory to write the raw body of every fetched feed to, as <feed>/<time>.json")
+	recordRawRetention := flag.Duration("record.raw-retention", 0, "Downsample files in -record.dir older than this to one per hour and feed; kept as recorded if zero")
+	recordRetention := flag.Duration("record.retention", 0, "Delete files in -record.dir older than this; kept forever if zero")
+	recordCompress := flag.Bool("record.compress", false, "Gzip the files written to -record.dir")
+	proxyEnabled := flag.Bool("proxy.enabled", false, "Serve the raw body of every feed last fetched under /gbfs/, for other local consumers of the feeds")
+	sampleInterval := flag.Duration("sample.interval", SampleInterval, "How often to sample the feeds, unless overridden per feed")
+	feedIntervals := map[string]*time.Duration{
+		"station_information": flag.Duration("sample.station-information-interval", 0, "How often to sample station_information; -sample.interval if zero"),
+		"station_status":      flag.Duration("sample.station-status-interval", 0, "How often to sample station_status; -sample.interval if zero"),
+		"free_bike_status":    flag.Duration("sample.free-bike-status-interval", 0, "How often to sample free_bike_status; -sample.interval if zero"),
+		"vehicle_types":       flag.Duration("sample.vehicle-types-interval", 0, "How often to sample vehicle_types; -sample.interval if zero"),
+	}
+	schedule := &Schedule{}
+	flag.Var(schedule, "sample.schedule", "Sample at another interval during a daily window, as [days] HH:MM-HH:MM interval, e.g. \"mon-fri 07:00-10:00 15s\"; may be repeated")
+	scheduleTimezone := flag.String("sample.schedule-timezone", "", "IANA time zone of the -sample.schedule windows, e.g. America/Los_Angeles; local time if empty")
+	closureHours := flag.Bool("closure.system-hours", false, "Pause sampling station_status and free_bike_status outside the rental hours in system_hours")
+	closureAlerts := flag.Bool("closure.system-alerts", false, "Pause sampling station_status and free_bike_status during a system_closure alert in system_alerts")
+	var closureWindows windowsFlag
+	flag.Var(&closureWindows, "closure.window", "Pause sampling station_status and free_bike_status during this daily window, as [days] HH:MM-HH:MM; may be repeated")
+	closureTimezone := flag.String("closure.timezone", "", "IANA time zone of the -closure.window windows; local time if empty")
+	jitter := flag.Duration("sample.jitter", 0, "Wait a random time up to this long before the first cycle, so exporters started together don't fetch at the same second")
+	stagger := flag.Duration("sample.stagger", 0, "Spread the feed fetches of each cycle evenly over this long; must be shorter than the shortest interval")
+	respectTTL := flag.Bool("sample.respect-ttl", false, "Wait for the shortest TTL the feeds report before sampling again, if longer than the sampling interval")
+	strict := flag.Bool("strict", false, "Reject feeds with missing required fields, invalid types or out of range values rather than exporting zeros for them")
+	validate := flag.Bool("validate", false, "Validate every fetched feed against its GBFS schema, counting the problems and checking them on /conformance; implied by -strict")
+	var shard Shard
+	flag.Var(&shard, "shard", "Only export the stations and bikes hashed to this shard, as index/count, e.g. 2/5 for the second of five replicas")
+	failurePolicy := flag.String("feed.failure-policy", "hold", "What to do with the metrics of a feed that fails to fetch: hold its last values, clear them, or ttl to hold them for -feed.hold-ttl")
+	holdTTL := flag.Duration("feed.hold-ttl", 5*time.Minute, "How long -feed.failure-policy=ttl holds the last values of a failing feed")
+	skewWarning := flag.Duration("clock.skew-warning", 30*time.Second, "Log a warning when feed timestamps are this far ahead of local time, 0 to disable")
+	namesNormalize := flag.Bool("names.normalize", false, "Trim and collapse whitespace in station names and ignore changes to their casing, for stable name labels")
+	namesTransliterate := flag.Bool("names.transliterate", false, "Strip accents from station names")
+	namesFile := flag.String("names.file", "", "File to save the last known station names to, so they survive restarts while station_information is failing")
+	debugEnabled := flag.Bool("debug", false, "Serve pprof and runtime debug endpoints")
+	debugListen := flag.String("debug.listen", "localhost:6060", "Listen address for the debug endpoints")
+	grpcListen := flag.String("grpc.listen", "", "Listen address for the gRPC API; disabled if empty")
+	namespace := flag.String("metrics.namespace", "", "Namespace prepended to station and bike metric names, e.g. baywheels")
+	trend := flag.Duration("station.trend", 6*time.Hour, "How much recent availability to keep in memory per station for the /station pages")
+	serve := flag.Bool("serve", true, "Serve /metrics over HTTP; disable to only push to the configured sinks")
+
+	remoteWrite := RemoteWriteConfig{Labels: labelsFlag{}}
+	flag.StringVar(&remoteWrite.URL, "remote-write.url", "", "Prometheus remote_write endpoint to push every sampling cycle to")
+	flag.StringVar(&remoteWrite.Username, "remote-write.username", "", "Basic auth username for the remote_write endpoint")
+	flag.StringVar(&remoteWrite.Password, "remote-write.password", "", "Basic auth password for the remote_write endpoint")
+	flag.StringVar(&remoteWrite.BearerToken, "remote-write.bearer-token", "", "Bearer token for the remote_write endpoint")
+	flag.Var(labelsFlag(remoteWrite.Labels), "remote-write.label", "Label added to every pushed series as name=value; may be repeated")
+	flag.IntVar(&remoteWrite.QueueSize, "remote-write.queue-size", 60, "Number of sampling cycles to queue while the remote_write endpoint is unavailable")
+	flag.DurationVar(&remoteWrite.Timeout, "remote-write.timeout", 30*time.Second, "Timeout for remote_write requests")
+
+	consul := ConsulConfig{Labels: labelsFlag{}}
+	flag.StringVar(&consul.URL, "consul.url", "", "Consul agent to register the exporter with as a service, e.g. http://localhost:8500")
+	flag.StringVar(&consul.Token, "consul.token", "", "Consul ACL token")
+	flag.StringVar(&consul.Service, "consul.service", "baywheels-exporter", "Service name to register with Consul")
+	flag.StringVar(&consul.Address, "consul.address", "", "Address to register with Consul; the agent's if empty")
+	flag.Var(labelsFlag(consul.Labels), "consul.label", "Label added to the Consul service as a name=value tag and metadata; may be repeated")
+
+	leaderFile := flag.String("leader.file", "", "Elect a leader among replicas by a lock on this file, e.g. on shared storage; only the leader pushes to sinks")
+	leaderConsulKey := flag.String("leader.consul-key", "", "Elect a leader among replicas by a lock on this Consul KV key, using -consul.url; only the leader pushes to sinks")
+
+	pushgateway := PushgatewayConfig{Grouping: labelsFlag{}}
+	flag.StringVar(&pushgateway.URL, "pushgateway.url", "", "Pushgateway to push every sampling cycle to")
+	flag.StringVar(&pushgateway.Job, "pushgateway.job", "baywheels", "Job name to push metrics under")
+	flag.Var(labelsFlag(pushgateway.Grouping), "pushgateway.grouping", "Grouping label for pushed metrics as name=value; may be repeated")
+	flag.StringVar(&pushgateway.Username, "pushgateway.username", "", "Basic auth username for the Pushgateway")
+	flag.StringVar(&pushgateway.Password, "pushgateway.password", "", "Basic auth password for the Pushgateway")
+
+	otlp := OTLPConfig{Headers: labelsFlag{}}
+	flag.StringVar(&otlp.Endpoint, "otlp.endpoint", "", "OTLP collector host:port to export every sampling cycle to")
+	flag.StringVar(&otlp.Protocol, "otlp.protocol", "grpc", "OTLP transport, either grpc or http/protobuf")
+	flag.BoolVar(&otlp.Insecure, "otlp.insecure", false, "Connect to the OTLP collector without TLS")
+	flag.Var(labelsFlag(otlp.Headers), "otlp.header", "Header sent with OTLP exports as name=value; may be repeated")
+
+	tracing := TracingConfig{Headers: labelsFlag{}}
+	flag.StringVar(&tracing.Endpoint, "tracing.endpoint", "", "OTLP collector host:port to export traces of sampling cycles and feed fetches to")
+	flag.StringVar(&tracing.Protocol, "tracing.protocol", "grpc", "OTLP transport for traces, either grpc or http/protobuf")
+	flag.BoolVar(&tracing.Insecure, "tracing.insecure", false, "Connect to the trace collector without TLS")
+	flag.Var(labelsFlag(tracing.Headers), "tracing.header", "Header sent with trace exports as name=value; may be repeated")
+	flag.Float64Var(&tracing.SampleRatio, "tracing.sample-ratio", 1, "Fraction of sampling cycles to trace")
+
+	influx := InfluxDBConfig{}
+	flag.StringVar(&influx.URL, "influxdb.url", "", "InfluxDB v2 server to write every sampling cycle to")
+	flag.StringVar(&influx.Org, "influxdb.org", "", "InfluxDB organization to write to")
+	flag.StringVar(&influx.Bucket, "influxdb.bucket", "baywheels", "InfluxDB bucket to write to")
+	flag.StringVar(&influx.Token, "influxdb.token", "", "InfluxDB API token")
+
+	victoriaMetrics := VictoriaMetricsConfig{Labels: labelsFlag{}}
+	flag.StringVar(&victoriaMetrics.URL, "victoriametrics.url", "", "VictoriaMetrics server to import every sampling cycle into, e.g. http://localhost:8428 or http://vminsert:8480/insert/0/prometheus")
+	flag.StringVar(&victoriaMetrics.Format, "victoriametrics.format", "json", "Import format, either json for /api/v1/import or influx for /influx/write")
+	flag.StringVar(&victoriaMetrics.Username, "victoriametrics.username", "", "Basic auth username for VictoriaMetrics")
+	flag.StringVar(&victoriaMetrics.Password, "victoriametrics.password", "", "Basic auth password for VictoriaMetrics")
+	flag.StringVar(&victoriaMetrics.BearerToken, "victoriametrics.bearer-token", "", "Bearer token for VictoriaMetrics, e.g. behind vmauth")
+	flag.Var(labelsFlag(victoriaMetrics.Labels), "victoriametrics.label", "Label added to every imported series as name=value; may be repeated")
+
+	datadogEnabled := flag.Bool("datadog.enabled", false, "Submit every sampling cycle to the Datadog series API")
+	datadog := DatadogConfig{Tags: labelsFlag{}}
+	flag.StringVar(&datadog.Site, "datadog.site", "datadoghq.com", "Datadog site to submit to, e.g. datadoghq.eu, or the URL of a proxy")
+	flag.StringVar(&datadog.APIKeyFile, "datadog.api-key-file", "", "File to read the Datadog API key from; DD_API_KEY if unset")
+	flag.StringVar(&datadog.Prefix, "datadog.prefix", "baywheels.", "Prefix prepended to Datadog metric names")
+	flag.Var(labelsFlag(datadog.Tags), "datadog.tag", "Tag added to every series as name=value; may be repeated")
+
+	cloudWatch := CloudWatchConfig{Dimensions: labelsFlag{}}
+	cloudWatchEnabled := flag.Bool("cloudwatch.enabled", false, "Publish system totals and watched stations to CloudWatch every sampling cycle")
+	flag.StringVar(&cloudWatch.Region, "cloudwatch.region", "", "AWS region to publish to; defaults to the AWS environment or config file")
+	flag.StringVar(&cloudWatch.Endpoint, "cloudwatch.endpoint", "", "CloudWatch endpoint URL overriding the region's, e.g. for LocalStack")
+	flag.StringVar(&cloudWatch.Namespace, "cloudwatch.namespace", "Baywheels", "CloudWatch namespace to publish metrics in")
+	flag.Var(labelsFlag(cloudWatch.Dimensions), "cloudwatch.dimension", "Dimension added to every CloudWatch metric as name=value; may be repeated")
+	cloudWatchStations := flag.String("cloudwatch.stations", "", "Comma separated station IDs to publish to CloudWatch individually")
+	cloudWatchMetrics := flag.String("cloudwatch.metrics", "", "Comma separated CloudWatch metrics to publish, e.g. BikesAvailable,StationsEmpty; all if unset")
+
+	cloudMonitoring := CloudMonitoringConfig{Labels: labelsFlag{}}
+	cloudMonitoringEnabled := flag.Bool("cloud-monitoring.enabled", false, "Write every sampling cycle to Google Cloud Monitoring as custom metrics")
+	flag.StringVar(&cloudMonitoring.Project, "cloud-monitoring.project", "", "Google Cloud project to write metrics to; defaults to the project of the application default credentials")
+	flag.StringVar(&cloudMonitoring.URL, "cloud-monitoring.url", "https://monitoring.googleapis.com", "URL of the Cloud Monitoring API")
+	flag.StringVar(&cloudMonitoring.Prefix, "cloud-monitoring.prefix", "custom.googleapis.com/baywheels", "Prefix of Cloud Monitoring metric types")
+	flag.StringVar(&cloudMonitoring.Location, "cloud-monitoring.location", "global", "Location of the generic_task resource metrics are written against, e.g. us-west1")
+	flag.StringVar(&cloudMonitoring.TaskID, "cloud-monitoring.task-id", "", "Task ID of the generic_task resource metrics are written against; defaults to the hostname")
+	flag.Var(labelsFlag(cloudMonitoring.Labels), "cloud-monitoring.label", "Label added to every Cloud Monitoring series as name=value; may be repeated")
+
+	statsd := StatsDConfig{}
+	flag.StringVar(&statsd.Address, "statsd.address", "", "statsd server to emit station gauges to, as host:port or unix:///path/to/socket")
+	flag.StringVar(&statsd.Prefix, "statsd.prefix", "baywheels.", "Prefix prepended to statsd metric names")
+	flag.BoolVar(&statsd.DogStatsD, "statsd.dogstatsd", false, "Send labels as DogStatsD tags instead of appending the station ID to the metric name")
 
-	listen := flag.String("listen", ":9100", "Listen address")
+	graphite := GraphiteConfig{}
+	flag.StringVar(&graphite.Address, "graphite.address", "", "Carbon plaintext host:port to write metrics to")
+	flag.StringVar(&graphite.Prefix, "graphite.prefix", "baywheels", "Prefix of every Graphite metric path")
+	flag.DurationVar(&graphite.Interval, "graphite.interval", 60*time.Second, "Interval between Graphite writes; should match the Carbon retention schema")
 
+	mqttConfig := MQTTConfig{}
+	mqttQoS := flag.Uint("mqtt.qos", 0, "QoS level of MQTT publishes")
+	flag.StringVar(&mqttConfig.Broker, "mqtt.broker", "", "MQTT broker URL to publish station state to, e.g. tcp://localhost:1883")
+	flag.StringVar(&mqttConfig.ClientID, "mqtt.client-id", "baywheels-exporter", "MQTT client ID")
+	flag.StringVar(&mqttConfig.Username, "mqtt.username", "", "MQTT username")
+	flag.StringVar(&mqttConfig.Password, "mqtt.password", "", "MQTT password")
+	flag.StringVar(&mqttConfig.StationTopic, "mqtt.station-topic", "baywheels/stations/{station_id}", "Topic each station's state is published to; {station_id} is replaced")
+	flag.StringVar(&mqttConfig.BikeTopic, "mqtt.bike-topic", "baywheels/bikes", "Topic all free bikes are published to as one message; disabled if empty")
+	flag.BoolVar(&mqttConfig.Retain, "mqtt.retain", true, "Publish retained MQTT messages")
+	mqttHomeAssistant := flag.String("mqtt.homeassistant.stations", "", "Comma separated station IDs to announce to Home Assistant through MQTT discovery")
+	flag.StringVar(&mqttConfig.HomeAssistantPrefix, "mqtt.homeassistant.prefix", "homeassistant", "Home Assistant MQTT discovery prefix")
+
+	kafkaConfig := KafkaConfig{}
+	kafkaBrokers := flag.String("kafka.brokers", "", "Comma separated Kafka bootstrap brokers to produce station availability to")
+	flag.StringVar(&kafkaConfig.Topic, "kafka.topic", "baywheels.stations", "Kafka topic to produce to")
+	flag.StringVar(&kafkaConfig.Mode, "kafka.mode", "station", "Produce one message per station keyed by station_id (station), or one per cycle (snapshot)")
+
+	natsConfig := NATSConfig{}
+	flag.StringVar(&natsConfig.URL, "nats.url", "", "NATS server URL to publish station and bike change events to")
+	flag.StringVar(&natsConfig.Credentials, "nats.creds", "", "Path to a NATS user credentials file")
+	flag.StringVar(&natsConfig.Prefix, "nats.subject-prefix", "baywheels", "Prefix of the NATS subjects events are published to")
+
+	eventsFile := flag.String("events.file", "", "Append station and bike events, e.g. stations going empty, to this JSONL file")
+	eventsMaxSize := flag.Int("events.max-size", 100, "Size in MB -events.file is rotated at, 0 to never rotate it")
+	eventsMaxFiles := flag.Int("events.max-files", 5, "Number of rotated -events.file files kept")
+	eventsBuffer := flag.Int("events.buffer-size", eventBufferSize, "Number of recent events kept in memory for /api/v1/events")
+	textfilePath := flag.String("textfile.path", "", "Write the metrics of every cycle to this .prom file for node_exporter's textfile collector")
+
+	historySQLite := flag.String("history.sqlite", "", "Path of a SQLite database to append every sampled station_status row to")
+	historyBikes := flag.Bool("history.bikes", false, "Also record free_bike_status rows in the history database")
+
+	var parquetConfig ParquetConfig
+	flag.StringVar(&parquetConfig.Dir, "archive.parquet-dir", "", "Directory to archive raw station_status and free_bike_status samples to as Parquet files")
+	flag.StringVar(&parquetConfig.Period, "archive.period", "hourly", "Period covered by each Parquet file, either hourly or daily")
+
+	var bucketConfig BucketConfig
+	flag.StringVar(&bucketConfig.Bucket, "archive.bucket", "", "S3 compatible bucket to upload completed Parquet files to")
+	flag.StringVar(&bucketConfig.Endpoint, "archive.endpoint", "s3.amazonaws.com", "S3 compatible endpoint of the bucket; storage.googleapis.com for GCS")
+	flag.StringVar(&bucketConfig.Region, "archive.region", "", "Region of the bucket")
+	flag.StringVar(&bucketConfig.Prefix, "archive.prefix", "baywheels", "Prefix of uploaded object keys")
+	flag.StringVar(&bucketConfig.AccessKey, "archive.access-key", "", "Access key of the bucket; defaults to the AWS environment, credentials file or instance role")
+	flag.StringVar(&bucketConfig.SecretKey, "archive.secret-key", "", "Secret key of the bucket")
+	flag.BoolVar(&bucketConfig.Insecure, "archive.insecure", false, "Connect to the bucket endpoint without TLS")
+	flag.DurationVar(&bucketConfig.Retention, "archive.retention", 0, "Delete uploaded objects older than this from the bucket; kept forever if zero")
+
+	secretFiles := secretFileFlags(flag.CommandLine)
 	flag.Parse()
+	if err := loadSecrets(flag.CommandLine, secretFiles); err != nil {
+		log.Fatalf("Error loading secrets %s\n", err)
+	}
+	if *discover != "" {
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "gbfs.url" {
+				log.Fatal("Error -gbfs.discover and -gbfs.url are mutually exclusive")
+			}
+		})
+		url, err := discoverURL(context.Background(), *catalogURL, *discover)
+		if err != nil {
+			log.Fatalf("Error discovering the GBFS system %s\n", err)
+		}
+		*gbfsURL = url
+	}
+	statsd.Namespace = *namespace
+	datadog.Namespace = *namespace
+	cloudMonitoring.Namespace = *namespace
+	graphite.Namespace = *namespace
+
+	config := &Config{}
+	if *configFile != "" {
+		var err error
+		if config, err = LoadConfig(*configFile); err != nil {
+			log.Fatalf("Error loading configuration file %s\n", err)
+		}
+	}
 
-	// sample at startup
-	sampleBaywheelsMetrics(metrics)
+	if tracing.Endpoint != "" {
+		provider, err := NewTracing(ctx, tracing)
+		if err != nil {
+			log.Fatalf("Error configuring tracing %s\n", err)
+		}
+		shutdown.Add(1)
+		go func() {
+			defer shutdown.Done()
+			<-ctx.Done()
+			// flush the spans of the last cycles
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := provider.Shutdown(ctx); err != nil {
+				log.Printf("Error flushing traces %s\n", err)
+			}
+		}()
+	}
 
-	// sample at 1 minute intervals
+	exporter := NewExporter(*gbfsURL, registry, *namespace)
+	if client, err := upstream.Client(registry); err != nil {
+		log.Fatalf("Error configuring GBFS authentication %s\n", err)
+	} else if client != nil {
+		exporter.client = gbfs.NewClient(*gbfsURL, client)
+	}
+	exporter.Events = NewEventBuffer(*eventsBuffer)
+	exporter.trips.Window = *fluxWindow
+	exporter.trips.RebalancingThreshold = *rebalancingThreshold
+	exporter.fleet.IdleThreshold = *idleThreshold
+	exporter.fleet.GhostThreshold = *ghostThreshold
+	exporter.fleet.GhostBikes = *ghostBikes
+	exporter.stale.Samples = *staleSamples
+	exporter.slo.Window = *sloWindow
+	if *sampleInterval <= 0 {
+		log.Fatalf("Error -sample.interval must be positive, got %s\n", *sampleInterval)
+	}
+	interval := *sampleInterval
+	// feeds without their own interval follow the schedule
+	var scheduled []string
+	for feed, feedInterval := range feedIntervals {
+		if *feedInterval <= 0 {
+			*feedInterval = *sampleInterval
+			scheduled = append(scheduled, feed)
+		}
+		exporter.Intervals[feed] = *feedInterval
+		interval = min(interval, *feedInterval)
+	}
+	schedule.Default = *sampleInterval
+	if *scheduleTimezone != "" {
+		location, err := time.LoadLocation(*scheduleTimezone)
+		if err != nil {
+			log.Fatalf("Error loading -sample.schedule-timezone %s\n", err)
+		}
+		schedule.Location = location
+	}
+	shortest := interval
+	for _, window := range schedule.Windows {
+		shortest = min(shortest, window.Interval)
+	}
+	if *stagger < 0 || *stagger >= shortest {
+		log.Fatalf("Error -sample.stagger must be shorter than the sampling interval %s, got %s\n", shortest, *stagger)
+	}
+	exporter.Stagger = *stagger
+	speed := 1.0
+	if *replayDir != "" {
+		replay, err := LoadReplay(*replayDir)
+		if err != nil {
+			log.Fatalf("Error loading recorded feeds %s\n", err)
+		}
+		if *replaySpeed <= 0 {
+			log.Fatalf("Error -replay.speed must be positive, got %g\n", *replaySpeed)
+		}
+		replay.Speed, replay.Loop = *replaySpeed, *replayLoop
+		exporter.URL = *replayDir
+		exporter.client = gbfs.NewClient("http://replay", &http.Client{Transport: replay})
+		exporter.Now = replay.Now
+		speed = *replaySpeed
+	}
+	// the time until the next feed is due, or until the schedule's interval
+	// changes, which also wakes cycles
+	next := func() time.Duration {
+		now := exporter.Now()
+		current := schedule.Interval(now)
+		for _, feed := range scheduled {
+			exporter.Intervals[feed] = current
+		}
+		wait := exporter.Wait(now)
+		if len(schedule.Windows) > 0 {
+			wait = min(wait, schedule.Until(now, wait))
+		}
+		wait = time.Duration(float64(wait) / speed)
+		// don't sample again before the feeds can have changed
+		if *respectTTL {
+			wait = max(wait, exporter.TTL())
+		}
+		return wait
+	}
+	// validating decodes every payload a second time, so only when asked to
+	if *validate || *strict {
+		exporter.client.OnProblems = func(feed string, problems []gbfs.Problem) {
+			exporter.status.RecordProblems(feed, problems)
+			exporter.conformance.RecordProblems(feed, problems)
+		}
+	}
+	exporter.client.Strict = *strict
+	if *recordDir != "" {
+		recorder := NewRecorder(*recordDir, *recordCompress)
+		recorder.RawRetention, recorder.Retention = *recordRawRetention, *recordRetention
+		if recorder.RawRetention > 0 || recorder.Retention > 0 {
+			go recorder.Run(ctx)
+		}
+		exporter.client.OnFetch = recorder.Record
+	}
+	var proxy *FeedProxy
+	if *proxyEnabled {
+		proxy = NewFeedProxy()
+		proxy.Language = exporter.client.Language
+		if record := exporter.client.OnFetch; record != nil {
+			exporter.client.OnFetch = func(feed string, body []byte) {
+				record(feed, body)
+				proxy.Record(feed, body)
+			}
+		} else {
+			exporter.client.OnFetch = proxy.Record
+		}
+	}
+	switch {
+	case *elevationDEM != "":
+		dem, err := LoadASCIIGrid(*elevationDEM)
+		if err != nil {
+			log.Fatalf("Error loading elevation model %s\n", err)
+		}
+		exporter.elevation.Source = dem
+	case *elevationURL != "":
+		exporter.elevation.Source = NewOpenMeteoElevation(*elevationURL)
+		exporter.elevation.CachePath = *elevationCache
+		if err := exporter.elevation.LoadCache(); err != nil {
+			log.Fatalf("Error loading elevation cache %s\n", err)
+		}
+	}
+	switch *failurePolicy {
+	case "hold", "clear", "ttl":
+		exporter.FailurePolicy, exporter.HoldTTL = *failurePolicy, *holdTTL
+	default:
+		log.Fatalf("Error -feed.failure-policy must be hold, clear or ttl, got %q\n", *failurePolicy)
+	}
+	exporter.clock.WarnAfter = *skewWarning
+	if *closureHours || *closureAlerts || len(closureWindows) > 0 {
+		exporter.Closure = NewClosure(registry)
+		exporter.Closure.Hours, exporter.Closure.Alerts = *closureHours, *closureAlerts
+		exporter.Closure.Windows = closureWindows
+		if *closureTimezone != "" {
+			location, err := time.LoadLocation(*closureTimezone)
+			if err != nil {
+				log.Fatalf("Error loading -closure.timezone %s\n", err)
+			}
+			exporter.Closure.Location = location
+		}
+	}
+	exporter.Shard = shard
+	exporter.names.Normalize, exporter.names.Transliterate = *namesNormalize, *namesTransliterate
+	if *namesFile != "" {
+		exporter.names.Path = *namesFile
+		if err := exporter.names.Load(); err != nil {
+			log.Fatalf("Error loading station names %s\n", err)
+		}
+	}
+	if *expectedAlpha <= 0 || *expectedAlpha > 1 {
+		log.Fatalf("Error -expected.alpha must be between 0 and 1, got %g\n", *expectedAlpha)
+	}
+	exporter.expected.Alpha = *expectedAlpha
+	if *timezone != "" {
+		location, err := time.LoadLocation(*timezone)
+		if err != nil {
+			log.Fatalf("Error loading time zone %s\n", err)
+		}
+		exporter.expected.Location = location
+		exporter.daily.Location = location
+	}
+	reset, err := parseTimeOfDay(*dailyReset)
+	if err != nil {
+		log.Fatalf("Error parsing -daily.reset %s\n", err)
+	}
+	exporter.daily.Reset = reset
+	if *zonesFile != "" {
+		zones, err := LoadZones(*zonesFile, *zonesName)
+		if err != nil {
+			log.Fatalf("Error loading zones %s\n", err)
+		}
+		exporter.zones.Zones = zones
+	}
+	if err := validatePOIs(config.POIs); err != nil {
+		log.Fatalf("Error configuring points of interest %s\n", err)
+	}
+	exporter.pois.POIs = config.POIs
+	if err := validateCorridors(config.Corridors); err != nil {
+		log.Fatalf("Error configuring corridors %s\n", err)
+	}
+	exporter.corridors.Corridors = config.Corridors
+	if *debugEnabled {
+		publishExpvars(exporter)
+		go serveDebug(*debugListen)
+	}
+	if points := int(*trend / interval); points > 0 {
+		exporter.Trends = NewTrends(points)
+	}
+	// systemd sets NOTIFY_SOCKET for Type=notify units and those with a
+	// watchdog
+	if os.Getenv("NOTIFY_SOCKET") != "" {
+		exporter.Systemd = NewSystemd(*watchdogStall)
+		go exporter.Systemd.Run(ctx)
+	}
+
+	// sinks pushing elsewhere are on standby unless this replica is the
+	// leader; local ones always run
+	lead := func(sink Sink) Sink { return sink }
+	var leading func() bool
+	var lock Lock
+	switch {
+	case *leaderFile != "" && *leaderConsulKey != "":
+		log.Fatal("Error -leader.file and -leader.consul-key are mutually exclusive")
+	case *leaderFile != "":
+		lock = &FileLock{Path: *leaderFile}
+	case *leaderConsulKey != "":
+		if consul.URL == "" {
+			log.Fatal("Error -leader.consul-key requires -consul.url")
+		}
+		lock = NewConsulLock(consul, *leaderConsulKey)
+	}
+	if lock != nil {
+		leader := NewLeader(lock, registry)
+		leader.attempt(ctx)
+		shutdown.Add(1)
+		go func() {
+			defer shutdown.Done()
+			leader.Run(ctx)
+		}()
+		lead, leading = leader.Standby, leader.Leading
+	}
+
+	if remoteWrite.URL != "" {
+		w := NewRemoteWrite(remoteWrite, registry)
+		go w.Run(ctx)
+		exporter.Sinks = append(exporter.Sinks, lead(w))
+	}
+	if pushgateway.URL != "" {
+		exporter.Sinks = append(exporter.Sinks, lead(NewPushgateway(pushgateway)))
+	}
+	if otlp.Endpoint != "" {
+		o, err := NewOTLP(ctx, otlp)
+		if err != nil {
+			log.Fatalf("Error configuring OTLP exporter %s\n", err)
+		}
+		exporter.Sinks = append(exporter.Sinks, lead(o))
+	}
+	if influx.URL != "" {
+		exporter.Sinks = append(exporter.Sinks, lead(NewInfluxDB(influx)))
+	}
+	if victoriaMetrics.URL != "" {
+		v, err := NewVictoriaMetrics(victoriaMetrics)
+		if err != nil {
+			log.Fatalf("Error configuring VictoriaMetrics sink %s\n", err)
+		}
+		exporter.Sinks = append(exporter.Sinks, lead(v))
+	}
+	if *datadogEnabled {
+		d, err := NewDatadog(datadog)
+		if err != nil {
+			log.Fatalf("Error configuring Datadog sink %s\n", err)
+		}
+		exporter.Sinks = append(exporter.Sinks, lead(d))
+	}
+	if *cloudWatchEnabled {
+		if *cloudWatchStations != "" {
+			cloudWatch.Stations = strings.Split(*cloudWatchStations, ",")
+		}
+		if *cloudWatchMetrics != "" {
+			cloudWatch.Metrics = strings.Split(*cloudWatchMetrics, ",")
+		}
+		c, err := NewCloudWatch(ctx, cloudWatch)
+		if err != nil {
+			log.Fatalf("Error configuring CloudWatch sink %s\n", err)
+		}
+		exporter.Sinks = append(exporter.Sinks, lead(c))
+	}
+	if *cloudMonitoringEnabled {
+		c, err := NewCloudMonitoring(ctx, cloudMonitoring)
+		if err != nil {
+			log.Fatalf("Error configuring Cloud Monitoring sink %s\n", err)
+		}
+		exporter.Sinks = append(exporter.Sinks, lead(c))
+	}
+	if statsd.Address != "" {
+		s, err := NewStatsD(statsd)
+		if err != nil {
+			log.Fatalf("Error configuring statsd sink %s\n", err)
+		}
+		exporter.Sinks = append(exporter.Sinks, lead(s))
+	}
+	if mqttConfig.Broker != "" {
+		mqttConfig.QoS = byte(*mqttQoS)
+		if *mqttHomeAssistant != "" {
+			mqttConfig.HomeAssistantStations = strings.Split(*mqttHomeAssistant, ",")
+		}
+		exporter.Sinks = append(exporter.Sinks, lead(NewMQTT(mqttConfig)))
+	}
+	if *kafkaBrokers != "" {
+		kafkaConfig.Brokers = strings.Split(*kafkaBrokers, ",")
+		k, err := NewKafka(kafkaConfig)
+		if err != nil {
+			log.Fatalf("Error configuring Kafka sink %s\n", err)
+		}
+		exporter.Sinks = append(exporter.Sinks, lead(k))
+	}
+	if natsConfig.URL != "" {
+		n, err := NewNATS(natsConfig)
+		if err != nil {
+			log.Fatalf("Error configuring NATS sink %s\n", err)
+		}
+		exporter.Sinks = append(exporter.Sinks, lead(n))
+	}
+	if *eventsFile != "" {
+		l, err := NewEventLog(*eventsFile)
+		if err != nil {
+			log.Fatalf("Error opening event log %s\n", err)
+		}
+		l.MaxSize, l.MaxFiles = int64(*eventsMaxSize)<<20, *eventsMaxFiles
+		exporter.Sinks = append(exporter.Sinks, l)
+	}
+	if *textfilePath != "" {
+		exporter.Sinks = append(exporter.Sinks, NewTextfile(*textfilePath))
+	}
+	var history *SQLiteHistory
+	if *historySQLite != "" {
+		h, err := NewSQLiteHistory(*historySQLite, *historyBikes)
+		if err != nil {
+			log.Fatalf("Error opening history database %s\n", err)
+		}
+		exporter.Sinks = append(exporter.Sinks, h)
+		history = h
+	}
+	if parquetConfig.Dir != "" {
+		a, err := NewParquetArchive(parquetConfig)
+		if err != nil {
+			log.Fatalf("Error creating Parquet archive %s\n", err)
+		}
+		if bucketConfig.Bucket != "" {
+			u, err := NewBucketUploader(bucketConfig, parquetConfig.Dir)
+			if err != nil {
+				log.Fatalf("Error creating archive uploader %s\n", err)
+			}
+			go u.Run(ctx)
+			a.Completed = u.Upload
+		}
+		exporter.Sinks = append(exporter.Sinks, a)
+	}
+	if len(config.Webhooks) > 0 {
+		w, err := NewWebhooks(config.Webhooks)
+		if err != nil {
+			log.Fatalf("Error configuring webhooks %s\n", err)
+		}
+		// tracked on standby too, only posting while leading
+		w.Leading = leading
+		exporter.Sinks = append(exporter.Sinks, w)
+	}
+	if len(config.Notifiers) > 0 {
+		n, err := NewNotifiers(config.Notifiers)
+		if err != nil {
+			log.Fatalf("Error configuring notifiers %s\n", err)
+		}
+		n.Leading = leading
+		exporter.Sinks = append(exporter.Sinks, n)
+	}
+	if graphite.Address != "" {
+		exporter.Sinks = append(exporter.Sinks, lead(NewGraphite(graphite)))
+	}
+	if !*serve && len(exporter.Sinks) == 0 {
+		log.Fatal("-serve=false requires at least one sink to push to")
+	}
+
+	if *grpcListen != "" {
+		go serveGRPC(*grpcListen, exporter)
+	}
+
+	// sample at startup, offsetting every later cycle by the jitter too
+	if *jitter > 0 {
+		time.Sleep(rand.N(*jitter))
+	}
+	exporter.Sample(ctx)
+
+	// registered after the first cycle so the system's GBFS version is known
+	if consul.URL != "" {
+		address, err := tcpAddress(listen.values)
+		if err != nil {
+			log.Fatalf("Error configuring Consul registration %s\n", err)
+		}
+		c, err := NewConsul(consul, address)
+		if err != nil {
+			log.Fatalf("Error configuring Consul registration %s\n", err)
+		}
+		shutdown.Add(1)
+		go func() {
+			defer shutdown.Done()
+			c.Run(ctx, exporter)
+		}()
+	}
+
+	// sample whenever the next feed is due
 	go func() {
-		for range ticker.C {
-			sampleBaywheelsMetrics(metrics)
+		for {
+			wait := next()
+			if exporter.Systemd != nil {
+				exporter.Systemd.Wait(wait)
+			}
+			time.Sleep(wait)
+			exporter.Sample(ctx)
 		}
 	}()
 
-	// Serve the prometheus metrics
-	log.Printf("Listening on %s\n", *listen)
-	http.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry}))
-	log.Fatal(http.ListenAndServe(*listen, nil))
+	if !*serve {
+		select {}
+	}
+
+	// Serve the prometheus metrics. Use our own mux rather than
+	// http.DefaultServeMux, which net/http/pprof registers itself on.
+	httpMetrics := NewHTTPMetrics(registry)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", httpMetrics.Instrument("metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})))
+	landing := NewLandingPage(exporter)
+	api := NewAPI(exporter)
+	if history != nil {
+		api.History = history
+		landing.Links = append(landing.Links, LandingLink{Path: "/api/v1/history?station_id=", Description: "Recorded availability of a station as JSON; takes from and to as RFC 3339 or unix times"})
+	}
+	mux.Handle("/{$}", httpMetrics.Instrument("landing", landing))
+	api.Register(mux, httpMetrics)
+	if proxy != nil {
+		proxy.Register(mux, httpMetrics)
+		landing.Links = append(landing.Links, LandingLink{Path: "/gbfs/gbfs.json", Description: "The feeds as last fetched, for other consumers to share the exporter's fetches"})
+	}
+	mux.Handle("GET /sd", httpMetrics.Instrument("sd", handleSD(exporter, *sdTarget)))
+	mux.Handle("GET /map", httpMetrics.Instrument("map", http.HandlerFunc(handleMap)))
+	mux.Handle("GET /static/", httpMetrics.Instrument("static", http.FileServerFS(static)))
+	mux.Handle("GET /heatmap.png", httpMetrics.Instrument("heatmap", handleHeatmap(exporter)))
+	mux.Handle("GET /conformance", httpMetrics.Instrument("conformance", handleConformance(exporter)))
+	mux.Handle("GET /station/{id}", httpMetrics.Instrument("station", handleStationPage(exporter)))
+	mux.Handle("GET /ws", httpMetrics.Instrument("ws", handleWebSocket(exporter.Changes)))
+	mux.Handle("GET /events", httpMetrics.Instrument("events", handleEvents(exporter.Changes)))
+
+	// TLS and authentication are handled by the exporter-toolkit according
+	// to the optional web configuration file.
+	handler, err := logRequests(mux, *accessLog)
+	if err != nil {
+		log.Fatalf("Error configuring the access log %s\n", err)
+	}
+	server := &http.Server{Handler: handler}
+	// systemd sets LISTEN_PID to the process it passes sockets to
+	if os.Getenv("LISTEN_PID") == strconv.Itoa(os.Getpid()) {
+		*systemdSocket = true
+	}
+	flags := &web.FlagConfig{
+		WebListenAddresses: &listen.values,
+		WebSystemdSocket:   systemdSocket,
+		WebConfigFile:      webConfig,
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	if *systemdSocket {
+		if err := web.ListenAndServe(server, flags, logger); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	mode, err := strconv.ParseUint(*socketMode, 8, 32)
+	if err != nil {
+		log.Fatalf("Error parsing -web.socket-mode %s\n", err)
+	}
+	listeners := make([]net.Listener, 0, len(listen.values))
+	for _, address := range listen.values {
+		listener, err := listenOn(address, fs.FileMode(mode))
+		if err != nil {
+			log.Fatalf("Error listening on %s %s\n", address, err)
+		}
+		listeners = append(listeners, listener)
+	}
+	// closing unix listeners removes their sockets
+	shutdown.Add(1)
+	go func() {
+		defer shutdown.Done()
+		<-ctx.Done()
+		for _, listener := range listeners {
+			listener.Close()
+		}
+	}()
+	if err := web.ServeMultiple(listeners, server, flags, logger); err != nil && ctx.Err() == nil {
+		log.Fatal(err)
+	}
+	select {}
 }
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// / Run cycles like the sampling loop for length, waking when Wait says,
+// / and return the offsets each feed was fetched at, successfully or not.
+// / Fetches of feeds in failing fail.
+func simulate(e *Exporter, length time.Duration, failing map[string]bool) map[string][]time.Duration {
+	start := time.Date(2026, 1, 5, 8, 0, 0, 0, time.UTC)
+	fetches := make(map[string][]time.Duration)
+	for now := start; now.Sub(start) <= length; now = now.Add(e.Wait(now)) {
+		cycle := &Cycle{Errors: make(map[string]error), Fetched: make(map[string]bool)}
+		for feed := range e.Intervals {
+			if !e.due(feed, now) {
+				continue
+			}
+			e.tried(feed, now)
+			fetches[feed] = append(fetches[feed], now.Sub(start))
+			if failing[feed] {
+				cycle.Errors[feed] = errors.New("unavailable")
+				continue
+			}
+			e.fetched(cycle, feed, now)
+		}
+	}
+	return fetches
+}
+
+func seconds(s ...int) []time.Duration {
+	offsets := make([]time.Duration, len(s))
+	for i, n := range s {
+		offsets[i] = time.Duration(n) * time.Second
+	}
+	return offsets
+}
+
+func TestWaitIntervals(t *testing.T) {
+	tests := []struct {
+		name      string
+		intervals map[string]time.Duration
+		failing   map[string]bool
+		length    time.Duration
+		want      map[string][]time.Duration
+	}{
+		{
+			name: "multiples",
+			intervals: map[string]time.Duration{
+				"station_status":   30 * time.Second,
+				"free_bike_status": time.Minute,
+			},
+			want: map[string][]time.Duration{
+				"station_status":   seconds(0, 30, 60, 90, 120, 150, 180),
+				"free_bike_status": seconds(0, 60, 120, 180),
+			},
+		},
+		{
+			name: "not multiples",
+			intervals: map[string]time.Duration{
+				"station_status":   45 * time.Second,
+				"free_bike_status": time.Minute,
+			},
+			want: map[string][]time.Duration{
+				"station_status":   seconds(0, 45, 90, 135, 180),
+				"free_bike_status": seconds(0, 60, 120, 180),
+			},
+		},
+		{
+			name: "close intervals",
+			intervals: map[string]time.Duration{
+				"station_status":   10 * time.Second,
+				"free_bike_status": 11 * time.Second,
+			},
+			length: time.Minute,
+			want: map[string][]time.Duration{
+				"station_status":   seconds(0, 10, 20, 30, 40, 50, 60),
+				"free_bike_status": seconds(0, 11, 22, 33, 44, 55),
+			},
+		},
+		{
+			name: "failed feed retried at the shortest interval",
+			intervals: map[string]time.Duration{
+				"station_status":      time.Minute,
+				"station_information": time.Hour,
+			},
+			failing: map[string]bool{"station_information": true},
+			want: map[string][]time.Duration{
+				"station_status":      seconds(0, 60, 120, 180),
+				"station_information": seconds(0, 60, 120, 180),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &Exporter{
+				Intervals: tt.intervals,
+				fetchedAt: make(map[string]time.Time),
+				triedAt:   make(map[string]time.Time),
+			}
+			length := tt.length
+			if length == 0 {
+				length = 3 * time.Minute
+			}
+			got := simulate(e, length, tt.failing)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fetched at %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
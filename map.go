@@ -0,0 +1,147 @@
+package main
+
+import (
+	"embed"
+	"net/http"
+)
+
+// / static holds the map's script and styles, served under /static/ so the
+// / map needs nothing but the tiles from elsewhere.
+//
+//go:embed static
+var static embed.FS
+
+// / mapPage is a self-contained map of the stations, coloured by the bikes
+// / available, and the free bikes, drawn by static/map.js with the subset of
+// / the Leaflet API it implements. It is driven entirely by the GeoJSON API,
+// / reloading it every minute to follow the sampling interval.
+const mapPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Baywheels Map</title>
+<link rel="stylesheet" href="static/map.css">
+<script src="static/map.js"></script>
+<style>
+html, body, #map { height: 100%; margin: 0; }
+body { font-family: sans-serif; }
+.legend { background: white; padding: 0.5em; line-height: 1.5em; }
+.legend span { display: inline-block; width: 0.8em; height: 0.8em; border-radius: 50%; margin-right: 0.4em; }
+</style>
+</head>
+<body>
+<div id="map"></div>
+<script>
+const colors = { empty: "#d7191c", low: "#fdae61", ok: "#1a9641", closed: "#888888", bike: "#2b83ba" };
+
+function stationColor(p) {
+  if (p.bikes_available === undefined || !p.is_renting) return colors.closed;
+  if (p.bikes_available === 0) return colors.empty;
+  if (p.bikes_available <= 2) return colors.low;
+  return colors.ok;
+}
+
+function escape(s) {
+  const div = document.createElement("div");
+  div.textContent = s;
+  return div.innerHTML;
+}
+
+function stationPopup(p) {
+  let html = "<b>" + escape(p.name) + "</b>";
+  if (p.bikes_available !== undefined) {
+    html += "<br>" + p.bikes_available + " bikes (" + p.ebikes_available + " e-bikes)" +
+      "<br>" + p.docks_available + " docks";
+    if (!p.is_renting) html += "<br>not renting";
+  }
+  return html + '<br><a href="station/' + encodeURIComponent(p.station_id) + '">details</a>';
+}
+
+// start on San Francisco until the stations or the rider's location are known
+const map = L.map("map").setView([37.7749, -122.4194], 13);
+L.tileLayer("https://tile.openstreetmap.org/{z}/{x}/{y}.png", {
+  maxZoom: 19,
+  attribution: '&copy; <a href="https://www.openstreetmap.org/copyright">OpenStreetMap</a> contributors',
+}).addTo(map);
+
+const stations = L.layerGroup().addTo(map);
+const stationMarkers = new Map();
+const bikes = L.layerGroup().addTo(map);
+L.control.layers(null, { "Stations": stations, "Free bikes": bikes }).addTo(map);
+
+const legend = L.control({ position: "bottomright" });
+legend.onAdd = function () {
+  const div = L.DomUtil.create("div", "legend");
+  div.innerHTML =
+    '<span style="background:' + colors.ok + '"></span>3+ bikes<br>' +
+    '<span style="background:' + colors.low + '"></span>1-2 bikes<br>' +
+    '<span style="background:' + colors.empty + '"></span>empty<br>' +
+    '<span style="background:' + colors.closed + '"></span>closed<br>' +
+    '<span style="background:' + colors.bike + '"></span>free bike';
+  return div;
+};
+legend.addTo(map);
+
+// centre on the rider if they allow it, otherwise on the whole system
+let located = false, fitted = false;
+map.on("locationfound", function (e) {
+  located = true;
+  L.circleMarker(e.latlng, { radius: 6, color: "#000", fillOpacity: 1 }).addTo(map);
+});
+map.locate({ setView: true, maxZoom: 16 });
+
+async function load() {
+  const resp = await fetch("api/v1/stations.geojson?bikes=true");
+  if (!resp.ok) return;
+  const fc = await resp.json();
+
+  // update station markers in place so open popups survive a reload
+  const seen = new Set();
+  bikes.clearLayers();
+  const bounds = [];
+  for (const f of fc.features) {
+    const [lon, lat] = f.geometry.coordinates;
+    const p = f.properties;
+    if (p.kind === "station") {
+      seen.add(f.id);
+      bounds.push([lat, lon]);
+      let marker = stationMarkers.get(f.id);
+      if (!marker) {
+        marker = L.circleMarker([lat, lon], { radius: 8, weight: 1, color: "#333", fillOpacity: 0.9 })
+          .bindPopup("")
+          .addTo(stations);
+        stationMarkers.set(f.id, marker);
+      }
+      marker.setLatLng([lat, lon]);
+      marker.setStyle({ fillColor: stationColor(p) });
+      marker.setPopupContent(stationPopup(p));
+    } else if (!p.is_disabled && !p.is_reserved) {
+      L.circleMarker([lat, lon], { radius: 3, weight: 0, fillColor: colors.bike, fillOpacity: 1 })
+        .bindPopup("Bike " + escape(p.bike_id))
+        .addTo(bikes);
+    }
+  }
+  for (const [id, marker] of stationMarkers) {
+    if (!seen.has(id)) {
+      stations.removeLayer(marker);
+      stationMarkers.delete(id);
+    }
+  }
+  if (!located && !fitted && bounds.length > 0) {
+    map.fitBounds(bounds);
+    fitted = true;
+  }
+}
+
+load();
+setInterval(load, 60 * 1000);
+</script>
+</body>
+</html>
+`
+
+func handleMap(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(mapPage))
+}
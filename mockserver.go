@@ -0,0 +1,333 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// / mockStation and mockBike are the state of the synthetic system served by
+// / mock-server.
+type mockStation struct {
+	id       string
+	name     string
+	lat, lon float64
+	capacity int
+	bikes    int
+	ebikes   int
+	disabled int
+	renting  bool
+}
+
+type mockBike struct {
+	id       string
+	lat, lon float64
+	disabled bool
+}
+
+// / mockSystem is a synthetic bikeshare system around a centre point whose
+// / availability changes every step, as a live system's would between
+// / fetches.
+type mockSystem struct {
+	// Churn is the fraction of stations and bikes that change every step.
+	Churn float64
+
+	mu       sync.Mutex
+	rand     *rand.Rand
+	stations []mockStation
+	bikes    []mockBike
+	updated  time.Time
+}
+
+func newMockSystem(stations, bikes int, lat, lon float64, seed uint64) *mockSystem {
+	s := &mockSystem{rand: rand.New(rand.NewPCG(seed, seed)), updated: time.Now()}
+	// stations on a grid of roughly 250m blocks
+	side := 1
+	for side*side < stations {
+		side++
+	}
+	for i := range stations {
+		capacity := 10 + s.rand.IntN(20)
+		bikes := s.rand.IntN(capacity + 1)
+		s.stations = append(s.stations, mockStation{
+			id:       fmt.Sprintf("station-%d", i),
+			name:     fmt.Sprintf("Station %d", i),
+			lat:      lat + float64(i/side-side/2)*0.00225,
+			lon:      lon + float64(i%side-side/2)*0.00285,
+			capacity: capacity,
+			bikes:    bikes,
+			ebikes:   s.rand.IntN(bikes + 1),
+			renting:  true,
+		})
+	}
+	for i := range bikes {
+		s.bikes = append(s.bikes, mockBike{
+			id:  fmt.Sprintf("bike-%d", i),
+			lat: lat + (s.rand.Float64()-0.5)*0.00225*float64(side),
+			lon: lon + (s.rand.Float64()-0.5)*0.00285*float64(side),
+		})
+	}
+	return s
+}
+
+// / Advance the system by one step: riders take and return bikes at a
+// / Churn fraction of the stations, now and then the operator rebalances
+// / one or it stops renting for a while, and free bikes move or break down.
+func (s *mockSystem) step() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.stations {
+		station := &s.stations[i]
+		if s.rand.Float64() >= s.Churn {
+			continue
+		}
+		switch r := s.rand.Float64(); {
+		case r < 0.02:
+			station.renting = !station.renting
+		case r < 0.07:
+			// rebalancing empties or fills the station
+			if station.bikes > station.capacity/2 {
+				station.bikes = 0
+			} else {
+				station.bikes = station.capacity - station.disabled
+			}
+		case r < 0.1:
+			// bikes breaking down and being repaired
+			station.disabled = s.rand.IntN(min(3, station.capacity) + 1)
+		default:
+			station.bikes += s.rand.IntN(7) - 3
+		}
+		station.bikes = max(0, min(station.bikes, station.capacity-station.disabled))
+		station.ebikes = min(station.ebikes, station.bikes)
+		if s.rand.Float64() < 0.5 {
+			station.ebikes = s.rand.IntN(station.bikes + 1)
+		}
+	}
+	for i := range s.bikes {
+		bike := &s.bikes[i]
+		if s.rand.Float64() >= s.Churn {
+			continue
+		}
+		if s.rand.Float64() < 0.05 {
+			bike.disabled = !bike.disabled
+			continue
+		}
+		bike.lat += (s.rand.Float64() - 0.5) * 0.005
+		bike.lon += (s.rand.Float64() - 0.5) * 0.005
+	}
+	s.updated = time.Now()
+}
+
+// / Return the data of each feed of the system's current state.
+func (s *mockSystem) feeds() (map[string]any, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	information := make([]map[string]any, len(s.stations))
+	status := make([]map[string]any, len(s.stations))
+	for i, station := range s.stations {
+		information[i] = map[string]any{
+			"station_id": station.id,
+			"name":       station.name,
+			"short_name": fmt.Sprintf("MS-%d", i),
+			"lat":        station.lat,
+			"lon":        station.lon,
+			"capacity":   station.capacity,
+		}
+		renting := 0
+		if station.renting {
+			renting = 1
+		}
+		status[i] = map[string]any{
+			"station_id":           station.id,
+			"num_bikes_available":  station.bikes,
+			"num_ebikes_available": station.ebikes,
+			"num_bikes_disabled":   station.disabled,
+			"num_docks_available":  station.capacity - station.bikes - station.disabled,
+			"num_docks_disabled":   0,
+			"is_installed":         1,
+			"is_renting":           renting,
+			"is_returning":         1,
+			"last_reported":        s.updated.Unix(),
+			"vehicle_types_available": []map[string]any{
+				{"vehicle_type_id": "1", "count": station.bikes},
+			},
+		}
+	}
+	free := make([]map[string]any, len(s.bikes))
+	for i, bike := range s.bikes {
+		disabled := 0
+		if bike.disabled {
+			disabled = 1
+		}
+		free[i] = map[string]any{
+			"bike_id":         bike.id,
+			"is_reserved":     0,
+			"is_disabled":     disabled,
+			"lat":             bike.lat,
+			"lon":             bike.lon,
+			"vehicle_type_id": "1",
+		}
+	}
+	return map[string]any{
+		"system_information": map[string]any{
+			"system_id": "mock",
+			"language":  "en",
+			"name":      "Mock Bikeshare",
+			"timezone":  "America/Los_Angeles",
+		},
+		"station_information": map[string]any{"stations": information},
+		"station_status":      map[string]any{"stations": status},
+		"free_bike_status":    map[string]any{"bikes": free},
+		"vehicle_types": map[string]any{"vehicle_types": []map[string]any{
+			{"vehicle_type_id": "1", "form_factor": "bicycle", "propulsion_type": "human"},
+		}},
+	}, s.updated
+}
+
+// / mockFailures injects failures into a fraction of the responses of
+// / mock-server, to see how the exporter and dashboards cope with a flaky
+// / upstream.
+type mockFailures struct {
+	// ErrorRate is the fraction of requests answered with a 503.
+	ErrorRate float64
+	// MalformedRate is the fraction answered with truncated JSON.
+	MalformedRate float64
+	// Latency is added to every response, up to twice as long.
+	Latency time.Duration
+}
+
+// / Run `mock-server`, serving a synthetic system, or the feeds recorded in
+// / a -record.dir, as a GBFS API with a gbfs.json auto-discovery file, so
+// / dashboards can be developed and the exporter demoed or tested offline.
+func runMockServer(args []string) error {
+	fs := flag.NewFlagSet("mock-server", flag.ExitOnError)
+	listen := fs.String("listen", ":8080", "Address to serve the feeds on")
+	stations := fs.Int("stations", 100, "Number of stations of the synthetic system")
+	bikes := fs.Int("bikes", 200, "Number of free bikes of the synthetic system")
+	lat := fs.Float64("lat", 37.7749, "Latitude of the centre of the synthetic system")
+	lon := fs.Float64("lon", -122.4194, "Longitude of the centre of the synthetic system")
+	seed := fs.Uint64("seed", 1, "Seed of the synthetic system, for reproducible runs")
+	interval := fs.Duration("interval", 30*time.Second, "How often the synthetic system changes, also its ttl")
+	churn := fs.Float64("churn", 0.2, "Fraction of stations and bikes that change every -interval")
+	recordDir := fs.String("record.dir", "", "Serve the feeds recorded in this -record.dir instead of a synthetic system")
+	replaySpeed := fs.Float64("replay.speed", 1, "How many times faster than real time to replay -record.dir")
+	replayLoop := fs.Bool("replay.loop", true, "Start over after replaying the last recorded feeds rather than holding them")
+	var failures mockFailures
+	fs.Float64Var(&failures.ErrorRate, "fail.error-rate", 0, "Fraction of requests to answer with 503 Service Unavailable")
+	fs.Float64Var(&failures.MalformedRate, "fail.malformed-rate", 0, "Fraction of requests to answer with truncated JSON")
+	fs.DurationVar(&failures.Latency, "fail.latency", 0, "Delay every response by between this and twice this")
+	fs.Parse(args)
+
+	// body returns the raw body of feed, or nil if it isn't served
+	var body func(feed string) ([]byte, error)
+	var names []string
+	if *recordDir != "" {
+		replay, err := LoadReplay(*recordDir)
+		if err != nil {
+			return err
+		}
+		if *replaySpeed <= 0 {
+			return fmt.Errorf("-replay.speed must be positive, got %g", *replaySpeed)
+		}
+		replay.Speed, replay.Loop = *replaySpeed, *replayLoop
+		for feed := range replay.feeds {
+			if feed != "gbfs" {
+				names = append(names, feed)
+			}
+		}
+		body = func(feed string) ([]byte, error) {
+			return replay.body(feed, replay.Now())
+		}
+		log.Printf("Replaying %s\n", *recordDir)
+	} else {
+		if *churn < 0 || *churn > 1 {
+			return fmt.Errorf("-churn must be between 0 and 1, got %g", *churn)
+		}
+		system := newMockSystem(*stations, *bikes, *lat, *lon, *seed)
+		system.Churn = *churn
+		go func() {
+			for range time.Tick(*interval) {
+				system.step()
+			}
+		}()
+		data, _ := system.feeds()
+		for feed := range data {
+			names = append(names, feed)
+		}
+		body = func(feed string) ([]byte, error) {
+			data, updated := system.feeds()
+			if data[feed] == nil {
+				return nil, nil
+			}
+			return json.Marshal(map[string]any{
+				"last_updated": updated.Unix(),
+				"ttl":          int(interval.Seconds()),
+				"version":      "2.3",
+				"data":         data[feed],
+			})
+		}
+		log.Printf("Serving a synthetic system of %d stations and %d bikes\n", *stations, *bikes)
+	}
+	sort.Strings(names)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /gbfs.json", func(w http.ResponseWriter, r *http.Request) {
+		feeds := make([]map[string]string, len(names))
+		for i, name := range names {
+			feeds[i] = map[string]string{"name": name, "url": "http://" + r.Host + "/" + name + ".json"}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"last_updated": time.Now().Unix(),
+			"ttl":          0,
+			"version":      "2.3",
+			"data":         map[string]any{"en": map[string]any{"feeds": feeds}},
+		})
+	})
+	mux.HandleFunc("GET /{feed}", func(w http.ResponseWriter, r *http.Request) {
+		b, err := body(strings.TrimSuffix(r.PathValue("feed"), ".json"))
+		if err != nil {
+			log.Printf("Error reading feed %s\n", err)
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if b == nil {
+			writeError(w, http.StatusNotFound, "unknown feed")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+	})
+
+	log.Printf("Serving the feeds on %s, with the auto-discovery file at /gbfs.json\n", *listen)
+	return http.ListenAndServe(*listen, failures.wrap(mux))
+}
+
+// / Wrap handler so the configured failures are injected into its
+// / responses.
+func (f mockFailures) wrap(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if f.Latency > 0 {
+			time.Sleep(f.Latency + rand.N(f.Latency))
+		}
+		switch roll := rand.Float64(); {
+		case roll < f.ErrorRate:
+			http.Error(w, "injected failure", http.StatusServiceUnavailable)
+			return
+		case roll < f.ErrorRate+f.MalformedRate:
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"last_updated": 0, "data": {"stations": [{"station_id": `)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
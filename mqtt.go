@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/patrickod/baywheels-exporter/pkg/gbfs"
+)
+
+const mqttTimeout = 10 * time.Second
+
+type MQTTConfig struct {
+	Broker   string
+	ClientID string
+	Username string
+	Password string
+	// StationTopic is expanded per station, replacing {station_id}.
+	StationTopic string
+	// BikeTopic receives all free bikes as a single message; disabled if
+	// empty.
+	BikeTopic string
+	QoS       byte
+	Retain    bool
+	// HomeAssistantStations are the station IDs announced to Home Assistant
+	// through MQTT discovery under HomeAssistantPrefix.
+	HomeAssistantStations []string
+	HomeAssistantPrefix   string
+}
+
+// / MQTT is a Sink publishing each station's merged information and status
+// / as a JSON message after every cycle. Messages are retained by default so
+// / subscribers such as home automation get the current state immediately.
+type MQTT struct {
+	config MQTTConfig
+	client mqtt.Client
+	// stations already announced to Home Assistant
+	discovered map[string]bool
+}
+
+func NewMQTT(config MQTTConfig) *MQTT {
+	opts := mqtt.NewClientOptions().
+		AddBroker(config.Broker).
+		SetClientID(config.ClientID).
+		SetUsername(config.Username).
+		SetPassword(config.Password).
+		SetAutoReconnect(true).
+		SetConnectRetry(true)
+	client := mqtt.NewClient(opts)
+
+	// with ConnectRetry the token only completes once connected; give the
+	// first cycle a chance to publish, but a broker that is down at startup
+	// shouldn't hold up sampling
+	client.Connect().WaitTimeout(mqttTimeout)
+
+	return &MQTT{config: config, client: client, discovered: make(map[string]bool)}
+}
+
+func (m *MQTT) Name() string {
+	return "mqtt"
+}
+
+func (m *MQTT) Send(ctx context.Context, cycle *Cycle) error {
+	if !m.client.IsConnectionOpen() {
+		return errors.New("not connected to MQTT broker")
+	}
+
+	tokens, err := m.discover(cycle.Snapshot)
+	if err != nil {
+		return err
+	}
+	for _, station := range cycle.Snapshot.Stations {
+		payload, err := json.Marshal(struct {
+			LastUpdated time.Time `json:"last_updated"`
+			Station
+		}{cycle.Snapshot.Time, station})
+		if err != nil {
+			return err
+		}
+		tokens = append(tokens, m.client.Publish(m.stationTopic(station.StationId), m.config.QoS, m.config.Retain, payload))
+	}
+	if m.config.BikeTopic != "" {
+		payload, err := json.Marshal(struct {
+			LastUpdated time.Time         `json:"last_updated"`
+			Bikes       []gbfs.BikeStatus `json:"bikes"`
+		}{cycle.Snapshot.Time, cycle.Snapshot.Bikes})
+		if err != nil {
+			return err
+		}
+		tokens = append(tokens, m.client.Publish(m.config.BikeTopic, m.config.QoS, m.config.Retain, payload))
+	}
+
+	var errs []error
+	for _, token := range tokens {
+		if !token.WaitTimeout(mqttTimeout) {
+			errs = append(errs, errors.New("timed out publishing to MQTT broker"))
+		} else if err := token.Error(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%d of %d MQTT publishes failed: %w", len(errs), len(tokens), errs[0])
+	}
+	return nil
+}
+
+// / homeAssistantSensors are announced for every selected station, reading
+// / their state from the station's JSON message.
+var homeAssistantSensors = []struct {
+	key, name, field, unit, icon string
+}{
+	{"bikes_available", "Bikes available", "num_bikes_available", "bikes", "mdi:bicycle"},
+	{"ebikes_available", "E-bikes available", "num_ebikes_available", "bikes", "mdi:bicycle-electric"},
+	{"docks_available", "Docks available", "num_docks_available", "docks", "mdi:parking"},
+}
+
+var homeAssistantIdSanitizer = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// / Publish Home Assistant MQTT discovery configs for the selected stations
+// / not yet announced, so they show up as a device with a sensor per count.
+// / Configs are always retained so Home Assistant picks them up after it
+// / restarts, and the station's name is only known once it was sampled.
+func (m *MQTT) discover(snapshot *Snapshot) ([]mqtt.Token, error) {
+	var tokens []mqtt.Token
+	for _, id := range m.config.HomeAssistantStations {
+		station, ok := snapshot.Station(id)
+		if m.discovered[id] || !ok {
+			continue
+		}
+		node := "baywheels_" + homeAssistantIdSanitizer.ReplaceAllString(id, "_")
+		for _, sensor := range homeAssistantSensors {
+			payload, err := json.Marshal(map[string]any{
+				"name":                sensor.name,
+				"unique_id":           node + "_" + sensor.key,
+				"state_topic":         m.stationTopic(id),
+				"value_template":      "{{ value_json.status." + sensor.field + " }}",
+				"state_class":         "measurement",
+				"unit_of_measurement": sensor.unit,
+				"icon":                sensor.icon,
+				"device": map[string]any{
+					"identifiers":  []string{node},
+					"name":         station.Name,
+					"manufacturer": "Bay Wheels",
+					"model":        "Station",
+				},
+			})
+			if err != nil {
+				return nil, err
+			}
+			topic := fmt.Sprintf("%s/sensor/%s/%s/config", m.config.HomeAssistantPrefix, node, sensor.key)
+			tokens = append(tokens, m.client.Publish(topic, m.config.QoS, true, payload))
+		}
+		m.discovered[id] = true
+	}
+	return tokens, nil
+}
+
+func (m *MQTT) stationTopic(id string) string {
+	// topic levels are separated by / and + and # are wildcards
+	id = strings.NewReplacer("/", "_", "+", "_", "#", "_").Replace(id)
+	return strings.ReplaceAll(m.config.StationTopic, "{station_id}", id)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/patrickod/baywheels-exporter/pkg/gbfs"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// / StationNames is the last known name of every station, so station_status
+// / metrics keep their name label while station_information is failing
+// / rather than switching to name="unknown" and starting new series. If Path
+// / is set the names are also saved there, surviving restarts during an
+// / outage.
+// /
+// / With Normalize names are trimmed, runs of whitespace collapsed to a
+// / single space and unicode normalized, and a station keeps its name when
+// / only the casing changes, so cosmetic edits to the feed don't start new
+// / series. Transliterate also strips accents, e.g. for sinks that only take
+// / ASCII.
+type StationNames struct {
+	Path          string
+	Normalize     bool
+	Transliterate bool
+
+	names map[string]string
+}
+
+func NewStationNames() *StationNames {
+	return &StationNames{names: make(map[string]string)}
+}
+
+// / Load the names saved by earlier runs from Path, if it exists.
+func (n *StationNames) Load() error {
+	data, err := os.ReadFile(n.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &n.names)
+}
+
+func (n *StationNames) save() error {
+	data, err := json.Marshal(n.names)
+	if err != nil {
+		return err
+	}
+	tmp := n.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, n.Path)
+}
+
+// / Return the name of a station, or "unknown" if it was never seen in
+// / station_information.
+func (n *StationNames) Name(id string) string {
+	if name, ok := n.names[id]; ok && name != "" {
+		return name
+	}
+	return "unknown"
+}
+
+// / Update the names from a successfully fetched station_information,
+// / returning the previous label of every station whose name changed,
+// / "unknown" for stations named for the first time. Stations missing from
+// / the feed keep their last known name.
+func (n *StationNames) Update(information []gbfs.StationInformation) (map[string]string, error) {
+	changed := make(map[string]string)
+	for _, station := range information {
+		name, previous := string(station.Name), n.Name(station.StationId)
+		if n.Normalize && strings.EqualFold(name, previous) {
+			continue
+		}
+		if name != "" && name != previous {
+			changed[station.StationId] = previous
+			n.names[station.StationId] = name
+		}
+	}
+	if len(changed) == 0 || n.Path == "" {
+		return changed, nil
+	}
+	return changed, n.save()
+}
+
+// / Fill in the names of stations only known from station_status, as when
+// / station_information failed since startup.
+func (n *StationNames) fill(stations []Station) {
+	for i := range stations {
+		if stations[i].Name == "" {
+			if name, ok := n.names[stations[i].StationId]; ok {
+				stations[i].Name = gbfs.LocalizedString(name)
+			}
+		}
+	}
+}
+
+// / accents removes the combining marks of decomposed characters.
+var accents = runes.Remove(runes.In(unicode.Mn))
+
+// / Return name as it is used in labels.
+func (n *StationNames) normalize(name string) string {
+	if n.Normalize {
+		name = norm.NFC.String(strings.Join(strings.Fields(name), " "))
+	}
+	if n.Transliterate {
+		if folded, _, err := transform.String(transform.Chain(norm.NFD, accents, norm.NFC), name); err == nil {
+			name = folded
+		}
+	}
+	return name
+}
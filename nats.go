@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+type NATSConfig struct {
+	URL         string
+	Credentials string
+	Prefix      string
+}
+
+// / NATS is a Sink publishing change events rather than snapshots:
+// /
+// /	<prefix>.stations.<station_id>.changed    a StationChange
+// /	<prefix>.bikes.<bike_id>.appeared         a BikeEvent
+// /	<prefix>.bikes.<bike_id>.disappeared      a BikeEvent
+// /
+// / so consumers can subscribe to e.g. <prefix>.stations.> without diffing
+// / snapshots themselves.
+type NATS struct {
+	config NATSConfig
+	conn   *nats.Conn
+}
+
+func NewNATS(config NATSConfig) (*NATS, error) {
+	opts := []nats.Option{
+		nats.Name("baywheels-exporter"),
+		// keep trying in the background if the server is down at startup
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+	}
+	if config.Credentials != "" {
+		opts = append(opts, nats.UserCredentials(config.Credentials))
+	}
+	conn, err := nats.Connect(config.URL, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &NATS{config: config, conn: conn}, nil
+}
+
+func (n *NATS) Name() string {
+	return "nats"
+}
+
+func (n *NATS) Send(ctx context.Context, cycle *Cycle) error {
+	for _, change := range cycle.Changes {
+		if err := n.publish(n.subject("stations", change.StationId, "changed"), change); err != nil {
+			return err
+		}
+	}
+	for _, event := range cycle.BikeEvents {
+		if err := n.publish(n.subject("bikes", event.Bike.BikeId, event.Type), event); err != nil {
+			return err
+		}
+	}
+	return n.conn.FlushWithContext(ctx)
+}
+
+func (n *NATS) publish(subject string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return n.conn.Publish(subject, data)
+}
+
+// / Subject tokens are separated by dots and may not contain whitespace or
+// / the * and > wildcards.
+var natsTokenSanitizer = strings.NewReplacer(".", "_", " ", "_", "\t", "_", "*", "_", ">", "_")
+
+func (n *NATS) subject(kind, id, event string) string {
+	return n.config.Prefix + "." + kind + "." + natsTokenSanitizer.Replace(id) + "." + event
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/patrickod/baywheels-exporter/pkg/gbfs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// / NearbyStation is a station found by `nearest`, and how far it is.
+type NearbyStation struct {
+	StationId       string  `json:"station_id"`
+	ShortName       string  `json:"short_name,omitempty"`
+	Name            string  `json:"name"`
+	Lat             float64 `json:"lat"`
+	Lon             float64 `json:"lon"`
+	Distance        float64 `json:"distance_meters"`
+	BikesAvailable  int     `json:"num_bikes_available"`
+	EBikesAvailable int     `json:"num_ebikes_available"`
+	DocksAvailable  int     `json:"num_docks_available"`
+}
+
+// / Return the stations of snapshot closest to lat, lon, nearest first, up
+// / to n of them. Only stations renting a bike, an ebike if ebike is set, or
+// / returning to a free dock if docks is set are considered.
+func nearestStations(snapshot *Snapshot, lat, lon float64, ebike, docks bool, n int) []NearbyStation {
+	var nearby []NearbyStation
+	for _, station := range snapshot.Stations {
+		status := station.Status
+		if status == nil || status.IsInstalled == 0 {
+			continue
+		}
+		if docks {
+			if status.IsReturning == 0 || status.DocksAvailable == 0 {
+				continue
+			}
+		} else if status.IsRenting == 0 || status.BikesAvailable == 0 || ebike && status.EBikesAvailable == 0 {
+			continue
+		}
+		nearby = append(nearby, NearbyStation{
+			StationId:       station.StationId,
+			ShortName:       string(station.ShortName),
+			Name:            string(station.Name),
+			Lat:             station.Lat,
+			Lon:             station.Lon,
+			Distance:        distance(lat, lon, station.Lat, station.Lon),
+			BikesAvailable:  status.BikesAvailable,
+			EBikesAvailable: status.EBikesAvailable,
+			DocksAvailable:  status.DocksAvailable,
+		})
+	}
+	sort.Slice(nearby, func(i, j int) bool {
+		return nearby[i].Distance < nearby[j].Distance
+	})
+	if n > 0 && len(nearby) > n {
+		nearby = nearby[:n]
+	}
+	return nearby
+}
+
+// / Format a distance in meters for people: meters up to a kilometer, then
+// / kilometers.
+func formatDistance(meters float64) string {
+	if meters < 1000 {
+		return fmt.Sprintf("%.0f m", math.Round(meters/10)*10)
+	}
+	return fmt.Sprintf("%.1f km", meters/1000)
+}
+
+// / Run `nearest`, fetching the current availability once and printing the
+// / stations closest to -lat, -lon with a bike, an ebike with -ebike, or a
+// / free dock with -docks.
+func runNearest(args []string) error {
+	fs := flag.NewFlagSet("nearest", flag.ExitOnError)
+	gbfsURL := fs.String("gbfs.url", BaywheelsURI, "Base URL of the GBFS feeds, or the URL of the system's gbfs.json")
+	lat := fs.Float64("lat", math.NaN(), "Latitude to search from")
+	lon := fs.Float64("lon", math.NaN(), "Longitude to search from")
+	ebike := fs.Bool("ebike", false, "Only stations with an ebike available")
+	docks := fs.Bool("docks", false, "Stations with a free dock to return a bike to, rather than a bike to rent")
+	n := fs.Int("n", 5, "Number of stations to print; all if zero")
+	format := fs.String("format", "table", "Output format, either table or jsonl")
+	var upstream UpstreamAuth
+	upstream.Flags(fs)
+	fs.Parse(args)
+
+	if math.IsNaN(*lat) || math.IsNaN(*lon) {
+		return fmt.Errorf("-lat and -lon are required")
+	}
+	if *format != "table" && *format != "jsonl" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+	client, err := upstream.Client(prometheus.NewRegistry())
+	if err != nil {
+		return err
+	}
+	snapshot, err := fetchSnapshot(context.Background(), gbfs.NewClient(*gbfsURL, client), false)
+	if err != nil {
+		return err
+	}
+	nearby := nearestStations(snapshot, *lat, *lon, *ebike, *docks, *n)
+
+	if *format == "jsonl" {
+		enc := json.NewEncoder(os.Stdout)
+		for _, station := range nearby {
+			if err := enc.Encode(station); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if len(nearby) == 0 {
+		return fmt.Errorf("no stations found")
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "STATION\tNAME\tDISTANCE\tBIKES\tEBIKES\tDOCKS")
+	for _, station := range nearby {
+		id := station.ShortName
+		if id == "" {
+			id = station.StationId
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\n", id, station.Name, formatDistance(station.Distance), station.BikesAvailable, station.EBikesAvailable, station.DocksAvailable)
+	}
+	return w.Flush()
+}
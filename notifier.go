@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/patrickod/baywheels-exporter/pkg/gbfs"
+)
+
+// / notifierMetrics are the counts a watch can be put on, with the singular
+// / and plural used in messages.
+var notifierMetrics = map[string]struct {
+	value            func(*gbfs.StationStatus) int
+	singular, plural string
+}{
+	"bikes":  {func(s *gbfs.StationStatus) int { return s.BikesAvailable }, "bike", "bikes"},
+	"ebikes": {func(s *gbfs.StationStatus) int { return s.EBikesAvailable }, "ebike", "ebikes"},
+	"docks":  {func(s *gbfs.StationStatus) int { return s.DocksAvailable }, "dock", "docks"},
+}
+
+type NotifierConfig struct {
+	// Type is either "slack" or "discord", both using incoming webhook URLs.
+	Type string `yaml:"type"`
+	URL  string `yaml:"url"`
+	// Cooldown is the minimum time between two messages about the same
+	// watch, so a station flapping around its threshold is only reported
+	// once.
+	Cooldown time.Duration `yaml:"cooldown"`
+	Watch    []WatchConfig `yaml:"watch"`
+}
+
+// / WatchConfig notifies when a station's bikes, ebikes or docks available
+// / drop below a threshold.
+type WatchConfig struct {
+	Station string `yaml:"station"`
+	Metric  string `yaml:"metric"`
+	Below   int    `yaml:"below"`
+}
+
+type watch struct {
+	config   WatchConfig
+	seen     bool
+	below    bool
+	lastSent time.Time
+}
+
+type notifier struct {
+	config  NotifierConfig
+	watches []*watch
+}
+
+// / Notifiers is a Sink posting a chat message to Slack or Discord when a
+// / watched station runs low, e.g. "SF Caltrain has 0 ebikes".
+type Notifiers struct {
+	// Leading, if set, reports whether this replica is the leader, as for
+	// Webhooks: standby replicas keep observing but don't post.
+	Leading func() bool
+
+	notifiers []*notifier
+	client    *http.Client
+}
+
+func NewNotifiers(configs []NotifierConfig) (*Notifiers, error) {
+	n := &Notifiers{client: &http.Client{}}
+	for _, config := range configs {
+		if config.Type != "slack" && config.Type != "discord" {
+			return nil, fmt.Errorf("unknown notifier type %q", config.Type)
+		}
+		if config.URL == "" {
+			return nil, fmt.Errorf("%s notifier without a url", config.Type)
+		}
+		nt := &notifier{config: config}
+		for _, wc := range config.Watch {
+			if wc.Metric == "" {
+				wc.Metric = "bikes"
+			}
+			if _, ok := notifierMetrics[wc.Metric]; !ok {
+				return nil, fmt.Errorf("unknown notifier metric %q", wc.Metric)
+			}
+			if wc.Below == 0 {
+				wc.Below = 1
+			}
+			nt.watches = append(nt.watches, &watch{config: wc})
+		}
+		n.notifiers = append(n.notifiers, nt)
+	}
+	return n, nil
+}
+
+func (n *Notifiers) Name() string {
+	return "notifier"
+}
+
+func (n *Notifiers) Send(ctx context.Context, cycle *Cycle) error {
+	var errs []error
+	for _, nt := range n.notifiers {
+		messages := nt.observe(cycle.Snapshot)
+		if n.Leading != nil && !n.Leading() {
+			continue
+		}
+		for _, msg := range messages {
+			if err := n.post(ctx, nt.config, msg); err != nil {
+				errs = append(errs, fmt.Errorf("%s notifier: %w", nt.config.Type, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// / Return the messages for watches that dropped below their threshold this
+// / cycle. As with webhooks the first observation only establishes the
+// / state.
+func (nt *notifier) observe(snapshot *Snapshot) []string {
+	var messages []string
+	for _, w := range nt.watches {
+		station, ok := snapshot.Station(w.config.Station)
+		if !ok || station.Status == nil {
+			continue
+		}
+		metric := notifierMetrics[w.config.Metric]
+		value := metric.value(station.Status)
+		below := value < w.config.Below
+		fire := w.seen && below && !w.below && snapshot.Time.Sub(w.lastSent) >= nt.config.Cooldown
+		w.seen, w.below = true, below
+		if !fire {
+			continue
+		}
+		w.lastSent = snapshot.Time
+
+		unit := metric.plural
+		if value == 1 {
+			unit = metric.singular
+		}
+		messages = append(messages, fmt.Sprintf("%s has %d %s", station.Name, value, unit))
+	}
+	return messages
+}
+
+func (n *Notifiers) post(ctx context.Context, config NotifierConfig, msg string) error {
+	var payload any
+	switch config.Type {
+	case "slack":
+		payload = struct {
+			Text string `json:"text"`
+		}{msg}
+	case "discord":
+		payload = struct {
+			Content string `json:"content"`
+		}{msg}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, n.client, config.URL, nil, body)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// / OccupancyMetrics accumulates the time each station spends completely
+// / empty or full. The time between two samples is attributed to the state
+// / seen at the first of them, and stations that aren't installed are not
+// / counted.
+type OccupancyMetrics struct {
+	station_empty_seconds_total prometheus.CounterVec
+	station_full_seconds_total  prometheus.CounterVec
+
+	// last is the latest snapshot with freshly fetched statuses, or nil
+	// after statuses were held
+	last *Snapshot
+}
+
+func NewOccupancyMetrics(reg prometheus.Registerer) *OccupancyMetrics {
+	m := &OccupancyMetrics{
+		station_empty_seconds_total: *prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "station_empty_seconds_total",
+			Help: "Seconds the station has had no bikes available",
+		},
+			[]string{"station_id", "name"},
+		),
+		station_full_seconds_total: *prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "station_full_seconds_total",
+			Help: "Seconds the station has had no docks available",
+		},
+			[]string{"station_id", "name"},
+		),
+	}
+	reg.MustRegister(m.station_empty_seconds_total)
+	reg.MustRegister(m.station_full_seconds_total)
+
+	return m
+}
+
+// / Add the time since the previous fresh snapshot to the stations that were
+// / empty or full in it. fresh is false when snapshot holds the statuses of
+// / an earlier cycle, either because station_status wasn't due or, when
+// / held is set, because they are held over a closure or a failed fetch. The
+// / time spent held isn't attributed to any state.
+func (m *OccupancyMetrics) Observe(snapshot *Snapshot, fresh, held bool) {
+	if held {
+		m.last = nil
+	}
+	if !fresh {
+		return
+	}
+	prev := m.last
+	m.last = snapshot
+
+	for _, station := range snapshot.Stations {
+		if station.Status == nil {
+			continue
+		}
+		labels := stationLabels(station.StationId, string(station.Name))
+		empty := m.station_empty_seconds_total.With(labels)
+		full := m.station_full_seconds_total.With(labels)
+		if prev == nil {
+			continue
+		}
+
+		before, _ := prev.Station(station.StationId)
+		if before.Status == nil || before.Status.IsInstalled == 0 {
+			continue
+		}
+		elapsed := snapshot.Time.Sub(prev.Time).Seconds()
+		if before.Status.BikesAvailable == 0 {
+			empty.Add(elapsed)
+		}
+		if before.Status.DocksAvailable == 0 {
+			full.Add(elapsed)
+		}
+	}
+}
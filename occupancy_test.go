@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/patrickod/baywheels-exporter/pkg/gbfs"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestOccupancyHeld(t *testing.T) {
+	m := NewOccupancyMetrics(prometheus.NewRegistry())
+	information := []gbfs.StationInformation{{StationId: "1", Name: "Market St"}}
+	empty := []gbfs.StationStatus{{StationId: "1", IsInstalled: 1, DocksAvailable: 10}}
+	start := time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)
+	at := func(minutes int) *Snapshot {
+		return NewSnapshot(start.Add(time.Duration(minutes)*time.Minute), information, empty, nil)
+	}
+
+	m.Observe(at(0), true, false)
+	m.Observe(at(1), true, false)
+	// station_status not due, its statuses are still current
+	m.Observe(at(2), false, false)
+	m.Observe(at(3), true, false)
+	// closed overnight, or the fetch failing, holds the statuses
+	m.Observe(at(4), false, true)
+	m.Observe(at(300), false, true)
+	m.Observe(at(301), true, false)
+	m.Observe(at(302), true, false)
+
+	var metric dto.Metric
+	if err := m.station_empty_seconds_total.With(stationLabels("1", "Market St")).Write(&metric); err != nil {
+		t.Fatal(err)
+	}
+	// minutes 0 to 3 and 301 to 302
+	if got, want := metric.GetCounter().GetValue(), (4 * time.Minute).Seconds(); got != want {
+		t.Errorf("station_empty_seconds_total = %v, want %v", got, want)
+	}
+}
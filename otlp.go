@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/version"
+	otelprometheus "go.opentelemetry.io/contrib/bridges/prometheus"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
+type OTLPConfig struct {
+	Endpoint string
+	// Protocol is either "grpc" or "http/protobuf", as in
+	// OTEL_EXPORTER_OTLP_PROTOCOL.
+	Protocol string
+	Insecure bool
+	Headers  map[string]string
+}
+
+// / OTLP is a Sink translating each cycle's metrics into OpenTelemetry
+// / metrics and exporting them to an OTLP collector.
+type OTLP struct {
+	exporter sdkmetric.Exporter
+	resource *resource.Resource
+}
+
+func NewOTLP(ctx context.Context, config OTLPConfig) (*OTLP, error) {
+	var exporter sdkmetric.Exporter
+	var err error
+	switch config.Protocol {
+	case "grpc":
+		opts := []otlpmetricgrpc.Option{otlpmetricgrpc.WithEndpoint(config.Endpoint), otlpmetricgrpc.WithHeaders(config.Headers)}
+		if config.Insecure {
+			opts = append(opts, otlpmetricgrpc.WithInsecure())
+		}
+		exporter, err = otlpmetricgrpc.New(ctx, opts...)
+	case "http/protobuf":
+		opts := []otlpmetrichttp.Option{otlpmetrichttp.WithEndpoint(config.Endpoint), otlpmetrichttp.WithHeaders(config.Headers)}
+		if config.Insecure {
+			opts = append(opts, otlpmetrichttp.WithInsecure())
+		}
+		exporter, err = otlpmetrichttp.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unknown OTLP protocol %q", config.Protocol)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := otelResource()
+	if err != nil {
+		return nil, err
+	}
+
+	return &OTLP{exporter: exporter, resource: res}, nil
+}
+
+// / Return the OpenTelemetry resource describing the exporter, shared by its
+// / metrics and traces.
+func otelResource() (*resource.Resource, error) {
+	// merge with the default resource so OTEL_RESOURCE_ATTRIBUTES is honoured
+	return resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName("baywheels-exporter"),
+		semconv.ServiceVersion(version.Version),
+	))
+}
+
+func (o *OTLP) Name() string {
+	return "otlp"
+}
+
+func (o *OTLP) Send(ctx context.Context, cycle *Cycle) error {
+	producer := otelprometheus.NewMetricProducer(otelprometheus.WithGatherer(
+		prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+			return cycle.Families, nil
+		}),
+	))
+	scopes, err := producer.Produce(ctx)
+	if err != nil {
+		return err
+	}
+	return o.exporter.Export(ctx, &metricdata.ResourceMetrics{Resource: o.resource, ScopeMetrics: scopes})
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+type ParquetConfig struct {
+	Dir string
+	// Period is either "hourly" or "daily" and determines how many cycles
+	// end up in each file.
+	Period string
+}
+
+type parquetStationRow struct {
+	SampledAt           time.Time `parquet:"sampled_at,timestamp(millisecond)"`
+	StationId           string    `parquet:"station_id,dict"`
+	Name                string    `parquet:"name,dict"`
+	IsInstalled         int32     `parquet:"is_installed"`
+	IsRenting           int32     `parquet:"is_renting"`
+	IsReturning         int32     `parquet:"is_returning"`
+	LastReported        int64     `parquet:"last_reported"`
+	BikesAvailable      int32     `parquet:"num_bikes_available"`
+	BikesDisabled       int32     `parquet:"num_bikes_disabled"`
+	DocksAvailable      int32     `parquet:"num_docks_available"`
+	DocksDisabled       int32     `parquet:"num_docks_disabled"`
+	EBikesAvailable     int32     `parquet:"num_ebikes_available"`
+	ScootersAvailable   int32     `parquet:"num_scooters_available"`
+	ScootersUnavailable int32     `parquet:"num_scooters_unavailable"`
+}
+
+type parquetBikeRow struct {
+	SampledAt  time.Time `parquet:"sampled_at,timestamp(millisecond)"`
+	BikeId     string    `parquet:"bike_id"`
+	IsDisabled int32     `parquet:"is_disabled"`
+	IsReserved int32     `parquet:"is_reserved"`
+	Lat        float64   `parquet:"lat"`
+	Lon        float64   `parquet:"lon"`
+}
+
+// / ParquetArchive is a Sink writing the raw station_status and
+// / free_bike_status samples of every cycle to hourly or daily Parquet files:
+// /
+// /	<dir>/station_status/2006-01-02T15.parquet
+// /	<dir>/free_bike_status/2006-01-02T15.parquet
+// /
+// / Parquet files can't be appended to, so each cycle's rows are written to a
+// / small file of their own under the period's parts directory, e.g.
+// / <dir>/station_status/2006-01-02T15.parts/, and the parts are merged into
+// / the period's file once it is over. Only a cycle's rows are held in memory
+// / and every file is replaced atomically, so a restart picks up where it
+// / left off, merging the parts earlier runs left behind.
+type ParquetArchive struct {
+	// Completed is called with every file whose period has ended, including
+	// those left behind by previous runs, e.g. to upload it elsewhere.
+	Completed func(path string)
+
+	config ParquetConfig
+	layout string
+	length time.Duration
+
+	period time.Time
+}
+
+// / the feeds archived, with the merge of each one's parts
+var parquetFeeds = map[string]func(parts, path string) error{
+	"station_status":   mergeParquet[parquetStationRow],
+	"free_bike_status": mergeParquet[parquetBikeRow],
+}
+
+// / merged files hold row groups of up to this many rows, so merging doesn't
+// / buffer a whole period
+const parquetRowGroupRows = 100_000
+
+func NewParquetArchive(config ParquetConfig) (*ParquetArchive, error) {
+	a := &ParquetArchive{config: config}
+	switch config.Period {
+	case "hourly":
+		a.layout, a.length = "2006-01-02T15", time.Hour
+	case "daily":
+		a.layout, a.length = "2006-01-02", 24*time.Hour
+	default:
+		return nil, fmt.Errorf("unknown Parquet period %q", config.Period)
+	}
+	for feed := range parquetFeeds {
+		if err := os.MkdirAll(filepath.Join(config.Dir, feed), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+func (a *ParquetArchive) Name() string {
+	return "parquet"
+}
+
+func (a *ParquetArchive) Send(ctx context.Context, cycle *Cycle) error {
+	// unix time is aligned to UTC days, so truncating gives UTC periods
+	period := cycle.Time.UTC().Truncate(a.length)
+	if !period.Equal(a.period) {
+		if err := a.rollover(period); err != nil {
+			return err
+		}
+	}
+
+	sampledAt := cycle.Time.UTC()
+	part := sampledAt.Format("2006-01-02T150405.000") + ".parquet"
+	if cycle.Fetched["station_status"] {
+		var rows []parquetStationRow
+		for _, station := range cycle.Snapshot.Stations {
+			s := station.Status
+			if s == nil {
+				continue
+			}
+			rows = append(rows, parquetStationRow{
+				SampledAt:           sampledAt,
+				StationId:           station.StationId,
+				Name:                string(station.Name),
+				IsInstalled:         int32(s.IsInstalled),
+				IsRenting:           int32(s.IsRenting),
+				IsReturning:         int32(s.IsReturning),
+				LastReported:        s.LastReported.Seconds(),
+				BikesAvailable:      int32(s.BikesAvailable),
+				BikesDisabled:       int32(s.BikesDisabled),
+				DocksAvailable:      int32(s.DocksAvailable),
+				DocksDisabled:       int32(s.DocksDisabled),
+				EBikesAvailable:     int32(s.EBikesAvailable),
+				ScootersAvailable:   int32(s.ScootersAvailable),
+				ScootersUnavailable: int32(s.ScootersUnavailable),
+			})
+		}
+		if err := writePart(a.parts("station_status", period), part, rows); err != nil {
+			return err
+		}
+	}
+	if cycle.Fetched["free_bike_status"] {
+		var rows []parquetBikeRow
+		for _, bike := range cycle.Snapshot.Bikes {
+			rows = append(rows, parquetBikeRow{
+				SampledAt:  sampledAt,
+				BikeId:     bike.BikeId,
+				IsDisabled: int32(bike.IsDisabled),
+				IsReserved: int32(bike.IsReserved),
+				Lat:        bike.Lat,
+				Lon:        bike.Lon,
+			})
+		}
+		if err := writePart(a.parts("free_bike_status", period), part, rows); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// / Start a new period, merging the parts of the previous one into its file.
+// / On startup that covers the parts of every earlier period a previous run
+// / left behind, and every file other than the new period's is reported as
+// / completed.
+func (a *ParquetArchive) rollover(period time.Time) error {
+	previous := a.period
+	a.period = period
+	for feed, merge := range parquetFeeds {
+		if !previous.IsZero() {
+			path := a.path(feed, previous)
+			if err := merge(a.parts(feed, previous), path); err != nil {
+				return err
+			}
+			if _, err := os.Stat(path); err == nil && a.Completed != nil {
+				a.Completed(path)
+			}
+			continue
+		}
+
+		dirs, err := filepath.Glob(filepath.Join(a.config.Dir, feed, "*.parts"))
+		if err != nil {
+			return err
+		}
+		for _, dir := range dirs {
+			if dir != a.parts(feed, period) {
+				if err := merge(dir, strings.TrimSuffix(dir, ".parts")+".parquet"); err != nil {
+					return err
+				}
+			}
+		}
+		if a.Completed == nil {
+			continue
+		}
+		files, err := filepath.Glob(filepath.Join(a.config.Dir, feed, "*.parquet"))
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if file != a.path(feed, period) {
+				a.Completed(file)
+			}
+		}
+	}
+	return nil
+}
+
+func (a *ParquetArchive) path(feed string, period time.Time) string {
+	return filepath.Join(a.config.Dir, feed, period.Format(a.layout)+".parquet")
+}
+
+func (a *ParquetArchive) parts(feed string, period time.Time) string {
+	return filepath.Join(a.config.Dir, feed, period.Format(a.layout)+".parts")
+}
+
+// / Write rows as a part named name in the parts directory, unless there
+// / are none.
+func writePart[T any](parts, name string, rows []T) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(parts, 0o755); err != nil {
+		return err
+	}
+	return writeParquet(filepath.Join(parts, name), func(w *parquet.GenericWriter[T]) error {
+		_, err := w.Write(rows)
+		return err
+	})
+}
+
+// / Merge the parts in the parts directory, in order, into the file at path,
+// / after the rows of any file already there, and remove them. There is
+// / nothing to do if there are no parts.
+func mergeParquet[T any](parts, path string) error {
+	files, err := filepath.Glob(filepath.Join(parts, "*.parquet"))
+	if err != nil || len(files) == 0 {
+		os.RemoveAll(parts)
+		return err
+	}
+	sort.Strings(files)
+	if _, err := os.Stat(path); err == nil {
+		files = append([]string{path}, files...)
+	}
+	err = writeParquet(path, func(w *parquet.GenericWriter[T]) error {
+		for _, file := range files {
+			if err := copyParquet(w, file); err != nil {
+				return fmt.Errorf("%s: %w", file, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(parts)
+}
+
+// / Copy the rows of the file at path to w.
+func copyParquet[T any](w *parquet.GenericWriter[T], path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	file, err := parquet.OpenFile(f, info.Size())
+	if err != nil {
+		return err
+	}
+	for _, rowGroup := range file.RowGroups() {
+		rows := rowGroup.Rows()
+		_, err := parquet.CopyRows(w, rows)
+		rows.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// / Write a Parquet file to path with write, replacing it atomically.
+func writeParquet[T any](path string, write func(w *parquet.GenericWriter[T]) error) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	w := parquet.NewGenericWriter[T](f, parquet.Compression(&parquet.Zstd), parquet.MaxRowsPerRowGroup(parquetRowGroupRows))
+	if err := write(w); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/patrickod/baywheels-exporter/pkg/gbfs"
+)
+
+func TestParquetArchive(t *testing.T) {
+	dir := t.TempDir()
+	information := []gbfs.StationInformation{{StationId: "1", Name: "Market St"}, {StationId: "2", Name: "Mission St"}}
+	start := time.Date(2026, 1, 5, 8, 0, 0, 0, time.UTC)
+	send := func(a *ParquetArchive, minutes int) {
+		t.Helper()
+		at := start.Add(time.Duration(minutes) * time.Minute)
+		statuses := []gbfs.StationStatus{
+			{StationId: "1", BikesAvailable: minutes},
+			{StationId: "2", BikesAvailable: 100 + minutes},
+		}
+		bikes := []gbfs.BikeStatus{{BikeId: "b", Lat: 37.77, Lon: float64(minutes)}}
+		cycle := &Cycle{
+			Time:     at,
+			Snapshot: NewSnapshot(at, information, statuses, bikes),
+			Fetched:  map[string]bool{"station_status": true, "free_bike_status": minutes%2 == 0},
+		}
+		if err := a.Send(context.Background(), cycle); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var completed []string
+	open := func() *ParquetArchive {
+		t.Helper()
+		a, err := NewParquetArchive(ParquetConfig{Dir: dir, Period: "hourly"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.Completed = func(path string) { completed = append(completed, path) }
+		return a
+	}
+
+	a := open()
+	send(a, 0)
+	send(a, 30)
+	// restarting mid-period carries on with the parts written so far
+	a = open()
+	send(a, 45)
+	send(a, 58)
+	send(a, 62)
+
+	hour := filepath.Join(dir, "station_status", "2026-01-05T08.parquet")
+	bikesHour := filepath.Join(dir, "free_bike_status", "2026-01-05T08.parquet")
+	if got, want := len(completed), 2; got != want {
+		t.Fatalf("completed %v, want the 08 files", completed)
+	}
+	stations, err := parquet.ReadFile[parquetStationRow](hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bikes []int32
+	for _, row := range stations {
+		bikes = append(bikes, row.BikesAvailable)
+	}
+	if want := []int32{0, 100, 30, 130, 45, 145, 58, 158}; !reflect.DeepEqual(bikes, want) {
+		t.Errorf("station rows have bikes %v, want %v", bikes, want)
+	}
+	bikeRows, err := parquet.ReadFile[parquetBikeRow](bikesHour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lons []float64
+	for _, row := range bikeRows {
+		lons = append(lons, row.Lon)
+	}
+	if want := []float64{0, 30, 58}; !reflect.DeepEqual(lons, want) {
+		t.Errorf("bike rows have lon %v, want %v", lons, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "station_status", "2026-01-05T08.parts")); !os.IsNotExist(err) {
+		t.Errorf("the merged parts are left behind: %v", err)
+	}
+
+	// a restart after the period merges and completes the parts left over
+	completed = nil
+	a = open()
+	send(a, 125)
+	for _, path := range []string{
+		filepath.Join(dir, "station_status", "2026-01-05T09.parquet"),
+		filepath.Join(dir, "free_bike_status", "2026-01-05T09.parquet"),
+	} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s not merged: %s", path, err)
+		}
+	}
+	if got, want := len(completed), 4; got != want {
+		t.Errorf("completed %v on startup, want the 08 and 09 files", completed)
+	}
+}
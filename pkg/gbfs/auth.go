@@ -0,0 +1,39 @@
+package gbfs
+
+import (
+	"net/http"
+)
+
+// / APIKey is an http.RoundTripper adding an API key to every request, for
+// / providers whose feeds require one, either in a header or a query
+// / parameter. Requests are cloned before the key is added, so it doesn't
+// / show up in the URLs of errors returned by the http.Client.
+type APIKey struct {
+	Key string
+	// Header is the name of the header carrying Key, e.g. Authorization
+	// with a Key of "Bearer <token>".
+	Header string
+	// Query is the name of the query parameter carrying Key, set instead of
+	// or as well as Header.
+	Query string
+	// Base is the RoundTripper the requests are sent with;
+	// http.DefaultTransport if nil.
+	Base http.RoundTripper
+}
+
+func (a *APIKey) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if a.Header != "" {
+		req.Header.Set(a.Header, a.Key)
+	}
+	if a.Query != "" {
+		query := req.URL.Query()
+		query.Set(a.Query, a.Key)
+		req.URL.RawQuery = query.Encode()
+	}
+	base := a.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
@@ -0,0 +1,349 @@
+// / Package gbfs is a client for the General Bikeshare Feed Specification,
+// / https://github.com/MobilityData/gbfs, with typed getters for every feed.
+// /
+// / A Client is created either with the URL of a system's gbfs.json
+// / auto-discovery file, in which case the feed URLs and GBFS version are
+// / discovered on first use, or with the base URL the feeds live under as
+// / <base>/<feed>.json.
+package gbfs
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type Client struct {
+	// Language picks the feeds of pre-v3.0 auto-discovery files, which
+	// list feeds per language. The first language is used if it is missing.
+	Language string
+	// OnFetch, if set, is called with the raw body of every feed fetched,
+	// named "gbfs" for the auto-discovery file, e.g. to record it. The body
+	// is reused once OnFetch returns, so it must be copied to be kept.
+	OnFetch func(feed string, body []byte)
+	// OnProblems, if set, is called with the problems Validate found in
+	// every fetched feed, none if it is valid, e.g. to count them.
+	OnProblems func(feed string, problems []Problem)
+	// Strict rejects feeds with problems with a ValidationError rather than
+	// decoding missing or invalid fields as zeros.
+	Strict bool
+
+	http *http.Client
+	url  string
+
+	mu      sync.Mutex
+	feeds   map[string]string
+	version string
+}
+
+// / Create a Client for url, either a gbfs.json auto-discovery file or a base
+// / URL. httpClient may be nil to use http.DefaultClient.
+func NewClient(url string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{Language: "en", http: httpClient, url: strings.TrimSuffix(url, "/")}
+}
+
+func (c *Client) discovers() bool {
+	return strings.HasSuffix(c.url, ".json")
+}
+
+// / Fetch and decode the auto-discovery file, remembering the feed URLs and
+// / version for later requests. It is called automatically by the getters of
+// / a Client created with a gbfs.json URL.
+func (c *Client) Discover(ctx context.Context) (*Response[Discovery], error) {
+	var resp Response[Discovery]
+	if err := c.get(ctx, "gbfs", c.url, &resp); err != nil {
+		return nil, err
+	}
+
+	feeds := resp.Data.Feeds
+	if feeds == nil {
+		if lang, ok := resp.Data.Languages[c.Language]; ok {
+			feeds = lang.Feeds
+		} else {
+			// map order is random, so pick the first language by name for
+			// a stable choice
+			first := ""
+			for name := range resp.Data.Languages {
+				if first == "" || name < first {
+					first = name
+				}
+			}
+			feeds = resp.Data.Languages[first].Feeds
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.feeds = make(map[string]string, len(feeds))
+	for _, feed := range feeds {
+		c.feeds[feed.Name] = feed.URL
+	}
+	c.version = resp.Version
+	return &resp, nil
+}
+
+// / Return the GBFS version reported by the auto-discovery file, or "" if
+// / the Client wasn't created with one or it hasn't been fetched yet.
+func (c *Client) Version() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.version
+}
+
+// / Return the names of the feeds listed by the auto-discovery file, sorted,
+// / discovering them first if needed. It returns nil for a Client created
+// / with a base URL, which can't list its feeds.
+func (c *Client) Feeds(ctx context.Context) ([]string, error) {
+	if !c.discovers() {
+		return nil, nil
+	}
+	c.mu.Lock()
+	discovered := c.feeds != nil
+	c.mu.Unlock()
+	if !discovered {
+		if _, err := c.Discover(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	names := make([]string, 0, len(c.feeds))
+	for name := range c.feeds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// / Return the URL of a feed, discovering it first if needed.
+func (c *Client) FeedURL(ctx context.Context, feed string) (string, error) {
+	if !c.discovers() {
+		return fmt.Sprintf("%s/%s.json", c.url, feed), nil
+	}
+
+	c.mu.Lock()
+	discovered := c.feeds != nil
+	c.mu.Unlock()
+	if !discovered {
+		if _, err := c.Discover(ctx); err != nil {
+			return "", err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	url, ok := c.feeds[feed]
+	if !ok {
+		// v3.0 renamed free_bike_status
+		if alias, renamed := feedAliases[feed]; renamed {
+			url, ok = c.feeds[alias]
+		}
+	}
+	if !ok {
+		return "", fmt.Errorf("system doesn't publish the %s feed", feed)
+	}
+	return url, nil
+}
+
+var feedAliases = map[string]string{
+	"free_bike_status": "vehicle_status",
+}
+
+// / Fetch a feed by name and decode it into v, e.g. for feeds without a
+// / typed getter.
+func (c *Client) Fetch(ctx context.Context, feed string, v any) error {
+	url, err := c.FeedURL(ctx, feed)
+	if err != nil {
+		return err
+	}
+	return c.get(ctx, feed, url, v)
+}
+
+// / bodies pools the buffers feed bodies are read into, so polling a system
+// / doesn't allocate a new buffer the size of every feed, every cycle.
+var bodies = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// / Buffers grown larger than this by an unusually large feed are left to
+// / the garbage collector rather than pooled.
+const maxPooledBody = 32 << 20
+
+// / tracer starts a span per feed fetched, a no-op unless the application
+// / sets an OpenTelemetry tracer provider.
+var tracer = otel.Tracer("github.com/patrickod/baywheels-exporter/pkg/gbfs")
+
+// / Response headers CDNs report whether and how long a feed was cached with,
+// / recorded on fetch spans.
+var cacheHeaders = []string{"Age", "Cache-Control", "CF-Cache-Status", "X-Cache"}
+
+func (c *Client) get(ctx context.Context, feed, url string, v any) (err error) {
+	ctx, span := tracer.Start(ctx, "fetch "+feed, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("gbfs.feed", feed),
+		attribute.String("url.full", url),
+	))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+	if span.IsRecording() {
+		ctx = httptrace.WithClientTrace(ctx, traceEvents(span))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	for _, header := range cacheHeaders {
+		if value := resp.Header.Get(header); value != "" {
+			span.SetAttributes(attribute.String("http.response.header."+strings.ToLower(header), value))
+		}
+	}
+	if resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	buf := bodies.Get().(*bytes.Buffer)
+	defer func() {
+		if buf.Cap() <= maxPooledBody {
+			buf.Reset()
+			bodies.Put(buf)
+		}
+	}()
+	if resp.ContentLength > 0 {
+		buf.Grow(int(min(resp.ContentLength, maxPooledBody)))
+	}
+	if _, err := buf.ReadFrom(resp.Body); err != nil {
+		return err
+	}
+	body := buf.Bytes()
+	span.SetAttributes(attribute.Int("http.response.body.size", len(body)))
+	if c.OnFetch != nil {
+		c.OnFetch(feed, body)
+	}
+	if c.OnProblems != nil || c.Strict {
+		problems := Validate(feed, body)
+		if c.OnProblems != nil {
+			c.OnProblems(feed, problems)
+		}
+		if len(problems) > 0 && c.Strict {
+			return &ValidationError{URL: url, Problems: problems}
+		}
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return &DecodeError{URL: url, Err: err}
+	}
+	return nil
+}
+
+// / DecodeError is returned for feeds that were fetched but couldn't be
+// / decoded, as opposed to network or HTTP errors.
+type DecodeError struct {
+	URL string
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("decoding %s: %s", e.URL, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+func fetch[T any](ctx context.Context, c *Client, feed string) (*Response[T], error) {
+	var resp Response[T]
+	if err := c.Fetch(ctx, feed, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *Client) Versions(ctx context.Context) (*Response[VersionsData], error) {
+	return fetch[VersionsData](ctx, c, "gbfs_versions")
+}
+
+func (c *Client) SystemInformation(ctx context.Context) (*Response[SystemInformation], error) {
+	return fetch[SystemInformation](ctx, c, "system_information")
+}
+
+func (c *Client) VehicleTypes(ctx context.Context) (*Response[VehicleTypesData], error) {
+	return fetch[VehicleTypesData](ctx, c, "vehicle_types")
+}
+
+func (c *Client) StationInformation(ctx context.Context) (*Response[StationInformationData], error) {
+	return fetch[StationInformationData](ctx, c, "station_information")
+}
+
+func (c *Client) StationStatus(ctx context.Context) (*Response[StationStatusData], error) {
+	return fetch[StationStatusData](ctx, c, "station_status")
+}
+
+// / Fetch free_bike_status, or vehicle_status for v3.0 systems.
+func (c *Client) FreeBikeStatus(ctx context.Context) (*Response[FreeBikeStatusData], error) {
+	return fetch[FreeBikeStatusData](ctx, c, "free_bike_status")
+}
+
+func (c *Client) SystemHours(ctx context.Context) (*Response[SystemHoursData], error) {
+	return fetch[SystemHoursData](ctx, c, "system_hours")
+}
+
+func (c *Client) SystemCalendar(ctx context.Context) (*Response[SystemCalendarData], error) {
+	return fetch[SystemCalendarData](ctx, c, "system_calendar")
+}
+
+func (c *Client) SystemRegions(ctx context.Context) (*Response[SystemRegionsData], error) {
+	return fetch[SystemRegionsData](ctx, c, "system_regions")
+}
+
+func (c *Client) SystemPricingPlans(ctx context.Context) (*Response[SystemPricingPlansData], error) {
+	return fetch[SystemPricingPlansData](ctx, c, "system_pricing_plans")
+}
+
+func (c *Client) SystemAlerts(ctx context.Context) (*Response[SystemAlertsData], error) {
+	return fetch[SystemAlertsData](ctx, c, "system_alerts")
+}
+
+func (c *Client) GeofencingZones(ctx context.Context) (*Response[GeofencingZonesData], error) {
+	return fetch[GeofencingZonesData](ctx, c, "geofencing_zones")
+}
+
+// / Return a ClientTrace adding the stages of a request to span as events,
+// / showing where the time of a slow fetch went.
+func traceEvents(span trace.Span) *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			span.AddEvent("got connection", trace.WithAttributes(attribute.Bool("reused", info.Reused)))
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) { span.AddEvent("dns done") },
+		ConnectDone: func(network, addr string, err error) {
+			span.AddEvent("connected", trace.WithAttributes(attribute.String("network.peer.address", addr)))
+		},
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { span.AddEvent("tls handshake done") },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { span.AddEvent("wrote request") },
+		GotFirstResponseByte: func() { span.AddEvent("first response byte") },
+	}
+}
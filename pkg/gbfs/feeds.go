@@ -0,0 +1,359 @@
+package gbfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// / Response is the envelope every GBFS feed is wrapped in.
+type Response[T any] struct {
+	LastUpdated Timestamp `json:"last_updated"`
+	TTL         int       `json:"ttl"`
+	Version     string    `json:"version"`
+	Data        T         `json:"data"`
+}
+
+// / Timestamp is a GBFS time, which is POSIX seconds before v3.0 and an
+// / RFC3339 string from v3.0 on.
+type Timestamp struct {
+	time.Time
+}
+
+func (t *Timestamp) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	if b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		parsed, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return err
+		}
+		t.Time = parsed
+		return nil
+	}
+	secs, err := strconv.ParseFloat(string(b), 64)
+	if err != nil {
+		return err
+	}
+	t.Time = time.Unix(int64(secs), 0)
+	return nil
+}
+
+// / Seconds returns the POSIX seconds of t, or 0 when it is unset.
+func (t Timestamp) Seconds() int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	return []byte(strconv.FormatInt(t.Unix(), 10)), nil
+}
+
+// / Flag is a GBFS boolean, which is 0 or 1 before v2.0 and true or false
+// / from v2.0 on, though some systems mix them up. It decodes from either and
+// / holds 0 or 1, so it can be used as a gauge value as is.
+type Flag int
+
+func (f *Flag) UnmarshalJSON(b []byte) error {
+	switch string(b) {
+	case "true":
+		*f = 1
+	case "false", "null":
+		*f = 0
+	default:
+		n, err := strconv.ParseFloat(string(b), 64)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %s", b)
+		}
+		*f = 0
+		if n != 0 {
+			*f = 1
+		}
+	}
+	return nil
+}
+
+// / LocalizedString is a GBFS text, which is a plain string before v3.0 and
+// / a list of translations from v3.0 on. It decodes from either and holds the
+// / first translation.
+type LocalizedString string
+
+func (l *LocalizedString) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '[' {
+		var translations []struct {
+			Text     string `json:"text"`
+			Language string `json:"language"`
+		}
+		if err := json.Unmarshal(b, &translations); err != nil {
+			return err
+		}
+		*l = ""
+		if len(translations) > 0 {
+			*l = LocalizedString(translations[0].Text)
+		}
+		return nil
+	}
+	var s *string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	*l = ""
+	if s != nil {
+		*l = LocalizedString(*s)
+	}
+	return nil
+}
+
+// / Feed is an entry of the gbfs.json auto-discovery file.
+type Feed struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// / Discovery is the data of gbfs.json. Before v3.0 feeds are listed per
+// / language, from v3.0 on there is a single list.
+type Discovery struct {
+	Feeds     []Feed                       `json:"feeds"`
+	Languages map[string]DiscoveryLanguage `json:"-"`
+}
+
+type DiscoveryLanguage struct {
+	Feeds []Feed `json:"feeds"`
+}
+
+func (d *Discovery) UnmarshalJSON(b []byte) error {
+	var v3 struct {
+		Feeds []Feed `json:"feeds"`
+	}
+	if err := json.Unmarshal(b, &v3); err == nil && v3.Feeds != nil {
+		d.Feeds = v3.Feeds
+		return nil
+	}
+	return json.Unmarshal(b, &d.Languages)
+}
+
+type Version struct {
+	Version string `json:"version"`
+	URL     string `json:"url"`
+}
+
+type VersionsData struct {
+	Versions []Version `json:"versions"`
+}
+
+type SystemInformation struct {
+	SystemId    string          `json:"system_id"`
+	Language    string          `json:"language"`
+	Name        LocalizedString `json:"name"`
+	ShortName   LocalizedString `json:"short_name"`
+	Operator    LocalizedString `json:"operator"`
+	URL         string          `json:"url"`
+	PurchaseURL string          `json:"purchase_url"`
+	StartDate   string          `json:"start_date"`
+	PhoneNumber string          `json:"phone_number"`
+	Email       string          `json:"email"`
+	Timezone    string          `json:"timezone"`
+	LicenseURL  string          `json:"license_url"`
+}
+
+type VehicleType struct {
+	VehicleTypeId  string          `json:"vehicle_type_id"`
+	FormFactor     string          `json:"form_factor"`
+	PropulsionType string          `json:"propulsion_type"`
+	MaxRangeMeters float64         `json:"max_range_meters"`
+	Name           LocalizedString `json:"name"`
+}
+
+type VehicleTypesData struct {
+	VehicleTypes []VehicleType `json:"vehicle_types"`
+}
+
+type StationInformation struct {
+	Name                        LocalizedString `json:"name"`
+	ShortName                   LocalizedString `json:"short_name"`
+	StationId                   string          `json:"station_id"`
+	StationType                 string          `json:"station_type"`
+	Lat                         float64         `json:"lat"`
+	Lon                         float64         `json:"lon"`
+	ExternalId                  string          `json:"external_id"`
+	Capacity                    int             `json:"capacity"`
+	HasKiosk                    bool            `json:"has_kiosk"`
+	ElectricBikeSurchargeWaiver bool            `json:"electric_bike_surcharge_waiver"`
+}
+
+type StationInformationData struct {
+	Stations []StationInformation `json:"stations"`
+}
+
+type StationStatus struct {
+	StationId           string    `json:"station_id"`
+	IsInstalled         Flag      `json:"is_installed"`
+	IsRenting           Flag      `json:"is_renting"`
+	IsReturning         Flag      `json:"is_returning"`
+	LastReported        Timestamp `json:"last_reported"`
+	BikesAvailable      int       `json:"num_bikes_available"`
+	BikesDisabled       int       `json:"num_bikes_disabled"`
+	DocksAvailable      int       `json:"num_docks_available"`
+	DocksDisabled       int       `json:"num_docks_disabled"`
+	EBikesAvailable     int       `json:"num_ebikes_available"`
+	ScootersAvailable   int       `json:"num_scooters_available"`
+	ScootersUnavailable int       `json:"num_scooters_unavailable"`
+	// VehicleTypesAvailable breaks BikesAvailable down by vehicle type, for
+	// systems publishing vehicle_types.
+	VehicleTypesAvailable []VehicleTypeCount `json:"vehicle_types_available,omitempty"`
+}
+
+// / UnmarshalJSON also decodes the num_vehicles_available and
+// / num_vehicles_disabled v3.0 renamed the bike counts to.
+func (s *StationStatus) UnmarshalJSON(b []byte) error {
+	type status StationStatus
+	var v struct {
+		status
+		VehiclesAvailable *int `json:"num_vehicles_available"`
+		VehiclesDisabled  *int `json:"num_vehicles_disabled"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*s = StationStatus(v.status)
+	if v.VehiclesAvailable != nil {
+		s.BikesAvailable = *v.VehiclesAvailable
+	}
+	if v.VehiclesDisabled != nil {
+		s.BikesDisabled = *v.VehiclesDisabled
+	}
+	return nil
+}
+
+type VehicleTypeCount struct {
+	VehicleTypeId string `json:"vehicle_type_id"`
+	Count         int    `json:"count"`
+}
+
+type StationStatusData struct {
+	Stations []StationStatus `json:"stations"`
+}
+
+type BikeStatus struct {
+	BikeId        string  `json:"bike_id"`
+	IsDisabled    Flag    `json:"is_disabled"`
+	IsReserved    Flag    `json:"is_reserved"`
+	Lat           float64 `json:"lat"`
+	Lon           float64 `json:"lon"`
+	VehicleTypeId string  `json:"vehicle_type_id,omitempty"`
+	// CurrentRangeMeters and CurrentFuelPercent (0-1) are only reported for
+	// motorized vehicles, and are nil otherwise.
+	CurrentRangeMeters *float64 `json:"current_range_meters,omitempty"`
+	CurrentFuelPercent *float64 `json:"current_fuel_percent,omitempty"`
+}
+
+// / FreeBikeStatusData holds the free bikes. v3.0 renamed the feed to
+// / vehicle_status, its list to vehicles and bike_id to vehicle_id; those
+// / are decoded into Bikes as well.
+type FreeBikeStatusData struct {
+	Bikes []BikeStatus `json:"bikes"`
+}
+
+func (d *FreeBikeStatusData) UnmarshalJSON(b []byte) error {
+	var v struct {
+		Bikes    []BikeStatus `json:"bikes"`
+		Vehicles []struct {
+			BikeStatus
+			VehicleId string `json:"vehicle_id"`
+		} `json:"vehicles"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	d.Bikes = v.Bikes
+	for _, vehicle := range v.Vehicles {
+		bike := vehicle.BikeStatus
+		bike.BikeId = vehicle.VehicleId
+		d.Bikes = append(d.Bikes, bike)
+	}
+	return nil
+}
+
+type RentalHour struct {
+	UserTypes []string `json:"user_types"`
+	Days      []string `json:"days"`
+	StartTime string   `json:"start_time"`
+	EndTime   string   `json:"end_time"`
+}
+
+type SystemHoursData struct {
+	RentalHours []RentalHour `json:"rental_hours"`
+}
+
+type Calendar struct {
+	StartMonth int `json:"start_month"`
+	StartDay   int `json:"start_day"`
+	StartYear  int `json:"start_year"`
+	EndMonth   int `json:"end_month"`
+	EndDay     int `json:"end_day"`
+	EndYear    int `json:"end_year"`
+}
+
+type SystemCalendarData struct {
+	Calendars []Calendar `json:"calendars"`
+}
+
+type Region struct {
+	RegionId string          `json:"region_id"`
+	Name     LocalizedString `json:"name"`
+}
+
+type SystemRegionsData struct {
+	Regions []Region `json:"regions"`
+}
+
+type PricingPlan struct {
+	PlanId      string          `json:"plan_id"`
+	Name        LocalizedString `json:"name"`
+	Currency    string          `json:"currency"`
+	Price       float64         `json:"price"`
+	IsTaxable   bool            `json:"is_taxable"`
+	Description LocalizedString `json:"description"`
+}
+
+type SystemPricingPlansData struct {
+	Plans []PricingPlan `json:"plans"`
+}
+
+type AlertTime struct {
+	Start Timestamp `json:"start"`
+	End   Timestamp `json:"end"`
+}
+
+type Alert struct {
+	AlertId     string          `json:"alert_id"`
+	Type        string          `json:"type"`
+	Times       []AlertTime     `json:"times"`
+	StationIds  []string        `json:"station_ids"`
+	RegionIds   []string        `json:"region_ids"`
+	URL         LocalizedString `json:"url"`
+	Summary     LocalizedString `json:"summary"`
+	Description LocalizedString `json:"description"`
+	LastUpdated Timestamp       `json:"last_updated"`
+}
+
+type SystemAlertsData struct {
+	Alerts []Alert `json:"alerts"`
+}
+
+// / GeofencingZonesData holds the zones as a GeoJSON FeatureCollection,
+// / left undecoded for a GeoJSON library of the caller's choice.
+type GeofencingZonesData struct {
+	GeofencingZones json.RawMessage `json:"geofencing_zones"`
+}
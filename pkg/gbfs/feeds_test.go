@@ -0,0 +1,162 @@
+package gbfs
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestFlagUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Flag
+		wantErr bool
+	}{
+		{in: `0`, want: 0},
+		{in: `1`, want: 1},
+		{in: `2`, want: 1},
+		{in: `1.0`, want: 1},
+		{in: `true`, want: 1},
+		{in: `false`, want: 0},
+		{in: `null`, want: 0},
+		{in: `"yes"`, wantErr: true},
+		{in: `"1"`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			// start from 1 so decoding to 0 is visible
+			f := Flag(1)
+			err := json.Unmarshal([]byte(tt.in), &f)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Unmarshal(%s) = %d, want error", tt.in, f)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unmarshal(%s): %s", tt.in, err)
+			}
+			if f != tt.want {
+				t.Errorf("Unmarshal(%s) = %d, want %d", tt.in, f, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimestampUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{in: `1700000000`, want: time.Unix(1700000000, 0)},
+		{in: `1700000000.75`, want: time.Unix(1700000000, 0)},
+		{in: `"2023-11-14T22:13:20Z"`, want: time.Unix(1700000000, 0)},
+		{in: `"2023-11-14T14:13:20-08:00"`, want: time.Unix(1700000000, 0)},
+		{in: `null`},
+		{in: `"2023-11-14 22:13:20"`, wantErr: true},
+		{in: `"soon"`, wantErr: true},
+		{in: `true`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			var ts Timestamp
+			err := json.Unmarshal([]byte(tt.in), &ts)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Unmarshal(%s) = %s, want error", tt.in, ts.Time)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unmarshal(%s): %s", tt.in, err)
+			}
+			if !ts.Equal(tt.want) {
+				t.Errorf("Unmarshal(%s) = %s, want %s", tt.in, ts.Time, tt.want)
+			}
+		})
+	}
+}
+
+func TestResponseLastUpdated(t *testing.T) {
+	// v2 feeds carry POSIX seconds and v3 feeds RFC3339 strings
+	for _, in := range []string{
+		`{"last_updated": 1700000000, "ttl": 60, "version": "2.3", "data": {}}`,
+		`{"last_updated": "2023-11-14T22:13:20Z", "ttl": 60, "version": "3.0", "data": {}}`,
+	} {
+		var resp Response[struct{}]
+		if err := json.Unmarshal([]byte(in), &resp); err != nil {
+			t.Fatalf("Unmarshal(%s): %s", in, err)
+		}
+		if want := time.Unix(1700000000, 0); !resp.LastUpdated.Equal(want) {
+			t.Errorf("Unmarshal(%s).LastUpdated = %s, want %s", in, resp.LastUpdated.Time, want)
+		}
+	}
+}
+
+func TestLocalizedStringUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    LocalizedString
+		wantErr bool
+	}{
+		{in: `"Market St at 10th St"`, want: "Market St at 10th St"},
+		{in: `[{"text": "Market St at 10th St", "language": "en"}, {"text": "Calle Market", "language": "es"}]`, want: "Market St at 10th St"},
+		{in: `[]`},
+		{in: `null`},
+		{in: `1`, wantErr: true},
+		{in: `[{"text": 1}]`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			// start from a name so decoding to "" is visible
+			l := LocalizedString("previous")
+			err := json.Unmarshal([]byte(tt.in), &l)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Unmarshal(%s) = %q, want error", tt.in, l)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unmarshal(%s): %s", tt.in, err)
+			}
+			if l != tt.want {
+				t.Errorf("Unmarshal(%s) = %q, want %q", tt.in, l, tt.want)
+			}
+		})
+	}
+}
+
+func TestStationFeedsV3(t *testing.T) {
+	// v2 and v3 versions of the same station
+	var information [2]StationInformationData
+	for i, in := range []string{
+		`{"stations": [{"station_id": "1", "name": "Market St", "short_name": "SF-1", "lat": 37.77, "lon": -122.41, "capacity": 19}]}`,
+		`{"stations": [{"station_id": "1", "name": [{"text": "Market St", "language": "en"}], "short_name": [{"text": "SF-1", "language": "en"}], "lat": 37.77, "lon": -122.41, "capacity": 19}]}`,
+	} {
+		if err := json.Unmarshal([]byte(in), &information[i]); err != nil {
+			t.Fatalf("Unmarshal(%s): %s", in, err)
+		}
+	}
+	if information[0].Stations[0] != information[1].Stations[0] {
+		t.Errorf("v3 station_information decoded to %+v, want %+v", information[1].Stations[0], information[0].Stations[0])
+	}
+
+	var status [2]StationStatusData
+	for i, in := range []string{
+		`{"stations": [{"station_id": "1", "is_installed": true, "is_renting": true, "is_returning": true, "last_reported": 1700000000, "num_bikes_available": 7, "num_bikes_disabled": 1, "num_docks_available": 11}]}`,
+		`{"stations": [{"station_id": "1", "is_installed": true, "is_renting": true, "is_returning": true, "last_reported": "2023-11-14T22:13:20Z", "num_vehicles_available": 7, "num_vehicles_disabled": 1, "num_docks_available": 11}]}`,
+	} {
+		if err := json.Unmarshal([]byte(in), &status[i]); err != nil {
+			t.Fatalf("Unmarshal(%s): %s", in, err)
+		}
+	}
+	v2, v3 := status[0].Stations[0], status[1].Stations[0]
+	if !v3.LastReported.Equal(v2.LastReported.Time) || v3.LastReported.Seconds() != 1700000000 {
+		t.Errorf("v3 last_reported decoded to %s, want %s", v3.LastReported.Time, v2.LastReported.Time)
+	}
+	if v3.BikesAvailable != 7 || v3.BikesDisabled != 1 || v3.DocksAvailable != 11 || v3.IsRenting != 1 {
+		t.Errorf("v3 station_status decoded to %+v, want %+v", v3, v2)
+	}
+}
@@ -0,0 +1,226 @@
+package gbfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// / Reasons a Problem is reported for.
+const (
+	ReasonMissingField = "missing_field"
+	ReasonInvalidType  = "invalid_type"
+	ReasonOutOfRange   = "out_of_range"
+)
+
+// / Problem is a field of a feed that failed validation against the GBFS
+// / schema of the feed.
+type Problem struct {
+	// Id of the station or vehicle, or "" for the envelope.
+	Id     string
+	Field  string
+	Reason string
+}
+
+func (p Problem) String() string {
+	if p.Id == "" {
+		return fmt.Sprintf("%s: %s", p.Field, p.Reason)
+	}
+	return fmt.Sprintf("%s %s: %s", p.Id, p.Field, p.Reason)
+}
+
+// / ValidationError is returned by a strict Client for payloads with
+// / problems, rather than decoding missing fields as zeros.
+type ValidationError struct {
+	URL      string
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	msg := fmt.Sprintf("validating %s: %d problems, first %s", e.URL, len(e.Problems), e.Problems[0])
+	if len(e.Problems) == 1 {
+		msg = fmt.Sprintf("validating %s: %s", e.URL, e.Problems[0])
+	}
+	return msg
+}
+
+// / field kinds
+const (
+	kindString = iota
+	kindNumber
+	// a bool, or 0 or 1 before v3.0
+	kindFlag
+	// POSIX seconds or an RFC3339 string
+	kindTimestamp
+	// a string, or a list of localized strings from v3.0 on
+	kindText
+)
+
+type fieldRule struct {
+	name     string
+	kind     int
+	required bool
+	min, max float64
+}
+
+// / feedSchema lists the fields checked in every element of the list named
+// / one of lists in data. The first rule names the element's id.
+type feedSchema struct {
+	lists []string
+	rules []fieldRule
+}
+
+func between(name string, kind int, required bool, min, max float64) fieldRule {
+	return fieldRule{name: name, kind: kind, required: required, min: min, max: max}
+}
+
+func atLeast(name string, kind int, required bool, min float64) fieldRule {
+	return fieldRule{name: name, kind: kind, required: required, min: min, max: math.Inf(1)}
+}
+
+func field(name string, kind int, required bool) fieldRule {
+	return fieldRule{name: name, kind: kind, required: required}
+}
+
+var feedSchemas = map[string]feedSchema{
+	"station_information": {
+		lists: []string{"stations"},
+		rules: []fieldRule{
+			field("station_id", kindString, true),
+			field("name", kindText, true),
+			between("lat", kindNumber, true, -90, 90),
+			between("lon", kindNumber, true, -180, 180),
+			atLeast("capacity", kindNumber, false, 0),
+		},
+	},
+	"station_status": {
+		lists: []string{"stations"},
+		rules: []fieldRule{
+			field("station_id", kindString, true),
+			atLeast("num_bikes_available", kindNumber, true, 0),
+			atLeast("num_bikes_disabled", kindNumber, false, 0),
+			atLeast("num_docks_available", kindNumber, false, 0),
+			atLeast("num_docks_disabled", kindNumber, false, 0),
+			atLeast("num_ebikes_available", kindNumber, false, 0),
+			field("is_installed", kindFlag, true),
+			field("is_renting", kindFlag, true),
+			field("is_returning", kindFlag, true),
+			field("last_reported", kindTimestamp, true),
+		},
+	},
+	"free_bike_status": {
+		lists: []string{"bikes", "vehicles"},
+		rules: []fieldRule{
+			// replaced by vehicle_id for the vehicles of v3.0
+			field("bike_id", kindString, true),
+			field("is_reserved", kindFlag, true),
+			field("is_disabled", kindFlag, true),
+			between("lat", kindNumber, false, -90, 90),
+			between("lon", kindNumber, false, -180, 180),
+			between("current_fuel_percent", kindNumber, false, 0, 1),
+			atLeast("current_range_meters", kindNumber, false, 0),
+		},
+	},
+	"vehicle_types": {
+		lists: []string{"vehicle_types"},
+		rules: []fieldRule{
+			field("vehicle_type_id", kindString, true),
+			field("form_factor", kindString, true),
+			field("propulsion_type", kindString, true),
+			atLeast("max_range_meters", kindNumber, false, 0),
+		},
+	},
+}
+
+// / Validate the body of a feed against the required fields, types and
+// / value ranges of its schema, returning its problems. Only the envelope is
+// / checked for feeds without a known schema, and bodies that aren't JSON
+// / objects are left for decoding to report.
+func Validate(feed string, body []byte) []Problem {
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil
+	}
+	var problems []Problem
+	problems = checkField(problems, "", envelope, field("last_updated", kindTimestamp, true))
+	problems = checkField(problems, "", envelope, atLeast("ttl", kindNumber, true, 0))
+	if _, ok := envelope["data"]; !ok {
+		return append(problems, Problem{Field: "data", Reason: ReasonMissingField})
+	}
+
+	schema, ok := feedSchemas[feed]
+	if !ok {
+		return problems
+	}
+	var data map[string]json.RawMessage
+	if err := json.Unmarshal(envelope["data"], &data); err != nil {
+		return append(problems, Problem{Field: "data", Reason: ReasonInvalidType})
+	}
+	found := false
+	for _, list := range schema.lists {
+		raw, ok := data[list]
+		if !ok {
+			continue
+		}
+		found = true
+		var elements []map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &elements); err != nil {
+			problems = append(problems, Problem{Field: "data." + list, Reason: ReasonInvalidType})
+			continue
+		}
+		rules := schema.rules
+		if list == "vehicles" {
+			rules = append([]fieldRule{field("vehicle_id", kindString, true)}, rules[1:]...)
+		}
+		for _, element := range elements {
+			var id string
+			json.Unmarshal(element[rules[0].name], &id)
+			for _, rule := range rules {
+				problems = checkField(problems, id, element, rule)
+			}
+		}
+	}
+	if !found {
+		problems = append(problems, Problem{Field: "data." + strings.Join(schema.lists, "|"), Reason: ReasonMissingField})
+	}
+	return problems
+}
+
+func checkField(problems []Problem, id string, object map[string]json.RawMessage, rule fieldRule) []Problem {
+	raw, ok := object[rule.name]
+	if !ok || string(raw) == "null" {
+		if rule.required {
+			problems = append(problems, Problem{Id: id, Field: rule.name, Reason: ReasonMissingField})
+		}
+		return problems
+	}
+
+	var value any
+	json.Unmarshal(raw, &value)
+	valid := true
+	number, isNumber := value.(float64)
+	switch rule.kind {
+	case kindString:
+		_, valid = value.(string)
+	case kindText:
+		_, isString := value.(string)
+		_, isList := value.([]any)
+		valid = isString || isList
+	case kindNumber:
+		valid = isNumber
+	case kindFlag:
+		_, isBool := value.(bool)
+		valid = isBool || isNumber && (number == 0 || number == 1)
+	case kindTimestamp:
+		_, isString := value.(string)
+		valid = isString || isNumber
+	}
+	if !valid {
+		return append(problems, Problem{Id: id, Field: rule.name, Reason: ReasonInvalidType})
+	}
+	if rule.kind == kindNumber && (number < rule.min || number > rule.max) {
+		problems = append(problems, Problem{Id: id, Field: rule.name, Reason: ReasonOutOfRange})
+	}
+	return problems
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"math"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// / POIConfig is a named location, such as home or the office, reported on
+// / by the availability of the stations and free bikes within Radius meters.
+type POIConfig struct {
+	Name   string  `yaml:"name"`
+	Lat    float64 `yaml:"lat"`
+	Lon    float64 `yaml:"lon"`
+	Radius float64 `yaml:"radius"`
+}
+
+// / POIMetrics reports the availability around each of POIs.
+type POIMetrics struct {
+	POIs []POIConfig
+
+	poi_bikes_available             prometheus.GaugeVec
+	poi_ebikes_available            prometheus.GaugeVec
+	poi_docks_available             prometheus.GaugeVec
+	poi_free_bikes_available        prometheus.GaugeVec
+	poi_nearest_station_meters      prometheus.GaugeVec
+	poi_nearest_bike_station_meters prometheus.GaugeVec
+}
+
+func NewPOIMetrics(reg prometheus.Registerer) *POIMetrics {
+	m := &POIMetrics{
+		poi_bikes_available: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "poi_bikes_available",
+			Help: "Number of bikes available at the stations within the radius of the point of interest",
+		},
+			[]string{"poi"},
+		),
+		poi_ebikes_available: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "poi_ebikes_available",
+			Help: "Number of ebikes available at the stations within the radius of the point of interest",
+		},
+			[]string{"poi"},
+		),
+		poi_docks_available: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "poi_docks_available",
+			Help: "Number of docks available at the stations within the radius of the point of interest",
+		},
+			[]string{"poi"},
+		),
+		poi_free_bikes_available: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "poi_free_bikes_available",
+			Help: "Number of free bikes within the radius of the point of interest that are neither disabled nor reserved",
+		},
+			[]string{"poi"},
+		),
+		poi_nearest_station_meters: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "poi_nearest_station_meters",
+			Help: "Distance from the point of interest to the nearest station",
+		},
+			[]string{"poi"},
+		),
+		poi_nearest_bike_station_meters: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "poi_nearest_bike_station_meters",
+			Help: "Distance from the point of interest to the nearest station with a bike available",
+		},
+			[]string{"poi"},
+		),
+	}
+	reg.MustRegister(m.poi_bikes_available)
+	reg.MustRegister(m.poi_ebikes_available)
+	reg.MustRegister(m.poi_docks_available)
+	reg.MustRegister(m.poi_free_bikes_available)
+	reg.MustRegister(m.poi_nearest_station_meters)
+	reg.MustRegister(m.poi_nearest_bike_station_meters)
+
+	return m
+}
+
+// / Check pois, defaulting their radius to 500 meters.
+func validatePOIs(pois []POIConfig) error {
+	for i := range pois {
+		if pois[i].Name == "" {
+			return errors.New("point of interest without a name")
+		}
+		if pois[i].Radius == 0 {
+			pois[i].Radius = 500
+		}
+	}
+	return nil
+}
+
+func (m *POIMetrics) Observe(snapshot *Snapshot) {
+	for _, poi := range m.POIs {
+		var bikes, ebikes, docks, free int
+		nearest, nearestBike := math.Inf(1), math.Inf(1)
+		for _, station := range snapshot.Stations {
+			// stations only known from station_status have no location
+			if station.Lat == 0 && station.Lon == 0 {
+				continue
+			}
+			d := distance(poi.Lat, poi.Lon, station.Lat, station.Lon)
+			nearest = min(nearest, d)
+			if station.Status == nil {
+				continue
+			}
+			if station.Status.BikesAvailable > 0 {
+				nearestBike = min(nearestBike, d)
+			}
+			if d <= poi.Radius {
+				bikes += station.Status.BikesAvailable
+				ebikes += station.Status.EBikesAvailable
+				docks += station.Status.DocksAvailable
+			}
+		}
+		for _, bike := range snapshot.Bikes {
+			if bike.IsDisabled == 0 && bike.IsReserved == 0 && distance(poi.Lat, poi.Lon, bike.Lat, bike.Lon) <= poi.Radius {
+				free++
+			}
+		}
+
+		labels := prometheus.Labels{"poi": poi.Name}
+		m.poi_bikes_available.With(labels).Set(float64(bikes))
+		m.poi_ebikes_available.With(labels).Set(float64(ebikes))
+		m.poi_docks_available.With(labels).Set(float64(docks))
+		m.poi_free_bikes_available.With(labels).Set(float64(free))
+		// without any candidate station there is no distance to report
+		if math.IsInf(nearest, 1) {
+			m.poi_nearest_station_meters.Delete(labels)
+		} else {
+			m.poi_nearest_station_meters.With(labels).Set(nearest)
+		}
+		if math.IsInf(nearestBike, 1) {
+			m.poi_nearest_bike_station_meters.Delete(labels)
+		} else {
+			m.poi_nearest_bike_station_meters.With(labels).Set(nearestBike)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/patrickod/baywheels-exporter/pkg/gbfs"
+)
+
+// / FeedProxy serves the raw body of every feed the exporter last fetched
+// / under /gbfs/<feed>.json, so apps and scripts next to it share its
+// / fetches rather than each polling the operator. Responses are cacheable
+// / for what is left of the feed's ttl, counted from its last_updated, and
+// / the auto-discovery file is rewritten to point at the proxied feeds, or
+// / made up from them when the exporter was given a base URL.
+type FeedProxy struct {
+	// Language is the language the feeds are listed under in a made up
+	// auto-discovery file before v3.0.
+	Language string
+
+	mu    sync.RWMutex
+	feeds map[string]*proxiedFeed
+}
+
+type proxiedFeed struct {
+	body    []byte
+	etag    string
+	version string
+	fetched time.Time
+	expires time.Time
+}
+
+func NewFeedProxy() *FeedProxy {
+	return &FeedProxy{Language: "en", feeds: make(map[string]*proxiedFeed)}
+}
+
+// / Keep a copy of body as the feed's latest payload. It is a gbfs.Client
+// / OnFetch hook.
+func (p *FeedProxy) Record(feed string, body []byte) {
+	now := time.Now()
+	f := &proxiedFeed{body: bytes.Clone(body), etag: etag(body), fetched: now, expires: now}
+	var header gbfs.Response[json.RawMessage]
+	if json.Unmarshal(body, &header) == nil {
+		updated := header.LastUpdated.Time
+		if updated.IsZero() || updated.After(now) {
+			updated = now
+		}
+		f.expires = updated.Add(time.Duration(header.TTL) * time.Second)
+		f.version = header.Version
+	}
+
+	p.mu.Lock()
+	p.feeds[feed] = f
+	p.mu.Unlock()
+}
+
+func etag(body []byte) string {
+	h := fnv.New64a()
+	h.Write(body)
+	return fmt.Sprintf(`"%x"`, h.Sum64())
+}
+
+func (p *FeedProxy) Register(mux *http.ServeMux, metrics *HTTPMetrics) {
+	mux.Handle("GET /gbfs/{feed}", metrics.Instrument("gbfs", http.HandlerFunc(p.serve)))
+}
+
+func (p *FeedProxy) serve(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSuffix(r.PathValue("feed"), ".json")
+	p.mu.RLock()
+	f := p.feeds[name]
+	p.mu.RUnlock()
+	if f == nil && name == "gbfs" {
+		f = p.discovery()
+	}
+	if f == nil {
+		writeError(w, http.StatusNotFound, "feed not fetched")
+		return
+	}
+
+	body := f.body
+	if name == "gbfs" {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		body = p.rewrite(body, scheme+"://"+r.Host+"/gbfs/")
+	}
+	now := time.Now()
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "max-age="+strconv.Itoa(int(max(0, f.expires.Sub(now).Seconds()))))
+	w.Header().Set("Age", strconv.Itoa(int(now.Sub(f.fetched).Seconds())))
+	w.Header().Set("ETag", f.etag)
+	http.ServeContent(w, r, "", f.fetched, bytes.NewReader(body))
+}
+
+// / Make up an auto-discovery file listing the proxied feeds, for exporters
+// / given the base URL of a system's feeds rather than its gbfs.json. It
+// / reports their GBFS version and is cacheable for as long as all of them
+// / are. The urls are filled in by rewrite. Returns nil before any feed has
+// / been fetched.
+func (p *FeedProxy) discovery() *proxiedFeed {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if len(p.feeds) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(p.feeds))
+	for name := range p.feeds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var f proxiedFeed
+	feeds := make([]gbfs.Feed, len(names))
+	for i, name := range names {
+		feed := p.feeds[name]
+		feeds[i] = gbfs.Feed{Name: name, URL: name + ".json"}
+		if f.fetched.IsZero() || feed.fetched.After(f.fetched) {
+			f.fetched = feed.fetched
+		}
+		if f.expires.IsZero() || feed.expires.Before(f.expires) {
+			f.expires = feed.expires
+		}
+		f.version = cmp.Or(f.version, feed.version)
+	}
+	var data any = map[string]gbfs.DiscoveryLanguage{p.Language: {Feeds: feeds}}
+	if strings.HasPrefix(f.version, "3.") {
+		data = gbfs.Discovery{Feeds: feeds}
+	}
+	body, err := json.Marshal(gbfs.Response[any]{
+		LastUpdated: gbfs.Timestamp{Time: f.fetched},
+		TTL:         int(max(0, f.expires.Sub(f.fetched).Seconds())),
+		Version:     f.version,
+		Data:        data,
+	})
+	if err != nil {
+		return nil
+	}
+	f.body, f.etag = body, etag(body)
+	return &f
+}
+
+// / Point the urls of the feeds listed in an auto-discovery file at the
+// / proxy, where it has fetched them. Feeds it hasn't keep their upstream
+// / urls. The file is returned as is if it can't be parsed.
+func (p *FeedProxy) rewrite(body []byte, base string) []byte {
+	var v any
+	if json.Unmarshal(body, &v) != nil {
+		return body
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			name, _ := v["name"].(string)
+			if _, ok := v["url"].(string); ok && p.feeds[name] != nil {
+				v["url"] = base + name + ".json"
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(v)
+	rewritten, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+	return rewritten
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+)
+
+type PushgatewayConfig struct {
+	URL      string
+	Job      string
+	Grouping map[string]string
+	Username string
+	Password string
+}
+
+// / Pushgateway is a Sink replacing the exporter's metric group on a
+// / Prometheus Pushgateway after every cycle.
+type Pushgateway struct {
+	config PushgatewayConfig
+}
+
+func NewPushgateway(config PushgatewayConfig) *Pushgateway {
+	return &Pushgateway{config: config}
+}
+
+func (p *Pushgateway) Name() string {
+	return "pushgateway"
+}
+
+func (p *Pushgateway) Send(ctx context.Context, cycle *Cycle) error {
+	pusher := push.New(p.config.URL, p.config.Job).
+		Gatherer(prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+			return cycle.Families, nil
+		}))
+	for name, value := range p.config.Grouping {
+		pusher = pusher.Grouping(name, value)
+	}
+	if p.config.Username != "" {
+		pusher = pusher.BasicAuth(p.config.Username, p.config.Password)
+	}
+
+	// PUT rather than POST so series for stations and bikes that have
+	// disappeared since the last cycle are removed from the group.
+	return pusher.PushContext(ctx)
+}
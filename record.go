@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// / recordTimeFormat names the recorded files after the time they were
+// / fetched, sorting chronologically.
+const recordTimeFormat = "20060102T150405.000Z"
+
+// / Recorder writes the raw body of every fetched feed to
+// / <dir>/<feed>/<time>.json, or .json.gz when compressing, building an
+// / archive of the feeds as published for later analysis or replay.
+// /
+// / So the archive doesn't grow unbounded, files older than RawRetention are
+// / downsampled to the first of every hour, which replays as a coarser
+// / recording, and files older than Retention are deleted. Either is
+// / disabled when zero.
+type Recorder struct {
+	RawRetention time.Duration
+	Retention    time.Duration
+
+	dir      string
+	compress bool
+}
+
+func NewRecorder(dir string, compress bool) *Recorder {
+	return &Recorder{dir: dir, compress: compress}
+}
+
+// / Record body as fetched now. It is the gbfs.Client OnFetch hook, so errors
+// / are logged rather than failing the fetch.
+func (r *Recorder) Record(feed string, body []byte) {
+	if err := r.write(feed, time.Now(), body); err != nil {
+		log.Printf("Error recording %s %s\n", feed, err)
+	}
+}
+
+// / compressed and gzipWriters pool the state of compressing recordings, as
+// / every feed is recorded every cycle.
+var (
+	compressed  = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+	gzipWriters = sync.Pool{New: func() any { return gzip.NewWriter(nil) }}
+)
+
+func (r *Recorder) write(feed string, t time.Time, body []byte) error {
+	dir := filepath.Join(r.dir, feed)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	name := t.UTC().Format(recordTimeFormat) + ".json"
+	if r.compress {
+		buf := compressed.Get().(*bytes.Buffer)
+		defer compressed.Put(buf)
+		buf.Reset()
+		zw := gzipWriters.Get().(*gzip.Writer)
+		defer gzipWriters.Put(zw)
+		zw.Reset(buf)
+		if _, err := zw.Write(body); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		body = buf.Bytes()
+		name += ".gz"
+	}
+	return os.WriteFile(filepath.Join(dir, name), body, 0o644)
+}
+
+// / Compact the archive every hour until ctx is cancelled.
+func (r *Recorder) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		if err := r.compact(time.Now()); err != nil {
+			log.Printf("Error compacting recorded feeds %s\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// / Apply the retention policies as of now.
+func (r *Recorder) compact(now time.Time) error {
+	feeds, err := os.ReadDir(r.dir)
+	if err != nil {
+		return err
+	}
+	for _, feed := range feeds {
+		if !feed.IsDir() {
+			continue
+		}
+		dir := filepath.Join(r.dir, feed.Name())
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		// files are named after their time, so they are listed in order
+		lastHour := time.Time{}
+		for _, file := range files {
+			t, err := recordedTime(file.Name())
+			if err != nil {
+				continue
+			}
+			age := now.Sub(t)
+			hour := t.Truncate(time.Hour)
+			remove := r.Retention > 0 && age > r.Retention ||
+				r.RawRetention > 0 && age > r.RawRetention && hour.Equal(lastHour)
+			lastHour = hour
+			if !remove {
+				continue
+			}
+			if err := os.Remove(filepath.Join(dir, file.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+type RemoteWriteConfig struct {
+	URL         string
+	Username    string
+	Password    string
+	BearerToken string
+	// Labels are added to every pushed series; since nothing scrapes us in
+	// push mode there is no job/instance labelling otherwise.
+	Labels    map[string]string
+	QueueSize int
+	Timeout   time.Duration
+}
+
+// / RemoteWrite is a Sink pushing every cycle to a Prometheus remote_write
+// / endpoint. Cycles are encoded immediately and queued, so a slow or
+// / unreachable endpoint never blocks sampling; when the queue is full the
+// / oldest pending request is dropped.
+type RemoteWrite struct {
+	config RemoteWriteConfig
+	client *http.Client
+	queue  chan remoteWriteRequest
+
+	samples_total  prometheus.Counter
+	failures_total prometheus.Counter
+	dropped_total  prometheus.Counter
+	queue_length   prometheus.GaugeFunc
+}
+
+type remoteWriteRequest struct {
+	body    []byte
+	samples int
+}
+
+// / errNonRetryable marks remote write responses that will never succeed
+// / when retried, such as a 400 for malformed samples.
+var errNonRetryable = errors.New("non-retryable remote write error")
+
+func NewRemoteWrite(config RemoteWriteConfig, reg prometheus.Registerer) *RemoteWrite {
+	if config.QueueSize < 1 {
+		config.QueueSize = 1
+	}
+	w := &RemoteWrite{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+		queue:  make(chan remoteWriteRequest, config.QueueSize),
+		samples_total: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "baywheels_exporter_remote_write_samples_total",
+			Help: "Number of samples successfully written to the remote_write endpoint",
+		}),
+		failures_total: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "baywheels_exporter_remote_write_failed_requests_total",
+			Help: "Number of failed remote_write requests, including retries",
+		}),
+		dropped_total: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "baywheels_exporter_remote_write_dropped_requests_total",
+			Help: "Number of remote_write requests dropped because the queue was full or the endpoint rejected them",
+		}),
+	}
+	w.queue_length = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "baywheels_exporter_remote_write_queue_length",
+		Help: "Number of remote_write requests waiting to be sent",
+	}, func() float64 { return float64(len(w.queue)) })
+
+	reg.MustRegister(w.samples_total)
+	reg.MustRegister(w.failures_total)
+	reg.MustRegister(w.dropped_total)
+	reg.MustRegister(w.queue_length)
+
+	return w
+}
+
+func (w *RemoteWrite) Name() string {
+	return "remote_write"
+}
+
+func (w *RemoteWrite) Send(ctx context.Context, cycle *Cycle) error {
+	samples := flattenFamilies(cycle.Families, cycle.Time)
+	req := remoteWriteRequest{
+		body:    snappy.Encode(nil, encodeWriteRequest(samples, w.config.Labels)),
+		samples: len(samples),
+	}
+
+	for {
+		select {
+		case w.queue <- req:
+			return nil
+		default:
+		}
+		// the queue is full; make room by discarding the oldest request
+		select {
+		case <-w.queue:
+			w.dropped_total.Inc()
+			log.Println("Remote write queue full, dropping oldest request")
+		default:
+		}
+	}
+}
+
+// / Drain the queue until ctx is cancelled, retrying each request with
+// / exponential backoff until it is accepted or rejected outright.
+func (w *RemoteWrite) Run(ctx context.Context) {
+	for {
+		var req remoteWriteRequest
+		select {
+		case <-ctx.Done():
+			return
+		case req = <-w.queue:
+		}
+
+		backoff := time.Second
+		for {
+			err := w.post(ctx, req.body)
+			if err == nil {
+				w.samples_total.Add(float64(req.samples))
+				break
+			}
+			w.failures_total.Inc()
+			if errors.Is(err, errNonRetryable) || ctx.Err() != nil {
+				w.dropped_total.Inc()
+				log.Printf("Error writing to remote_write endpoint %s\n", err)
+				break
+			}
+			log.Printf("Error writing to remote_write endpoint, retrying in %s: %s\n", backoff, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, 30*time.Second)
+		}
+	}
+}
+
+func (w *RemoteWrite) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", "baywheels-exporter")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if w.config.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+w.config.BearerToken)
+	} else if w.config.Username != "" {
+		req.SetBasicAuth(w.config.Username, w.config.Password)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("server returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	// 5xx and rate limiting are worth retrying, anything else is not
+	if resp.StatusCode/100 != 5 && resp.StatusCode != http.StatusTooManyRequests {
+		err = fmt.Errorf("%w: %w", errNonRetryable, err)
+	}
+	return err
+}
+
+// / Encode samples as a prometheus.WriteRequest protobuf message, with each
+// / sample forming its own TimeSeries. extra labels are added to every series
+// / unless the sample already carries a label of the same name.
+func encodeWriteRequest(samples []Sample, extra map[string]string) []byte {
+	var buf, ts []byte
+	for _, sample := range samples {
+		labels := seriesLabels(sample, extra)
+
+		ts = ts[:0]
+		for _, l := range labels {
+			var label []byte
+			label = protowire.AppendTag(label, 1, protowire.BytesType)
+			label = protowire.AppendString(label, l.Name)
+			label = protowire.AppendTag(label, 2, protowire.BytesType)
+			label = protowire.AppendString(label, l.Value)
+
+			ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, label)
+		}
+
+		var point []byte
+		point = protowire.AppendTag(point, 1, protowire.Fixed64Type)
+		point = protowire.AppendFixed64(point, math.Float64bits(sample.Value))
+		point = protowire.AppendTag(point, 2, protowire.VarintType)
+		point = protowire.AppendVarint(point, uint64(sample.Timestamp.UnixMilli()))
+		ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+		ts = protowire.AppendBytes(ts, point)
+
+		buf = protowire.AppendTag(buf, 1, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, ts)
+	}
+	return buf
+}
+
+// / Return the complete, sorted label set of a sample including __name__.
+// / Empty label values are dropped, as Prometheus treats them as unset.
+func seriesLabels(sample Sample, extra map[string]string) []Label {
+	labels := make([]Label, 0, len(sample.Labels)+len(extra)+1)
+	labels = append(labels, Label{Name: "__name__", Value: sample.Name})
+	for _, l := range sample.Labels {
+		if l.Value != "" {
+			labels = append(labels, l)
+		}
+	}
+	for name, value := range extra {
+		if !hasLabel(sample.Labels, name) {
+			labels = append(labels, Label{Name: name, Value: value})
+		}
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+	return labels
+}
+
+func hasLabel(labels []Label, name string) bool {
+	for _, l := range labels {
+		if l.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type recordedFile struct {
+	time time.Time
+	path string
+}
+
+// / Replay serves the feeds recorded by a Recorder in place of the live API,
+// / as an http.RoundTripper for the gbfs.Client. Its clock starts at the
+// / first recorded file and advances Speed times as fast as the wall clock,
+// / each feed returning the latest file recorded at or before it.
+type Replay struct {
+	Speed float64
+	// Loop starts over from the first recorded file after the last, rather
+	// than holding the last files.
+	Loop bool
+
+	feeds      map[string][]recordedFile
+	start, end time.Time
+
+	mu    sync.Mutex
+	began time.Time
+	// at, when set by Seek, stops the clock at a fixed time
+	at time.Time
+}
+
+// / Index the files recorded under dir.
+func LoadReplay(dir string) (*Replay, error) {
+	r := &Replay{Speed: 1, feeds: make(map[string][]recordedFile)}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		feed := entry.Name()
+		files, err := os.ReadDir(filepath.Join(dir, feed))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			t, err := recordedTime(file.Name())
+			if err != nil {
+				continue
+			}
+			r.feeds[feed] = append(r.feeds[feed], recordedFile{time: t, path: filepath.Join(dir, feed, file.Name())})
+			if r.start.IsZero() || t.Before(r.start) {
+				r.start = t
+			}
+			if t.After(r.end) {
+				r.end = t
+			}
+		}
+		sort.Slice(r.feeds[feed], func(i, j int) bool { return r.feeds[feed][i].time.Before(r.feeds[feed][j].time) })
+	}
+	if len(r.feeds) == 0 {
+		return nil, fmt.Errorf("no recorded feeds in %s", dir)
+	}
+	return r, nil
+}
+
+// / Parse the time a recorded file was fetched from its name.
+func recordedTime(name string) (time.Time, error) {
+	name = strings.TrimSuffix(name, ".gz")
+	if !strings.HasSuffix(name, ".json") {
+		return time.Time{}, errors.New("not a recorded feed")
+	}
+	return time.Parse(recordTimeFormat, strings.TrimSuffix(name, ".json"))
+}
+
+// / Return the current time of the replay, starting the clock on first use.
+func (r *Replay) Now() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.at.IsZero() {
+		return r.at
+	}
+	if r.began.IsZero() {
+		r.began = time.Now()
+	}
+	elapsed := time.Duration(float64(time.Since(r.began)) * r.Speed)
+	if length := r.end.Sub(r.start); elapsed > length {
+		if !r.Loop {
+			return r.end
+		}
+		if length > 0 {
+			elapsed %= length
+		} else {
+			elapsed = 0
+		}
+	}
+	return r.start.Add(elapsed)
+}
+
+// / Stop the clock at t, for stepping through the recording.
+func (r *Replay) Seek(t time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.at = t
+}
+
+// / Return the times feed was recorded at, in order.
+func (r *Replay) Times(feed string) []time.Time {
+	times := make([]time.Time, len(r.feeds[feed]))
+	for i, file := range r.feeds[feed] {
+		times[i] = file.time
+	}
+	return times
+}
+
+// / Return the body of the latest file of feed recorded at or before t, or
+// / the first one if the feed wasn't recorded until after t.
+func (r *Replay) body(feed string, t time.Time) ([]byte, error) {
+	files, ok := r.feeds[feed]
+	if !ok {
+		return nil, nil
+	}
+	i := sort.Search(len(files), func(i int) bool { return files[i].time.After(t) })
+	file := files[max(i-1, 0)]
+
+	data, err := os.ReadFile(file.path)
+	if err != nil || !strings.HasSuffix(file.path, ".gz") {
+		return data, err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(zr)
+}
+
+func (r *Replay) RoundTrip(req *http.Request) (*http.Response, error) {
+	feed := strings.TrimSuffix(path.Base(req.URL.Path), ".json")
+	body, err := r.body(feed, r.Now())
+	if err != nil {
+		return nil, err
+	}
+	resp := &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Request:    req,
+	}
+	if body == nil {
+		resp.Status, resp.StatusCode = "404 Not Found", http.StatusNotFound
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	return resp, nil
+}
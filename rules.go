@@ -0,0 +1,120 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"go.yaml.in/yaml/v2"
+)
+
+type ruleGroups struct {
+	Groups []ruleGroup `yaml:"groups"`
+}
+
+type ruleGroup struct {
+	Name  string `yaml:"name"`
+	Rules []rule `yaml:"rules"`
+}
+
+type rule struct {
+	Alert       string            `yaml:"alert"`
+	Expr        string            `yaml:"expr"`
+	For         model.Duration    `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// / Run `rules`, writing recommended Prometheus alerting rules for the
+// / exporter to stdout. As with grafana-dashboard the queries follow the
+// / exporter's -metrics.namespace and any -label selectors.
+func runRules(args []string) error {
+	fs := flag.NewFlagSet("rules", flag.ExitOnError)
+	namespace := fs.String("metrics.namespace", "", "Namespace the exporter prepends to station and bike metric names")
+	labels := labelsFlag{}
+	fs.Var(labels, "label", "Label every query is restricted to as name=value; may be repeated")
+	staleAfter := fs.Duration("station.stale-after", time.Hour, "Alert on installed stations that haven't reported for this long")
+	emptyFor := fs.Duration("station.empty-for", 30*time.Minute, "Alert on renting stations that have had no bikes for this long")
+	feedDownAfter := fs.Duration("feed.down-after", 10*time.Minute, "Alert on feeds that haven't been fetched successfully for this long")
+	severity := fs.String("severity", "warning", "Severity label of the generated alerts")
+	fs.Parse(args)
+
+	selector := promSelector(labels)
+	metric := func(name string) string {
+		return promMetric(*namespace, name, selector)
+	}
+	alertLabels := map[string]string{"severity": *severity}
+
+	groups := ruleGroups{Groups: []ruleGroup{
+		{
+			Name: "baywheels-exporter",
+			Rules: []rule{
+				{
+					Alert:  "BaywheelsExporterDown",
+					Expr:   fmt.Sprintf("up%s == 0", selector),
+					For:    model.Duration(5 * time.Minute),
+					Labels: alertLabels,
+					Annotations: map[string]string{
+						"summary":     "Bay Wheels exporter {{ $labels.instance }} is down",
+						"description": "Prometheus has failed to scrape the exporter for 5 minutes.",
+					},
+				},
+				{
+					Alert:  "BaywheelsFeedDown",
+					Expr:   fmt.Sprintf("time() - %s > %d", promMetric("", "baywheels_exporter_feed_last_success_timestamp_seconds", selector), int(feedDownAfter.Seconds())),
+					Labels: alertLabels,
+					Annotations: map[string]string{
+						"summary":     "GBFS feed {{ $labels.feed }} is down",
+						"description": fmt.Sprintf("The {{ $labels.feed }} feed hasn't been fetched successfully for more than %s.", model.Duration(*feedDownAfter)),
+					},
+				},
+				{
+					Alert: "BaywheelsFeedErrors",
+					Expr: fmt.Sprintf("rate(%s[15m]) / rate(%s[15m]) > 0.5",
+						promMetric("", "baywheels_exporter_feed_errors_total", selector),
+						promMetric("", "baywheels_exporter_feed_fetches_total", selector)),
+					For:    model.Duration(15 * time.Minute),
+					Labels: alertLabels,
+					Annotations: map[string]string{
+						"summary":     "GBFS feed {{ $labels.feed }} is failing",
+						"description": "More than half of the fetches of the {{ $labels.feed }} feed have failed over the last 15 minutes.",
+					},
+				},
+			},
+		},
+		{
+			Name: "baywheels-stations",
+			Rules: []rule{
+				{
+					Alert: "BaywheelsStationStale",
+					Expr: fmt.Sprintf("time() - %s > %d and on(station_id) %s == 1",
+						metric("station_last_report"), int(staleAfter.Seconds()), metric("station_is_installed")),
+					Labels: alertLabels,
+					Annotations: map[string]string{
+						"summary":     "Station {{ $labels.name }} is stale",
+						"description": fmt.Sprintf("Installed station {{ $labels.station_id }} hasn't reported for more than %s.", model.Duration(*staleAfter)),
+					},
+				},
+				{
+					Alert: "BaywheelsStationEmpty",
+					Expr: fmt.Sprintf("%s == 0 and on(station_id) %s == 1",
+						metric("station_bikes_available"), metric("station_is_renting")),
+					For:    model.Duration(*emptyFor),
+					Labels: alertLabels,
+					Annotations: map[string]string{
+						"summary":     "Station {{ $labels.name }} is empty",
+						"description": fmt.Sprintf("Station {{ $labels.station_id }} has had no bikes available for %s.", model.Duration(*emptyFor)),
+					},
+				},
+			},
+		},
+	}}
+
+	enc := yaml.NewEncoder(os.Stdout)
+	if err := enc.Encode(groups); err != nil {
+		return err
+	}
+	return enc.Close()
+}
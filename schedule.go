@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// / ScheduleWindow is a daily window of time on some days of the week in
+// / which the exporter samples at Interval.
+type ScheduleWindow struct {
+	Days [7]bool
+	// Start and End are offsets from midnight; windows ending at or before
+	// they start run past midnight into the next day.
+	Start, End time.Duration
+	Interval   time.Duration
+}
+
+// / Schedule varies the sampling interval by time of day, e.g. sampling every
+// / 15 seconds during commute hours on weekdays and every 2 minutes
+// / otherwise, so data is fine-grained when it matters without multiplying
+// / requests around the clock. Outside its windows the interval is Default.
+// / The first window containing a time wins.
+type Schedule struct {
+	Windows  []ScheduleWindow
+	Default  time.Duration
+	Location *time.Location
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+func (s *Schedule) String() string {
+	if s == nil {
+		return ""
+	}
+	windows := make([]string, len(s.Windows))
+	for i, w := range s.Windows {
+		windows[i] = fmt.Sprintf("%s-%s %s", clockTime(w.Start), clockTime(w.End), w.Interval)
+	}
+	return strings.Join(windows, ",")
+}
+
+// / Add a window given as "[days] HH:MM-HH:MM interval", e.g.
+// / "mon-fri 07:00-10:00 15s", or "sat,sun 10:00-18:00 30s". Windows with no
+// / days apply every day.
+func (s *Schedule) Set(v string) error {
+	fields := strings.Fields(v)
+	if len(fields) < 2 {
+		return fmt.Errorf("expected [days] HH:MM-HH:MM interval, got %q", v)
+	}
+	window, err := parseWindow(strings.Join(fields[:len(fields)-1], " "))
+	if err != nil {
+		return err
+	}
+	if window.Interval, err = time.ParseDuration(fields[len(fields)-1]); err != nil {
+		return err
+	}
+	if window.Interval <= 0 {
+		return fmt.Errorf("interval must be positive, got %s", window.Interval)
+	}
+	s.Windows = append(s.Windows, window)
+	return nil
+}
+
+// / Parse a window given as "[days] HH:MM-HH:MM", applying every day if no
+// / days are given.
+func parseWindow(v string) (ScheduleWindow, error) {
+	fields := strings.Fields(v)
+	var window ScheduleWindow
+	switch len(fields) {
+	case 1:
+		for i := range window.Days {
+			window.Days[i] = true
+		}
+	case 2:
+		if err := parseDays(fields[0], &window.Days); err != nil {
+			return window, err
+		}
+		fields = fields[1:]
+	default:
+		return window, fmt.Errorf("expected [days] HH:MM-HH:MM, got %q", v)
+	}
+
+	start, end, ok := strings.Cut(fields[0], "-")
+	if !ok {
+		return window, fmt.Errorf("expected HH:MM-HH:MM, got %q", fields[0])
+	}
+	var err error
+	if window.Start, err = parseClockTime(start); err != nil {
+		return window, err
+	}
+	if window.End, err = parseClockTime(end); err != nil {
+		return window, err
+	}
+	return window, nil
+}
+
+// / Parse days like "mon-fri", "sat,sun" or "mon,wed-fri".
+func parseDays(v string, days *[7]bool) error {
+	for _, span := range strings.Split(strings.ToLower(v), ",") {
+		first, last, isRange := strings.Cut(span, "-")
+		if !isRange {
+			last = first
+		}
+		from, ok := weekdays[first]
+		if !ok {
+			return fmt.Errorf("unknown day %q", first)
+		}
+		to, ok := weekdays[last]
+		if !ok {
+			return fmt.Errorf("unknown day %q", last)
+		}
+		for day := from; ; day = (day + 1) % 7 {
+			days[day] = true
+			if day == to {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func parseClockTime(v string) (time.Duration, error) {
+	t, err := time.Parse("15:04", v)
+	if err != nil {
+		// 24:00 ends a window at midnight
+		if v == "24:00" {
+			return 24 * time.Hour, nil
+		}
+		return 0, fmt.Errorf("expected HH:MM, got %q", v)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func clockTime(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+}
+
+// / Return whether the window contains t, in the schedule's location.
+func (w ScheduleWindow) contains(t time.Time) bool {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	offset := t.Sub(midnight)
+	if w.End > w.Start {
+		return w.Days[t.Weekday()] && offset >= w.Start && offset < w.End
+	}
+	// past midnight, the window started the day before
+	yesterday := (t.Weekday() + 6) % 7
+	return w.Days[t.Weekday()] && offset >= w.Start || w.Days[yesterday] && offset < w.End
+}
+
+// / Return the sampling interval at t.
+func (s *Schedule) Interval(t time.Time) time.Duration {
+	if s.Location != nil {
+		t = t.In(s.Location)
+	}
+	for _, w := range s.Windows {
+		if w.contains(t) {
+			return w.Interval
+		}
+	}
+	return s.Default
+}
+
+// / Return the time from t until the interval next changes, searching up to
+// / limit ahead at minute resolution, or limit if it doesn't change before.
+func (s *Schedule) Until(t time.Time, limit time.Duration) time.Duration {
+	current := s.Interval(t)
+	boundary := t.Truncate(time.Minute)
+	for step := time.Minute; step <= limit; step += time.Minute {
+		if s.Interval(boundary.Add(step)) != current {
+			return boundary.Add(step).Sub(t)
+		}
+	}
+	return limit
+}
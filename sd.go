@@ -0,0 +1,31 @@
+package main
+
+import (
+	"net/http"
+)
+
+// / SDTarget is a target group of the Prometheus HTTP service discovery
+// / format, https://prometheus.io/docs/prometheus/latest/http_sd/.
+type SDTarget struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// / Serve the exporter as a Prometheus HTTP service discovery target group
+// / at /sd, labelled with the system it samples for relabeling. The target is
+// / target if set, else the host the request was made to.
+func handleSD(exporter *Exporter, target string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		host := target
+		if host == "" {
+			host = r.Host
+		}
+		labels := map[string]string{
+			"__meta_gbfs_url": exporter.URL,
+		}
+		if version := exporter.client.Version(); version != "" {
+			labels["__meta_gbfs_version"] = version
+		}
+		writeJSON(w, http.StatusOK, []SDTarget{{Targets: []string{host}, Labels: labels}})
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// / secretFlags are the flags holding credentials. Rather than on the command
+// / line, where they show up in the process arguments, each can be given in
+// / a file with -<name>-file, e.g. a mounted Kubernetes secret, as a systemd
+// / credential named <name> or in the environment variable
+// / BAYWHEELS_<NAME>, e.g. BAYWHEELS_MQTT_PASSWORD. The header flags take
+// / one name=value header per line.
+var secretFlags = []string{
+	"remote-write.password",
+	"remote-write.bearer-token",
+	"consul.token",
+	"pushgateway.password",
+	"influxdb.token",
+	"victoriametrics.password",
+	"victoriametrics.bearer-token",
+	"mqtt.password",
+	"archive.secret-key",
+	"otlp.header",
+	"tracing.header",
+}
+
+// / Return the environment variable a secret flag can be given in.
+func secretEnv(name string) string {
+	return "BAYWHEELS_" + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(name))
+}
+
+// / Define the -<name>-file flags of the secret flags defined on fs,
+// / returning the files by flag name.
+func secretFileFlags(fs *flag.FlagSet) map[string]*string {
+	files := make(map[string]*string, len(secretFlags))
+	for _, name := range secretFlags {
+		if fs.Lookup(name) == nil {
+			continue
+		}
+		files[name] = fs.String(name+"-file", "", fmt.Sprintf("File to read -%s from, e.g. a mounted secret; %s if unset", name, secretEnv(name)))
+	}
+	return files
+}
+
+// / Set the secret flags not given on the command line from their files,
+// / systemd credentials or environment variables, in that order.
+func loadSecrets(fs *flag.FlagSet, files map[string]*string) error {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	credentials := os.Getenv("CREDENTIALS_DIRECTORY")
+	for name, file := range files {
+		path := *file
+		if set[name] {
+			if path != "" {
+				return fmt.Errorf("both -%s and -%s-file are set", name, name)
+			}
+			continue
+		}
+		if path == "" && credentials != "" {
+			if _, err := os.Stat(filepath.Join(credentials, name)); err == nil {
+				path = filepath.Join(credentials, name)
+			}
+		}
+
+		value := os.Getenv(secretEnv(name))
+		if path != "" {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if value = strings.TrimSpace(string(data)); value == "" {
+				return errors.New(path + " is empty")
+			}
+		}
+		if value == "" {
+			continue
+		}
+		values := []string{value}
+		if _, ok := fs.Lookup(name).Value.(labelsFlag); ok {
+			values = strings.Split(value, "\n")
+		}
+		for _, value := range values {
+			if value = strings.TrimSpace(value); value == "" {
+				continue
+			}
+			if err := fs.Set(name, value); err != nil {
+				return fmt.Errorf("-%s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
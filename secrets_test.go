@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadSecretHeaders(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		file string
+		env  string
+		want labelsFlag
+	}{
+		{
+			name: "file",
+			file: "Authorization=Bearer abc\n\nX-Scope-OrgID=baywheels\n",
+			want: labelsFlag{"Authorization": "Bearer abc", "X-Scope-OrgID": "baywheels"},
+		},
+		{
+			name: "environment",
+			env:  "Authorization=Bearer abc",
+			want: labelsFlag{"Authorization": "Bearer abc"},
+		},
+		{
+			name: "command line wins",
+			args: []string{"-otlp.header", "Authorization=Bearer flag"},
+			env:  "Authorization=Bearer abc",
+			want: labelsFlag{"Authorization": "Bearer flag"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CREDENTIALS_DIRECTORY", "")
+			t.Setenv("BAYWHEELS_OTLP_HEADER", tt.env)
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			headers := labelsFlag{}
+			fs.Var(headers, "otlp.header", "")
+			files := secretFileFlags(fs)
+
+			args := tt.args
+			if tt.file != "" {
+				path := filepath.Join(t.TempDir(), "headers")
+				if err := os.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				args = append(args, "-otlp.header-file", path)
+			}
+			if err := fs.Parse(args); err != nil {
+				t.Fatal(err)
+			}
+			if err := loadSecrets(fs, files); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(headers, tt.want) {
+				t.Errorf("headers = %v, want %v", headers, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+type Label struct {
+	Name  string
+	Value string
+}
+
+// / Sample is a single point of a flattened time series, as it would be
+// / ingested by Prometheus after scraping the exposition format.
+type Sample struct {
+	Name      string
+	Labels    []Label
+	Value     float64
+	Timestamp time.Time
+}
+
+// / Flatten gathered metric families into individual samples. Histograms and
+// / summaries are expanded into their _bucket/_sum/_count series the same way
+// / the text exposition format does. Samples without an explicit timestamp
+// / are stamped with ts. Labels are sorted by name.
+func flattenFamilies(families []*dto.MetricFamily, ts time.Time) []Sample {
+	var samples []Sample
+	for _, family := range families {
+		name := family.GetName()
+		for _, m := range family.GetMetric() {
+			stamp := ts
+			if m.TimestampMs != nil {
+				stamp = time.UnixMilli(m.GetTimestampMs())
+			}
+			labels := make([]Label, 0, len(m.GetLabel())+1)
+			for _, pair := range m.GetLabel() {
+				labels = append(labels, Label{Name: pair.GetName(), Value: pair.GetValue()})
+			}
+			add := func(suffix string, value float64, extra ...Label) {
+				ls := append(append(make([]Label, 0, len(labels)+len(extra)), labels...), extra...)
+				sort.Slice(ls, func(i, j int) bool { return ls[i].Name < ls[j].Name })
+				samples = append(samples, Sample{Name: name + suffix, Labels: ls, Value: value, Timestamp: stamp})
+			}
+
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				add("", m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				add("", m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				add("", m.GetUntyped().GetValue())
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.GetQuantile() {
+					add("", q.GetValue(), Label{Name: "quantile", Value: formatFloat(q.GetQuantile())})
+				}
+				add("_sum", s.GetSampleSum())
+				add("_count", float64(s.GetSampleCount()))
+			case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+				h := m.GetHistogram()
+				infSeen := false
+				for _, b := range h.GetBucket() {
+					if math.IsInf(b.GetUpperBound(), 1) {
+						infSeen = true
+					}
+					add("_bucket", float64(b.GetCumulativeCount()), Label{Name: "le", Value: formatFloat(b.GetUpperBound())})
+				}
+				if !infSeen {
+					add("_bucket", float64(h.GetSampleCount()), Label{Name: "le", Value: "+Inf"})
+				}
+				add("_sum", h.GetSampleSum())
+				add("_count", float64(h.GetSampleCount()))
+			}
+		}
+	}
+	return samples
+}
+
+// / Strip the -metrics.namespace prefix from a metric name, for sinks that
+// / have their own prefix and group metrics by their station_ or bike_ part.
+func trimNamespace(name, namespace string) string {
+	if namespace == "" {
+		return name
+	}
+	return strings.TrimPrefix(name, namespace+"_")
+}
+
+// / Report whether name is one of the exporter's own operational metrics
+// / rather than a measurement of the bikeshare system.
+func isExporterMetric(name string) bool {
+	return strings.HasPrefix(name, "baywheels_exporter_")
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
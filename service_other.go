@@ -0,0 +1,11 @@
+//go:build !windows
+
+package main
+
+import "errors"
+
+func runService(args []string) error {
+	return errors.New("services are only supported on Windows; use a systemd unit elsewhere")
+}
+
+func startService(stop func()) func() { return func() {} }
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+const serviceName = "baywheels-exporter"
+
+// / Run `service install` or `service uninstall`, registering the exporter as
+// / a Windows service started at boot and restarted when it fails. Flags
+// / after install are passed to the exporter whenever the service starts.
+func runService(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: service install [flags] | service uninstall")
+	}
+	switch args[0] {
+	case "install":
+		return installService(args[1:])
+	case "uninstall":
+		return uninstallService()
+	default:
+		return fmt.Errorf("unknown service action %q", args[0])
+	}
+}
+
+func installService(args []string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	if s, err := m.OpenService(serviceName); err == nil {
+		s.Close()
+		return fmt.Errorf("service %s is already installed", serviceName)
+	}
+	s, err := m.CreateService(serviceName, exe, mgr.Config{
+		DisplayName: "Baywheels Exporter",
+		Description: "Prometheus exporter for GBFS bikeshare feeds",
+		StartType:   mgr.StartAutomatic,
+	}, args...)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	if err := s.SetRecoveryActions([]mgr.RecoveryAction{{Type: mgr.ServiceRestart, Delay: time.Minute}}, uint32((24 * time.Hour).Seconds())); err != nil {
+		s.Delete()
+		return err
+	}
+	if err := eventlog.InstallAsEventCreate(serviceName, eventlog.Error|eventlog.Warning|eventlog.Info); err != nil {
+		s.Delete()
+		return err
+	}
+	return nil
+}
+
+func uninstallService() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed", serviceName)
+	}
+	defer s.Close()
+	if err := s.Delete(); err != nil {
+		return err
+	}
+	return eventlog.Remove(serviceName)
+}
+
+// / When started by the service control manager, report the service as
+// / running and log to the event log as there is no console. Stopping the
+// / service calls stop, and it is only reported stopped once the returned
+// / func is called after cleaning up, so the manager doesn't take the exit
+// / for a failure.
+func startService(stop func()) func() {
+	isService, err := svc.IsWindowsService()
+	if err != nil || !isService {
+		return func() {}
+	}
+	if elog, err := eventlog.Open(serviceName); err == nil {
+		log.SetOutput(eventLogWriter{elog})
+	}
+	handler := serviceHandler{stop: stop, cleanedUp: make(chan struct{})}
+	exited := make(chan struct{})
+	go func() {
+		if err := svc.Run(serviceName, handler); err != nil {
+			log.Fatalf("Error running as a Windows service %s\n", err)
+		}
+		close(exited)
+	}()
+	return func() {
+		close(handler.cleanedUp)
+		<-exited
+	}
+}
+
+type serviceHandler struct {
+	stop      func()
+	cleanedUp chan struct{}
+}
+
+func (h serviceHandler) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				status <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				// cleaning up flushes traces and releases the leader lock
+				status <- svc.Status{State: svc.StopPending, WaitHint: uint32((30 * time.Second).Milliseconds())}
+				h.stop()
+				<-h.cleanedUp
+				return false, 0
+			}
+		// the exporter is shutting down on its own, e.g. on a signal
+		case <-h.cleanedUp:
+			return false, 0
+		}
+	}
+}
+
+// / eventLogWriter writes log lines to the event log, as errors for the
+// / "Error ..." lines.
+type eventLogWriter struct {
+	elog *eventlog.Log
+}
+
+func (w eventLogWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimSpace(string(p))
+	var err error
+	if strings.Contains(msg, " Error ") {
+		err = w.elog.Error(1, msg)
+	} else {
+		err = w.elog.Info(1, msg)
+	}
+	return len(p), err
+}
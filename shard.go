@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// / Shard is the part of the stations and bikes a replica exports, so the
+// / series of very large systems can be split across replicas by hashing
+// / their ids. The zero Shard owns everything.
+type Shard struct {
+	// Index is 1-based, Index/Count as in -shard=2/5.
+	Index int
+	Count int
+}
+
+func (s *Shard) String() string {
+	if s.Count == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d/%d", s.Index, s.Count)
+}
+
+func (s *Shard) Set(v string) error {
+	index, count, ok := strings.Cut(v, "/")
+	if !ok {
+		return fmt.Errorf("expected index/count, got %q", v)
+	}
+	var err error
+	if s.Index, err = strconv.Atoi(index); err != nil {
+		return fmt.Errorf("invalid shard index %q", index)
+	}
+	if s.Count, err = strconv.Atoi(count); err != nil {
+		return fmt.Errorf("invalid shard count %q", count)
+	}
+	if s.Count < 1 || s.Index < 1 || s.Index > s.Count {
+		return fmt.Errorf("shard index must be between 1 and the count, got %q", v)
+	}
+	return nil
+}
+
+// / Return whether the shard owns the station or bike with id.
+func (s Shard) Owns(id string) bool {
+	if s.Count <= 1 {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return int(h.Sum32()%uint32(s.Count)) == s.Index-1
+}
+
+// / Return the elements of items owned by shard.
+func owned[T any](shard Shard, items []T, id func(*T) string) []T {
+	if shard.Count <= 1 {
+		return items
+	}
+	kept := items[:0:0]
+	for i := range items {
+		if shard.Owns(id(&items[i])) {
+			kept = append(kept, items[i])
+		}
+	}
+	return kept
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestShardSet(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Shard
+		wantErr bool
+	}{
+		{in: "1/1", want: Shard{Index: 1, Count: 1}},
+		{in: "2/5", want: Shard{Index: 2, Count: 5}},
+		{in: "5/5", want: Shard{Index: 5, Count: 5}},
+		{in: "0/5", wantErr: true},
+		{in: "6/5", wantErr: true},
+		{in: "1/0", wantErr: true},
+		{in: "2", wantErr: true},
+		{in: "a/5", wantErr: true},
+		{in: "1/b", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			var s Shard
+			err := s.Set(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Set(%q) = %s, want error", tt.in, s.String())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Set(%q): %s", tt.in, err)
+			}
+			if s != tt.want {
+				t.Errorf("Set(%q) = %+v, want %+v", tt.in, s, tt.want)
+			}
+		})
+	}
+}
+
+func TestShardOwns(t *testing.T) {
+	const stations = 2000
+	for _, count := range []int{1, 2, 3, 5, 8} {
+		t.Run(fmt.Sprint(count), func(t *testing.T) {
+			owners := make([]int, count)
+			for i := 0; i < stations; i++ {
+				id := fmt.Sprintf("station-%d", i)
+				n := 0
+				for index := 1; index <= count; index++ {
+					if (Shard{Index: index, Count: count}).Owns(id) {
+						owners[index-1]++
+						n++
+					}
+				}
+				// every station is exported by exactly one replica
+				if n != 1 {
+					t.Fatalf("%s is owned by %d of %d shards", id, n, count)
+				}
+			}
+			// and the stations are spread evenly across them
+			even := stations / count
+			for index, owned := range owners {
+				if owned < even*3/4 || owned > even*5/4 {
+					t.Errorf("shard %d/%d owns %d of %d stations, want about %d", index+1, count, owned, stations, even)
+				}
+			}
+		})
+	}
+}
+
+func TestShardOwnsUnsharded(t *testing.T) {
+	// without -shard every station is owned
+	if !(Shard{}).Owns("station-1") {
+		t.Error("the zero Shard doesn't own station-1")
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// / Cycle is the outcome of a single sampling pass over the GBFS feeds.
+type Cycle struct {
+	Time       time.Time
+	Snapshot   *Snapshot
+	Changes    []StationChange
+	BikeEvents []BikeEvent
+	Events     []Event
+	Families   []*dto.MetricFamily
+	// Errors holds the feeds that failed to fetch this cycle, Fetched those
+	// fetched successfully. The part of the Snapshot of the others is
+	// carried over from their last successful fetch.
+	Errors  map[string]error
+	Fetched map[string]bool
+	// Closed is set while the system is closed and its status feeds
+	// aren't sampled.
+	Closed bool
+}
+
+// / A Sink is handed every Cycle once sampling has completed, so it can
+// / forward the results somewhere other than the /metrics endpoint.
+type Sink interface {
+	Name() string
+	Send(ctx context.Context, cycle *Cycle) error
+}
+
+type SinkMetrics struct {
+	sends  *prometheus.CounterVec
+	errors *prometheus.CounterVec
+}
+
+func NewSinkMetrics(reg prometheus.Registerer) *SinkMetrics {
+	m := &SinkMetrics{
+		sends: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "baywheels_exporter_sink_sends_total",
+			Help: "Number of sampling cycles handed to each sink",
+		},
+			[]string{"sink"},
+		),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "baywheels_exporter_sink_errors_total",
+			Help: "Number of sampling cycles each sink failed to handle",
+		},
+			[]string{"sink"},
+		),
+	}
+	reg.MustRegister(m.sends)
+	reg.MustRegister(m.errors)
+
+	return m
+}
+
+// / Upper bound on the time a single sink may spend on a cycle, so one
+// / unresponsive destination can't stall sampling indefinitely.
+const sinkTimeout = 30 * time.Second
+
+// / Hand the cycle to every sink in turn, skipping those on standby.
+// / Failures are logged and counted but do not stop the remaining sinks from
+// / running.
+func dispatch(ctx context.Context, metrics *SinkMetrics, sinks []Sink, cycle *Cycle) {
+	for _, sink := range sinks {
+		if s, ok := sink.(interface{ Active() bool }); ok && !s.Active() {
+			continue
+		}
+		metrics.sends.WithLabelValues(sink.Name()).Inc()
+		ctx, span := tracer.Start(ctx, "sink "+sink.Name())
+		ctx, cancel := context.WithTimeout(ctx, sinkTimeout)
+		err := sink.Send(ctx, cycle)
+		cancel()
+		if err != nil {
+			metrics.errors.WithLabelValues(sink.Name()).Inc()
+			log.Printf("Error sending to %s sink %s\n", sink.Name(), err)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type sloSample struct {
+	time             time.Time
+	hasBike, hasDock bool
+}
+
+// / SLOMetrics reports the share of samples over a rolling Window in which
+// / each station had a bike to rent and a dock to return to, a service level
+// / per station without recording rules.
+type SLOMetrics struct {
+	Window time.Duration
+
+	station_availability_ratio      prometheus.GaugeVec
+	station_bike_availability_ratio prometheus.GaugeVec
+	station_dock_availability_ratio prometheus.GaugeVec
+
+	samples map[string][]sloSample
+}
+
+func NewSLOMetrics(reg prometheus.Registerer) *SLOMetrics {
+	m := &SLOMetrics{
+		Window: 24 * time.Hour,
+		station_availability_ratio: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "station_availability_ratio",
+			Help: "Share of samples over the SLO window in which the station had at least one bike and one dock available",
+		},
+			[]string{"station_id", "name"},
+		),
+		station_bike_availability_ratio: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "station_bike_availability_ratio",
+			Help: "Share of samples over the SLO window in which the station had at least one bike available",
+		},
+			[]string{"station_id", "name"},
+		),
+		station_dock_availability_ratio: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "station_dock_availability_ratio",
+			Help: "Share of samples over the SLO window in which the station had at least one dock available",
+		},
+			[]string{"station_id", "name"},
+		),
+		samples: make(map[string][]sloSample),
+	}
+	reg.MustRegister(m.station_availability_ratio)
+	reg.MustRegister(m.station_bike_availability_ratio)
+	reg.MustRegister(m.station_dock_availability_ratio)
+
+	return m
+}
+
+// / Add the availability of every station in snapshot and recompute the
+// / ratios over the samples within the window.
+func (m *SLOMetrics) Observe(snapshot *Snapshot) {
+	cutoff := snapshot.Time.Add(-m.Window)
+	for _, station := range snapshot.Stations {
+		if station.Status == nil {
+			continue
+		}
+		samples := append(m.samples[station.StationId], sloSample{
+			time:    snapshot.Time,
+			hasBike: station.Status.BikesAvailable > 0,
+			hasDock: station.Status.DocksAvailable > 0,
+		})
+		for len(samples) > 0 && !samples[0].time.After(cutoff) {
+			samples = samples[1:]
+		}
+		m.samples[station.StationId] = samples
+
+		var both, bikes, docks int
+		for _, sample := range samples {
+			if sample.hasBike {
+				bikes++
+			}
+			if sample.hasDock {
+				docks++
+			}
+			if sample.hasBike && sample.hasDock {
+				both++
+			}
+		}
+		total := float64(len(samples))
+		labels := stationLabels(station.StationId, string(station.Name))
+		m.station_availability_ratio.With(labels).Set(float64(both) / total)
+		m.station_bike_availability_ratio.With(labels).Set(float64(bikes) / total)
+		m.station_dock_availability_ratio.With(labels).Set(float64(docks) / total)
+	}
+}
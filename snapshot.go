@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/patrickod/baywheels-exporter/pkg/gbfs"
+)
+
+// / Station joins a station's information with its most recent status. Status
+// / is nil for stations that are missing from the station_status feed.
+type Station struct {
+	gbfs.StationInformation
+	Status *gbfs.StationStatus `json:"status,omitempty"`
+}
+
+// / Snapshot is the merged state of the GBFS feeds at the end of a sampling
+// / cycle. Snapshots are never modified once built, so they can be shared
+// / freely between the sampling loop and HTTP handlers.
+type Snapshot struct {
+	Time     time.Time
+	Stations []Station
+	Bikes    []gbfs.BikeStatus
+
+	stationIndex map[string]int
+}
+
+func NewSnapshot(t time.Time, information []gbfs.StationInformation, statuses []gbfs.StationStatus, bikes []gbfs.BikeStatus) *Snapshot {
+	s := &Snapshot{
+		Time:         t,
+		Stations:     make([]Station, 0, len(information)),
+		Bikes:        bikes,
+		stationIndex: make(map[string]int, len(information)),
+	}
+	for _, info := range information {
+		s.stationIndex[info.StationId] = len(s.Stations)
+		s.Stations = append(s.Stations, Station{StationInformation: info})
+	}
+	for i := range statuses {
+		status := &statuses[i]
+		idx, ok := s.stationIndex[status.StationId]
+		if !ok {
+			// status for a station we have no information about
+			idx = len(s.Stations)
+			s.stationIndex[status.StationId] = idx
+			s.Stations = append(s.Stations, Station{StationInformation: gbfs.StationInformation{StationId: status.StationId}})
+		}
+		s.Stations[idx].Status = status
+	}
+	sort.Slice(s.Stations, func(i, j int) bool { return s.Stations[i].StationId < s.Stations[j].StationId })
+	for i, station := range s.Stations {
+		s.stationIndex[station.StationId] = i
+	}
+	return s
+}
+
+// / Look up a station by its station_id.
+func (s *Snapshot) Station(id string) (Station, bool) {
+	idx, ok := s.stationIndex[id]
+	if !ok {
+		return Station{}, false
+	}
+	return s.Stations[idx], true
+}
+
+// / Look up a station by its station_id, or failing that its short_name, as
+// / riders know stations by the number on the kiosk rather than the id.
+func (s *Snapshot) Find(key string) (Station, bool) {
+	if station, ok := s.Station(key); ok {
+		return station, true
+	}
+	for _, station := range s.Stations {
+		if station.ShortName != "" && string(station.ShortName) == key {
+			return station, true
+		}
+	}
+	return Station{}, false
+}
+
+// / Fetch the station feeds, and free_bike_status if withBikes, once and
+// / merge them into a Snapshot, for the subcommands that query a system
+// / rather than export it. Systems without free_bike_status have no bikes.
+func fetchSnapshot(ctx context.Context, client *gbfs.Client, withBikes bool) (*Snapshot, error) {
+	information, err := client.StationInformation(ctx)
+	if err != nil {
+		return nil, err
+	}
+	status, err := client.StationStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var bikes []gbfs.BikeStatus
+	if withBikes {
+		if response, err := client.FreeBikeStatus(ctx); err == nil {
+			bikes = response.Data.Bikes
+		}
+	}
+	return NewSnapshot(time.Now(), information.Data.Stations, status.Data.Stations, bikes), nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// / Keep idle connections alive through proxies that time out silent
+// / responses.
+const sseHeartbeatInterval = 30 * time.Second
+
+// / Stream station changes as Server-Sent Events, one station_change event
+// / per changed station. Accepts the same station_id filter as /ws.
+func handleEvents(feed *ChangeFeed) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filter := stationFilter(r)
+		rc := http.NewResponseController(w)
+
+		changes, unsubscribe := feed.Subscribe()
+		defer unsubscribe()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		// stop nginx from buffering the stream
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(sseHeartbeatInterval)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": heartbeat\n\n")
+			case change := <-changes:
+				if !filter(change.StationId) {
+					continue
+				}
+				data, err := json.Marshal(change)
+				if err != nil {
+					continue
+				}
+				fmt.Fprintf(w, "event: station_change\ndata: %s\n\n", data)
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type staleState struct {
+	lastReported time.Time
+	unchanged    int
+	stale        bool
+}
+
+// / StaleMetrics flags stations whose last_reported hasn't advanced for
+// / Samples consecutive samples. Broken kiosks often keep reporting
+// / is_renting and is_installed as 1 while they stop checking in, so those
+// / flags alone don't catch them.
+type StaleMetrics struct {
+	Samples int
+
+	station_stale                prometheus.GaugeVec
+	station_stale_episodes_total prometheus.CounterVec
+
+	stations map[string]*staleState
+}
+
+func NewStaleMetrics(reg prometheus.Registerer) *StaleMetrics {
+	m := &StaleMetrics{
+		Samples: 10,
+		station_stale: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "station_stale",
+			Help: "1 if the station's last_reported hasn't advanced for the configured number of samples",
+		},
+			[]string{"station_id", "name"},
+		),
+		station_stale_episodes_total: *prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "station_stale_episodes_total",
+			Help: "Number of times the station has become stale",
+		},
+			[]string{"station_id", "name"},
+		),
+		stations: make(map[string]*staleState),
+	}
+	reg.MustRegister(m.station_stale)
+	reg.MustRegister(m.station_stale_episodes_total)
+
+	return m
+}
+
+// / Compare each station's last_reported with the previous sample. fresh is
+// / false when station_status failed to fetch and snapshot holds the
+// / statuses of an earlier cycle, which must not count as unchanged.
+func (m *StaleMetrics) Observe(snapshot *Snapshot, fresh bool) {
+	if !fresh {
+		return
+	}
+	for _, station := range snapshot.Stations {
+		if station.Status == nil {
+			continue
+		}
+		labels := stationLabels(station.StationId, string(station.Name))
+		episodes := m.station_stale_episodes_total.With(labels)
+
+		state, ok := m.stations[station.StationId]
+		if !ok {
+			state = &staleState{lastReported: station.Status.LastReported.Time}
+			m.stations[station.StationId] = state
+		} else if station.Status.LastReported.Equal(state.lastReported) {
+			state.unchanged++
+		} else {
+			state.lastReported = station.Status.LastReported.Time
+			state.unchanged = 0
+		}
+
+		stale := m.Samples > 0 && state.unchanged >= m.Samples
+		if stale && !state.stale {
+			episodes.Inc()
+		}
+		state.stale = stale
+		value := 0.0
+		if stale {
+			value = 1
+		}
+		m.station_stale.With(labels).Set(value)
+	}
+}
@@ -0,0 +1,507 @@
+// A minimal slippy map for /map, served by the exporter so the page works
+// offline and behind proxies that block CDNs; only the tiles are remote. It
+// implements the subset of the Leaflet API the page uses, under the same
+// names: a web mercator tile layer, circle markers with popups, layer
+// groups, the layers and custom controls, geolocation and fitBounds.
+(function () {
+  "use strict";
+
+  const TILE = 256;
+
+  function toLatLng(v) {
+    if (Array.isArray(v)) return { lat: v[0], lng: v[1] };
+    return { lat: v.lat, lng: v.lng };
+  }
+
+  // world pixel coordinates at zoom
+  function project(latlng, zoom) {
+    const scale = TILE * Math.pow(2, zoom);
+    const lat = Math.max(-85.05112878, Math.min(85.05112878, latlng.lat));
+    const sin = Math.sin(lat * Math.PI / 180);
+    return {
+      x: (latlng.lng + 180) / 360 * scale,
+      y: (0.5 - Math.log((1 + sin) / (1 - sin)) / (4 * Math.PI)) * scale,
+    };
+  }
+
+  function unproject(p, zoom) {
+    const scale = TILE * Math.pow(2, zoom);
+    const n = Math.PI - 2 * Math.PI * p.y / scale;
+    return {
+      lat: 180 / Math.PI * Math.atan(Math.sinh(n)),
+      lng: p.x / scale * 360 - 180,
+    };
+  }
+
+  function create(tag, className, parent) {
+    const el = document.createElement(tag);
+    if (className) el.className = className;
+    if (parent) parent.appendChild(el);
+    return el;
+  }
+
+  const SVG = "http://www.w3.org/2000/svg";
+
+  class MapView {
+    constructor(id) {
+      this._container = typeof id === "string" ? document.getElementById(id) : id;
+      this._container.classList.add("map-container");
+      this._tiles = create("div", "map-tiles", this._container);
+      this._svg = document.createElementNS(SVG, "svg");
+      this._svg.setAttribute("class", "map-overlay");
+      this._container.appendChild(this._svg);
+      this._popupPane = create("div", "map-popups", this._container);
+      this._corners = {};
+      for (const pos of ["topleft", "topright", "bottomleft", "bottomright"]) {
+        this._corners[pos] = create("div", "map-control-corner map-" + pos, this._container);
+      }
+      this._center = { lat: 0, lng: 0 };
+      this._zoom = 1;
+      this._minZoom = 0;
+      this._maxZoom = 19;
+      this._layers = new Set();
+      this._handlers = {};
+      this._popup = null;
+      this._addZoomControl();
+      this._addInteractions();
+      window.addEventListener("resize", () => this._render());
+    }
+
+    setView(latlng, zoom) {
+      this._center = toLatLng(latlng);
+      if (zoom !== undefined) this._zoom = this._clampZoom(zoom);
+      this._render();
+      return this;
+    }
+
+    getZoom() {
+      return this._zoom;
+    }
+
+    fitBounds(points) {
+      if (points.length === 0) return this;
+      const lls = points.map(toLatLng);
+      let south = Infinity, north = -Infinity, west = Infinity, east = -Infinity;
+      for (const ll of lls) {
+        south = Math.min(south, ll.lat);
+        north = Math.max(north, ll.lat);
+        west = Math.min(west, ll.lng);
+        east = Math.max(east, ll.lng);
+      }
+      const width = this._container.clientWidth - 40, height = this._container.clientHeight - 40;
+      let zoom = this._maxZoom;
+      for (; zoom > this._minZoom; zoom--) {
+        const sw = project({ lat: south, lng: west }, zoom), ne = project({ lat: north, lng: east }, zoom);
+        if (ne.x - sw.x <= width && sw.y - ne.y <= height) break;
+      }
+      const sw = project({ lat: south, lng: west }, zoom), ne = project({ lat: north, lng: east }, zoom);
+      return this.setView(unproject({ x: (sw.x + ne.x) / 2, y: (sw.y + ne.y) / 2 }, zoom), zoom);
+    }
+
+    addLayer(layer) {
+      if (this._layers.has(layer)) return this;
+      this._layers.add(layer);
+      layer._map = this;
+      layer._add(this);
+      this._render();
+      return this;
+    }
+
+    removeLayer(layer) {
+      if (!this._layers.delete(layer)) return this;
+      layer._remove(this);
+      layer._map = null;
+      return this;
+    }
+
+    hasLayer(layer) {
+      return this._layers.has(layer);
+    }
+
+    on(event, fn) {
+      (this._handlers[event] = this._handlers[event] || []).push(fn);
+      return this;
+    }
+
+    fire(event, data) {
+      for (const fn of this._handlers[event] || []) fn(data);
+    }
+
+    locate(options) {
+      options = options || {};
+      if (!navigator.geolocation) return this;
+      navigator.geolocation.getCurrentPosition((pos) => {
+        const latlng = { lat: pos.coords.latitude, lng: pos.coords.longitude };
+        if (options.setView) this.setView(latlng, Math.min(options.maxZoom || this._maxZoom, 16));
+        this.fire("locationfound", { latlng: latlng });
+      }, () => {});
+      return this;
+    }
+
+    openPopup(html, latlng) {
+      this.closePopup();
+      const el = create("div", "map-popup", this._popupPane);
+      const content = create("div", "map-popup-content", el);
+      content.innerHTML = html;
+      const close = create("a", "map-popup-close", el);
+      close.href = "#";
+      close.innerHTML = "&times;";
+      close.addEventListener("click", (e) => {
+        e.preventDefault();
+        this.closePopup();
+      });
+      el.addEventListener("pointerdown", (e) => e.stopPropagation());
+      this._popup = { el: el, content: content, latlng: toLatLng(latlng) };
+      this._render();
+    }
+
+    closePopup() {
+      if (!this._popup) return;
+      this._popup.el.remove();
+      this._popup = null;
+    }
+
+    // container pixel of a coordinate
+    latLngToPoint(latlng) {
+      const c = project(this._center, this._zoom), p = project(latlng, this._zoom);
+      return {
+        x: p.x - c.x + this._container.clientWidth / 2,
+        y: p.y - c.y + this._container.clientHeight / 2,
+      };
+    }
+
+    _clampZoom(zoom) {
+      return Math.max(this._minZoom, Math.min(this._maxZoom, Math.round(zoom)));
+    }
+
+    _zoomAround(point, delta) {
+      const zoom = this._clampZoom(this._zoom + delta);
+      if (zoom === this._zoom) return;
+      // keep the coordinate under point in place
+      const c = project(this._center, this._zoom);
+      const offset = { x: point.x - this._container.clientWidth / 2, y: point.y - this._container.clientHeight / 2 };
+      const at = unproject({ x: c.x + offset.x, y: c.y + offset.y }, this._zoom);
+      const p = project(at, zoom);
+      this._center = unproject({ x: p.x - offset.x, y: p.y - offset.y }, zoom);
+      this._zoom = zoom;
+      this._render();
+    }
+
+    _panBy(dx, dy) {
+      const c = project(this._center, this._zoom);
+      this._center = unproject({ x: c.x - dx, y: c.y - dy }, this._zoom);
+      this._render();
+    }
+
+    _addZoomControl() {
+      const bar = create("div", "map-control map-bar", this._corners.topleft);
+      for (const [label, delta] of [["+", 1], ["−", -1]]) {
+        const a = create("a", "", bar);
+        a.href = "#";
+        a.textContent = label;
+        a.addEventListener("click", (e) => {
+          e.preventDefault();
+          this._zoomAround({ x: this._container.clientWidth / 2, y: this._container.clientHeight / 2 }, delta);
+        });
+      }
+    }
+
+    _addInteractions() {
+      const el = this._container;
+      const pointers = new Map();
+      let pinch = 0, moved = false, target = null;
+      const local = (e) => {
+        const r = el.getBoundingClientRect();
+        return { x: e.clientX - r.left, y: e.clientY - r.top };
+      };
+      el.addEventListener("pointerdown", (e) => {
+        if (e.target.closest(".map-control")) return;
+        pointers.set(e.pointerId, local(e));
+        // capturing retargets the pointerup to the container
+        target = e.target;
+        el.setPointerCapture(e.pointerId);
+        moved = false;
+        if (pointers.size === 2) {
+          const [a, b] = [...pointers.values()];
+          pinch = Math.hypot(a.x - b.x, a.y - b.y);
+        }
+      });
+      el.addEventListener("pointermove", (e) => {
+        const prev = pointers.get(e.pointerId);
+        if (!prev) return;
+        const cur = local(e);
+        pointers.set(e.pointerId, cur);
+        if (pointers.size === 1) {
+          if (Math.abs(cur.x - prev.x) + Math.abs(cur.y - prev.y) > 0) moved = true;
+          this._panBy(cur.x - prev.x, cur.y - prev.y);
+        } else if (pointers.size === 2) {
+          moved = true;
+          const [a, b] = [...pointers.values()];
+          const d = Math.hypot(a.x - b.x, a.y - b.y);
+          // zoom a level each time the fingers' distance doubles or halves
+          if (d > pinch * 2 || d < pinch / 2) {
+            this._zoomAround({ x: (a.x + b.x) / 2, y: (a.y + b.y) / 2 }, d > pinch ? 1 : -1);
+            pinch = d;
+          }
+        }
+      });
+      const up = (e) => {
+        pointers.delete(e.pointerId);
+      };
+      el.addEventListener("pointerup", (e) => {
+        if (!pointers.has(e.pointerId)) return;
+        up(e);
+        if (moved || pointers.size > 0) return;
+        // a click on a marker opens its popup, anywhere else closes it
+        if (target && target.__marker) target.__marker.openPopup();
+        else this.closePopup();
+      });
+      el.addEventListener("pointercancel", up);
+      let wheel = 0;
+      el.addEventListener("wheel", (e) => {
+        e.preventDefault();
+        wheel += e.deltaY;
+        // trackpads send many small deltas, zoom a level every so often
+        if (Math.abs(wheel) >= 60) {
+          this._zoomAround(local(e), wheel < 0 ? 1 : -1);
+          wheel = 0;
+        }
+      }, { passive: false });
+      el.addEventListener("dblclick", (e) => {
+        if (e.target.closest(".map-control")) return;
+        this._zoomAround(local(e), e.shiftKey ? -1 : 1);
+      });
+    }
+
+    _render() {
+      if (this._rendering) return;
+      this._rendering = true;
+      requestAnimationFrame(() => {
+        this._rendering = false;
+        for (const layer of this._layers) layer._draw && layer._draw(this);
+        if (this._popup) {
+          const p = this.latLngToPoint(this._popup.latlng);
+          this._popup.el.style.left = p.x + "px";
+          this._popup.el.style.top = p.y + "px";
+        }
+      });
+    }
+  }
+
+  class TileLayer {
+    constructor(template, options) {
+      this._template = template;
+      this._options = options || {};
+      this._imgs = new Map();
+    }
+
+    addTo(map) {
+      map.addLayer(this);
+      return this;
+    }
+
+    _add(map) {
+      if (this._options.maxZoom !== undefined) map._maxZoom = this._options.maxZoom;
+      if (this._options.attribution) {
+        const el = create("div", "map-control map-attribution", map._corners.bottomright);
+        el.innerHTML = this._options.attribution;
+        this._attribution = el;
+      }
+    }
+
+    _remove(map) {
+      for (const img of this._imgs.values()) img.remove();
+      this._imgs.clear();
+      this._attribution && this._attribution.remove();
+    }
+
+    _draw(map) {
+      const zoom = map._zoom, n = Math.pow(2, zoom);
+      const w = map._container.clientWidth, h = map._container.clientHeight;
+      const c = project(map._center, zoom);
+      const left = c.x - w / 2, top = c.y - h / 2;
+      const wanted = new Set();
+      for (let ty = Math.floor(top / TILE); ty <= Math.floor((top + h) / TILE); ty++) {
+        if (ty < 0 || ty >= n) continue;
+        for (let tx = Math.floor(left / TILE); tx <= Math.floor((left + w) / TILE); tx++) {
+          const x = ((tx % n) + n) % n;
+          const key = zoom + "/" + tx + "/" + ty;
+          wanted.add(key);
+          let img = this._imgs.get(key);
+          if (!img) {
+            img = create("img", "map-tile", map._tiles);
+            img.alt = "";
+            img.draggable = false;
+            img.src = this._template.replace("{z}", zoom).replace("{x}", x).replace("{y}", ty);
+            this._imgs.set(key, img);
+          }
+          img.style.left = Math.round(tx * TILE - left) + "px";
+          img.style.top = Math.round(ty * TILE - top) + "px";
+        }
+      }
+      for (const [key, img] of this._imgs) {
+        if (!wanted.has(key)) {
+          img.remove();
+          this._imgs.delete(key);
+        }
+      }
+    }
+  }
+
+  class CircleMarker {
+    constructor(latlng, options) {
+      this._latlng = toLatLng(latlng);
+      this._style = Object.assign({ radius: 10, color: "#3388ff", weight: 3, opacity: 1, fillOpacity: 0.2 }, options);
+      this._popupHTML = null;
+    }
+
+    addTo(target) {
+      target.addLayer(this);
+      return this;
+    }
+
+    setLatLng(latlng) {
+      this._latlng = toLatLng(latlng);
+      this._map && this._map._render();
+      return this;
+    }
+
+    setStyle(style) {
+      Object.assign(this._style, style);
+      this._applyStyle();
+      return this;
+    }
+
+    bindPopup(html) {
+      this._popupHTML = html;
+      return this;
+    }
+
+    setPopupContent(html) {
+      this._popupHTML = html;
+      const popup = this._map && this._map._popup;
+      if (popup && popup.owner === this) popup.content.innerHTML = html;
+      return this;
+    }
+
+    openPopup() {
+      if (!this._map || this._popupHTML === null) return this;
+      this._map.openPopup(this._popupHTML, this._latlng);
+      this._map._popup.owner = this;
+      return this;
+    }
+
+    _add(map) {
+      this._el = document.createElementNS(SVG, "circle");
+      this._el.__marker = this;
+      this._applyStyle();
+      map._svg.appendChild(this._el);
+    }
+
+    _remove(map) {
+      if (map._popup && map._popup.owner === this) map.closePopup();
+      this._el.remove();
+    }
+
+    _applyStyle() {
+      if (!this._el) return;
+      const s = this._style;
+      this._el.setAttribute("r", s.radius);
+      this._el.setAttribute("fill", s.fillColor || s.color);
+      this._el.setAttribute("fill-opacity", s.fillOpacity);
+      this._el.setAttribute("stroke", s.weight > 0 ? s.color : "none");
+      this._el.setAttribute("stroke-width", s.weight);
+      this._el.setAttribute("stroke-opacity", s.opacity);
+    }
+
+    _draw(map) {
+      const p = map.latLngToPoint(this._latlng);
+      this._el.setAttribute("cx", p.x);
+      this._el.setAttribute("cy", p.y);
+    }
+  }
+
+  class LayerGroup {
+    constructor() {
+      this._layers = new Set();
+    }
+
+    addTo(map) {
+      map.addLayer(this);
+      return this;
+    }
+
+    addLayer(layer) {
+      this._layers.add(layer);
+      if (this._map) this._map.addLayer(layer);
+      return this;
+    }
+
+    removeLayer(layer) {
+      this._layers.delete(layer);
+      if (this._map) this._map.removeLayer(layer);
+      return this;
+    }
+
+    clearLayers() {
+      for (const layer of this._layers) this.removeLayer(layer);
+      return this;
+    }
+
+    _add(map) {
+      for (const layer of this._layers) map.addLayer(layer);
+    }
+
+    _remove(map) {
+      for (const layer of this._layers) map.removeLayer(layer);
+    }
+  }
+
+  class Control {
+    constructor(options) {
+      this._position = (options && options.position) || "topright";
+    }
+
+    addTo(map) {
+      const el = this.onAdd(map);
+      el.classList.add("map-control");
+      map._corners[this._position].appendChild(el);
+      return this;
+    }
+  }
+
+  // a checkbox per overlay, toggling it on the map
+  class LayersControl extends Control {
+    constructor(base, overlays) {
+      super({ position: "topright" });
+      this._overlays = overlays || {};
+    }
+
+    onAdd(map) {
+      const el = create("div", "map-layers");
+      for (const [name, layer] of Object.entries(this._overlays)) {
+        const label = create("label", "", el);
+        const input = create("input", "", label);
+        input.type = "checkbox";
+        input.checked = map.hasLayer(layer);
+        input.addEventListener("change", () => {
+          input.checked ? map.addLayer(layer) : map.removeLayer(layer);
+        });
+        label.appendChild(document.createTextNode(" " + name));
+      }
+      return el;
+    }
+  }
+
+  const control = (options) => new Control(options);
+  control.layers = (base, overlays) => new LayersControl(base, overlays);
+
+  window.L = {
+    map: (id) => new MapView(id),
+    tileLayer: (template, options) => new TileLayer(template, options),
+    circleMarker: (latlng, options) => new CircleMarker(latlng, options),
+    layerGroup: () => new LayerGroup(),
+    control: control,
+    DomUtil: { create: create },
+  };
+})();
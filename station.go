@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var stationTemplate = template.Must(template.New("station").Funcs(templateFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta http-equiv="refresh" content="60">
+<title>{{ .Station.Name }}</title>
+<style>
+body { font-family: sans-serif; margin: 1em; max-width: 30em; }
+.counts { display: flex; gap: 1.5em; margin: 1em 0; }
+.count { font-size: 2.5em; font-weight: bold; }
+.label { color: #555; }
+.warning { color: #b00; }
+svg { width: 100%; height: 5em; }
+</style>
+</head>
+<body>
+<h1>{{ .Station.Name }}</h1>
+{{- with .Station.Status }}
+{{- if not .IsRenting }}<p class="warning">Not renting bikes</p>{{ end }}
+{{- if not .IsReturning }}<p class="warning">Not accepting returns</p>{{ end }}
+<div class="counts">
+<div><div class="count">{{ .BikesAvailable }}</div><div class="label">bikes</div></div>
+<div><div class="count">{{ .EBikesAvailable }}</div><div class="label">e-bikes</div></div>
+<div><div class="count">{{ .DocksAvailable }}</div><div class="label">docks</div></div>
+</div>
+{{- else }}
+<p class="warning">No status reported</p>
+{{- end }}
+{{- if .Sparkline }}
+<svg viewBox="0 0 {{ .Width }} {{ .Height }}" preserveAspectRatio="none">
+<title>Bikes available over the last {{ .Span }}</title>
+<polyline points="{{ .Sparkline }}" fill="none" stroke="#1a9641" stroke-width="2" vector-effect="non-scaling-stroke"/>
+</svg>
+<p class="label">Bikes available over the last {{ .Span }}</p>
+{{- end }}
+<p>Capacity {{ .Station.Capacity }}{{ with .Station.ShortName }} &middot; {{ . }}{{ end }}</p>
+<p title="{{ rfc3339 .LastReported }}">Last reported {{ ago .LastReported }}</p>
+<p><a href="../map">Map</a></p>
+</body>
+</html>
+`))
+
+const (
+	sparklineWidth  = 300
+	sparklineHeight = 60
+)
+
+// / Render a station's current availability and recent trend as a page
+// / small enough to bookmark on a phone.
+func handleStationPage(exporter *Exporter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		snapshot := exporter.Snapshot()
+		if snapshot == nil {
+			http.Error(w, "no data sampled yet", http.StatusServiceUnavailable)
+			return
+		}
+		station, ok := snapshot.Station(r.PathValue("id"))
+		if !ok {
+			http.Error(w, "unknown station", http.StatusNotFound)
+			return
+		}
+
+		data := struct {
+			Station       Station
+			LastReported  time.Time
+			Sparkline     string
+			Span          time.Duration
+			Width, Height int
+		}{Station: station, Width: sparklineWidth, Height: sparklineHeight}
+		if station.Status != nil && !station.Status.LastReported.IsZero() {
+			data.LastReported = station.Status.LastReported.Time
+		}
+		if exporter.Trends != nil {
+			points := exporter.Trends.Points(station.StationId)
+			if len(points) > 1 {
+				data.Sparkline = sparkline(points, station.Capacity)
+				data.Span = points[len(points)-1].Time.Sub(points[0].Time).Round(time.Minute)
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := stationTemplate.Execute(w, data); err != nil {
+			log.Printf("Error rendering station page %s\n", err)
+		}
+	}
+}
+
+// / Return the SVG polyline points plotting bikes available against time,
+// / scaled to the station's capacity so stations are comparable at a glance.
+func sparkline(points []TrendPoint, capacity int) string {
+	top := capacity
+	for _, p := range points {
+		top = max(top, p.BikesAvailable)
+	}
+	top = max(top, 1)
+	start, span := points[0].Time, points[len(points)-1].Time.Sub(points[0].Time)
+	if span <= 0 {
+		return ""
+	}
+
+	coords := make([]string, len(points))
+	for i, p := range points {
+		x := float64(sparklineWidth) * float64(p.Time.Sub(start)) / float64(span)
+		y := float64(sparklineHeight) * (1 - float64(p.BikesAvailable)/float64(top))
+		coords[i] = fmt.Sprintf("%.1f,%.1f", x, y)
+	}
+	return strings.Join(coords, " ")
+}
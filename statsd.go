@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// / Keep datagrams below the typical internet MTU so they are never
+// / fragmented; the unix socket transport could go larger but doesn't need to.
+const statsdMaxPacket = 1432
+
+type StatsDConfig struct {
+	// Address is host:port for UDP, or unix:///path for a unix datagram
+	// socket such as the Datadog agent's dsd.socket.
+	Address string
+	Prefix  string
+	// DogStatsD sends labels as tags rather than folding them into the
+	// metric name, which plain statsd has no way to express.
+	DogStatsD bool
+	// Namespace of the exporter's metrics, left out of statsd names.
+	Namespace string
+}
+
+// / StatsD is a Sink emitting the station gauges of each cycle as statsd
+// / gauges.
+type StatsD struct {
+	config StatsDConfig
+	conn   net.Conn
+}
+
+func NewStatsD(config StatsDConfig) (*StatsD, error) {
+	network, address := "udp", config.Address
+	if path, ok := strings.CutPrefix(config.Address, "unix://"); ok {
+		network, address = "unixgram", path
+	}
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return &StatsD{config: config, conn: conn}, nil
+}
+
+func (s *StatsD) Name() string {
+	return "statsd"
+}
+
+func (s *StatsD) Send(ctx context.Context, cycle *Cycle) error {
+	var packet, line bytes.Buffer
+	for _, sample := range flattenFamilies(cycle.Families, cycle.Time) {
+		sample.Name = trimNamespace(sample.Name, s.config.Namespace)
+		if !strings.HasPrefix(sample.Name, "station_") {
+			continue
+		}
+
+		line.Reset()
+		s.writeGauge(&line, sample)
+		if packet.Len() > 0 && packet.Len()+1+line.Len() > statsdMaxPacket {
+			if _, err := s.conn.Write(packet.Bytes()); err != nil {
+				return err
+			}
+			packet.Reset()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.Write(line.Bytes())
+	}
+	if packet.Len() > 0 {
+		if _, err := s.conn.Write(packet.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var (
+	statsdNameSanitizer = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+	statsdTagSanitizer  = strings.NewReplacer(",", "_", "|", "_", "#", "_", "\n", " ")
+)
+
+func (s *StatsD) writeGauge(buf *bytes.Buffer, sample Sample) {
+	buf.WriteString(s.config.Prefix)
+	buf.WriteString(sample.Name)
+	if !s.config.DogStatsD {
+		// plain statsd: station_bikes_available.<station_id>
+		for _, l := range sample.Labels {
+			if l.Name == "station_id" {
+				buf.WriteByte('.')
+				buf.WriteString(statsdNameSanitizer.ReplaceAllString(l.Value, "_"))
+			}
+		}
+	}
+	buf.WriteByte(':')
+	buf.WriteString(strconv.FormatFloat(sample.Value, 'f', -1, 64))
+	buf.WriteString("|g")
+	if s.config.DogStatsD && len(sample.Labels) > 0 {
+		buf.WriteString("|#")
+		for i, l := range sample.Labels {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(l.Name)
+			buf.WriteByte(':')
+			buf.WriteString(statsdTagSanitizer.Replace(l.Value))
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/patrickod/baywheels-exporter/pkg/gbfs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// / FeedStatus records the outcome of the most recent fetches of a GBFS feed.
+type FeedStatus struct {
+	Feed        string
+	LastAttempt time.Time
+	LastSuccess time.Time
+	LastError   string
+	Attempts    int
+	Errors      int
+	// ParseErrors counts the Errors where the feed was fetched but couldn't
+	// be decoded.
+	ParseErrors int
+	// LastUpdated, TTL and Version are from the envelope of the last
+	// successful fetch.
+	LastUpdated time.Time
+	TTL         int
+	Version     string
+}
+
+// / ScrapeStatus tracks the FeedStatus of every feed the exporter samples. It
+// / is written by the sampling loop and read by the HTTP handlers.
+type ScrapeStatus struct {
+	mu    sync.Mutex
+	feeds map[string]*FeedStatus
+	// problems counts the validation problems and decode errors of every
+	// feed by reason
+	problems map[[2]string]int
+	// duplicates counts the duplicate ids of every feed
+	duplicates map[string]int
+}
+
+func NewScrapeStatus() *ScrapeStatus {
+	return &ScrapeStatus{feeds: make(map[string]*FeedStatus), problems: make(map[[2]string]int), duplicates: make(map[string]int)}
+}
+
+func (s *ScrapeStatus) Record(feed string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status, ok := s.feeds[feed]
+	if !ok {
+		status = &FeedStatus{Feed: feed}
+		s.feeds[feed] = status
+	}
+	status.LastAttempt = time.Now()
+	status.Attempts++
+	if err != nil {
+		status.Errors++
+		var decodeErr *gbfs.DecodeError
+		if errors.As(err, &decodeErr) {
+			status.ParseErrors++
+			s.problems[[2]string{feed, "decode"}]++
+		}
+		status.LastError = err.Error()
+	} else {
+		status.LastSuccess = status.LastAttempt
+		status.LastError = ""
+	}
+}
+
+// / Record the envelope of a successfully fetched feed.
+func (s *ScrapeStatus) RecordEnvelope(feed string, lastUpdated time.Time, ttl int, version string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if status, ok := s.feeds[feed]; ok {
+		status.LastUpdated, status.TTL, status.Version = lastUpdated, ttl, version
+	}
+}
+
+// / Count the validation problems of a fetched feed, for use as a Client's
+// / OnProblems.
+func (s *ScrapeStatus) RecordProblems(feed string, problems []gbfs.Problem) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, problem := range problems {
+		s.problems[[2]string{feed, problem.Reason}]++
+	}
+}
+
+// / Count the elements of a feed with the same id as an earlier element.
+func (s *ScrapeStatus) RecordDuplicates(feed string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.duplicates[feed] += n
+}
+
+// / Return the time of the last successful fetch of a feed, zero if it never
+// / succeeded.
+func (s *ScrapeStatus) LastSuccess(feed string) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if status, ok := s.feeds[feed]; ok {
+		return status.LastSuccess
+	}
+	return time.Time{}
+}
+
+// / Return a copy of the status of every feed, sorted by feed name.
+func (s *ScrapeStatus) Feeds() []FeedStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	feeds := make([]FeedStatus, 0, len(s.feeds))
+	for _, status := range s.feeds {
+		feeds = append(feeds, *status)
+	}
+	sort.Slice(feeds, func(i, j int) bool { return feeds[i].Feed < feeds[j].Feed })
+	return feeds
+}
+
+var (
+	feedFetchesDesc = prometheus.NewDesc(
+		"baywheels_exporter_feed_fetches_total",
+		"Number of attempts to fetch each GBFS feed.",
+		[]string{"feed"}, nil,
+	)
+	feedErrorsDesc = prometheus.NewDesc(
+		"baywheels_exporter_feed_errors_total",
+		"Number of failed attempts to fetch each GBFS feed.",
+		[]string{"feed"}, nil,
+	)
+	feedLastSuccessDesc = prometheus.NewDesc(
+		"baywheels_exporter_feed_last_success_timestamp_seconds",
+		"Unix time of the last successful fetch of each GBFS feed.",
+		[]string{"feed"}, nil,
+	)
+	feedLastUpdatedDesc = prometheus.NewDesc(
+		"baywheels_exporter_feed_last_updated_timestamp_seconds",
+		"Unix time each GBFS feed reports its data was last updated, from the last successful fetch.",
+		[]string{"feed"}, nil,
+	)
+	feedAgeDesc = prometheus.NewDesc(
+		"baywheels_exporter_feed_age_seconds",
+		"Seconds since each GBFS feed reports its data was last updated.",
+		[]string{"feed"}, nil,
+	)
+	feedTTLDesc = prometheus.NewDesc(
+		"baywheels_exporter_feed_ttl_seconds",
+		"Seconds each GBFS feed reports its data stays valid for.",
+		[]string{"feed"}, nil,
+	)
+	feedVersionDesc = prometheus.NewDesc(
+		"baywheels_exporter_gbfs_version_info",
+		"Always 1, labelled with the GBFS version each feed reports.",
+		[]string{"feed", "version"}, nil,
+	)
+	feedParseErrorsDesc = prometheus.NewDesc(
+		"baywheels_exporter_gbfs_parse_errors_total",
+		"Number of fields of each GBFS feed failing validation, and of fetches failing to decode, by reason.",
+		[]string{"feed", "reason"}, nil,
+	)
+	feedDuplicatesDesc = prometheus.NewDesc(
+		"baywheels_exporter_gbfs_duplicate_ids_total",
+		"Number of stations or bikes of each GBFS feed with the same id as an earlier one, of which the last is used.",
+		[]string{"feed"}, nil,
+	)
+	feedStaleDesc = prometheus.NewDesc(
+		"baywheels_exporter_feed_stale",
+		"1 if the last fetch of the GBFS feed failed, so its metrics are from an earlier fetch or missing.",
+		[]string{"feed"}, nil,
+	)
+)
+
+// / ScrapeStatus is also a prometheus.Collector exposing the fetch counts
+// / and last success of every feed, for alerting on feeds that are down.
+func (s *ScrapeStatus) Describe(ch chan<- *prometheus.Desc) {
+	ch <- feedFetchesDesc
+	ch <- feedErrorsDesc
+	ch <- feedLastSuccessDesc
+	ch <- feedStaleDesc
+	ch <- feedLastUpdatedDesc
+	ch <- feedAgeDesc
+	ch <- feedTTLDesc
+	ch <- feedVersionDesc
+	ch <- feedParseErrorsDesc
+	ch <- feedDuplicatesDesc
+}
+
+func (s *ScrapeStatus) Collect(ch chan<- prometheus.Metric) {
+	for _, status := range s.Feeds() {
+		ch <- prometheus.MustNewConstMetric(feedFetchesDesc, prometheus.CounterValue, float64(status.Attempts), status.Feed)
+		ch <- prometheus.MustNewConstMetric(feedErrorsDesc, prometheus.CounterValue, float64(status.Errors), status.Feed)
+		if !status.LastSuccess.IsZero() {
+			ch <- prometheus.MustNewConstMetric(feedLastSuccessDesc, prometheus.GaugeValue, float64(status.LastSuccess.UnixNano())/1e9, status.Feed)
+		}
+		stale := 0.0
+		if status.LastError != "" {
+			stale = 1
+		}
+		ch <- prometheus.MustNewConstMetric(feedStaleDesc, prometheus.GaugeValue, stale, status.Feed)
+		if !status.LastUpdated.IsZero() {
+			ch <- prometheus.MustNewConstMetric(feedLastUpdatedDesc, prometheus.GaugeValue, float64(status.LastUpdated.Unix()), status.Feed)
+			ch <- prometheus.MustNewConstMetric(feedAgeDesc, prometheus.GaugeValue, time.Since(status.LastUpdated).Seconds(), status.Feed)
+			ch <- prometheus.MustNewConstMetric(feedTTLDesc, prometheus.GaugeValue, float64(status.TTL), status.Feed)
+		}
+		if status.Version != "" {
+			ch <- prometheus.MustNewConstMetric(feedVersionDesc, prometheus.GaugeValue, 1, status.Feed, status.Version)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, count := range s.problems {
+		ch <- prometheus.MustNewConstMetric(feedParseErrorsDesc, prometheus.CounterValue, float64(count), key[0], key[1])
+	}
+	for _, feed := range []string{"station_information", "station_status", "free_bike_status"} {
+		ch <- prometheus.MustNewConstMetric(feedDuplicatesDesc, prometheus.CounterValue, float64(s.duplicates[feed]), feed)
+	}
+}
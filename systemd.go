@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/coreos/go-systemd/v22/daemon"
+)
+
+// / Systemd notifies systemd of the exporter's readiness once the first
+// / cycle has fetched station_status, or found the system closed, for
+// / Type=notify units, and pings the
+// / watchdog of units with WatchdogSec= for as long as cycles keep
+// / completing. A sampling loop wedged for StallAfter past the time its next
+// / cycle was due stops the pings, so systemd restarts the exporter, however
+// / long the sampling interval or feed TTLs make the wait between cycles.
+type Systemd struct {
+	StallAfter time.Duration
+
+	ready     bool
+	lastCycle atomic.Int64
+	wait      atomic.Int64
+}
+
+func NewSystemd(stallAfter time.Duration) *Systemd {
+	s := &Systemd{StallAfter: stallAfter}
+	s.lastCycle.Store(time.Now().UnixNano())
+	return s
+}
+
+// / Observe a completed cycle.
+func (s *Systemd) Observe(cycle *Cycle) {
+	s.lastCycle.Store(time.Now().UnixNano())
+	if s.ready || !cycle.Fetched["station_status"] && !cycle.Closed {
+		return
+	}
+	s.ready = true
+	if _, err := daemon.SdNotify(false, daemon.SdNotifyReady); err != nil {
+		log.Printf("Error notifying systemd %s\n", err)
+	}
+}
+
+// / Record that the sampling loop waits d before its next cycle.
+func (s *Systemd) Wait(d time.Duration) {
+	s.wait.Store(int64(d))
+}
+
+// / Ping the watchdog, if the unit has one, until ctx is cancelled.
+func (s *Systemd) Run(ctx context.Context) {
+	interval, err := daemon.SdWatchdogEnabled(false)
+	if err != nil {
+		log.Printf("Error reading systemd watchdog interval %s\n", err)
+		return
+	}
+	if interval == 0 {
+		return
+	}
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		wait := time.Duration(s.wait.Load())
+		if time.Since(time.Unix(0, s.lastCycle.Load())) > wait+s.StallAfter {
+			continue
+		}
+		if _, err := daemon.SdNotify(false, daemon.SdNotifyWatchdog); err != nil {
+			log.Printf("Error pinging systemd watchdog %s\n", err)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// / Textfile is a Sink writing the metrics of every cycle to a .prom file
+// / for node_exporter's textfile collector, for hosts where the exporter
+// / can't listen itself. The file is written to a temporary file and renamed
+// / into place, so node_exporter never reads a partial file.
+type Textfile struct {
+	path string
+}
+
+func NewTextfile(path string) *Textfile {
+	return &Textfile{path: path}
+}
+
+func (t *Textfile) Name() string {
+	return "textfile"
+}
+
+func (t *Textfile) Send(ctx context.Context, cycle *Cycle) error {
+	return prometheus.WriteToTextfile(t.path, prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		return cycle.Families, nil
+	}))
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/patrickod/baywheels-exporter/pkg/gbfs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// / ANSI escapes of the terminal subcommands.
+const (
+	ansiClear  = "\033[H\033[2J"
+	ansiReset  = "\033[0m"
+	ansiBold   = "\033[1m"
+	ansiRed    = "\033[31m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+	ansiDim    = "\033[2m"
+)
+
+// / Report whether f is a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// / Wrap s in an ANSI colour if color is set.
+func paint(color bool, code, s string) string {
+	if !color || code == "" || s == "" {
+		return s
+	}
+	return code + s + ansiReset
+}
+
+// / Return the colour of a count of bikes or docks available: red when
+// / there are none, yellow when there are few.
+func availabilityColor(n int) string {
+	switch {
+	case n == 0:
+		return ansiRed
+	case n <= 2:
+		return ansiYellow
+	default:
+		return ansiGreen
+	}
+}
+
+// / Pad s with spaces to width runes, truncating it if it is longer.
+func pad(s string, width int) string {
+	n := utf8.RuneCountInString(s)
+	if n > width {
+		return string([]rune(s)[:width-1]) + "…"
+	}
+	return s + strings.Repeat(" ", width-n)
+}
+
+// / Run `top`, a terminal dashboard refreshing a table of stations sorted by
+// / availability every -interval, with the change in bikes available over
+// / -trend, for a glance at the system without a Prometheus or Grafana.
+func runTop(args []string) error {
+	fs := flag.NewFlagSet("top", flag.ExitOnError)
+	gbfsURL := fs.String("gbfs.url", BaywheelsURI, "Base URL of the GBFS feeds, or the URL of the system's gbfs.json")
+	interval := fs.Duration("interval", 30*time.Second, "How often to refresh")
+	trend := fs.Duration("trend", 15*time.Minute, "Window the change in bikes available is shown over")
+	var stations stringsFlag
+	fs.Var(&stations, "station", "Station to show, by station_id or short_name; may be repeated, all stations if not given")
+	rows := fs.Int("n", 30, "Number of stations to show; all if zero")
+	sortBy := fs.String("sort", "bikes", "Column to sort by: bikes, ebikes, docks, trend or name")
+	reverse := fs.Bool("reverse", false, "Sort in descending order")
+	color := fs.Bool("color", isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == "", "Colour the output; the default on terminals unless NO_COLOR is set")
+	var upstream UpstreamAuth
+	upstream.Flags(fs)
+	fs.Parse(args)
+
+	if *interval <= 0 {
+		return fmt.Errorf("-interval must be positive, got %s", *interval)
+	}
+	if _, err := topOrder(*sortBy); err != nil {
+		return err
+	}
+	client, err := upstream.Client(prometheus.NewRegistry())
+	if err != nil {
+		return err
+	}
+	c := gbfs.NewClient(*gbfsURL, client)
+	trends := NewTrends(int(*trend / *interval) + 1)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	var snapshot *Snapshot
+	for {
+		latest, err := fetchSnapshot(ctx, c, false)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err == nil {
+			snapshot = latest
+			trends.Record(snapshot)
+		}
+		if err := renderTop(os.Stdout, snapshot, trends, err, topOptions{
+			url:      *gbfsURL,
+			stations: stations.values,
+			rows:     *rows,
+			sortBy:   *sortBy,
+			reverse:  *reverse,
+			color:    *color,
+			clear:    isTerminal(os.Stdout),
+		}); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(*interval):
+		}
+	}
+}
+
+type topOptions struct {
+	url      string
+	stations []string
+	rows     int
+	sortBy   string
+	reverse  bool
+	color    bool
+	// clear is whether to redraw the screen rather than append the table
+	clear bool
+}
+
+type topRow struct {
+	station Station
+	trend   int
+}
+
+func renderTop(w io.Writer, snapshot *Snapshot, trends *Trends, fetchErr error, opts topOptions) error {
+	var b strings.Builder
+	if opts.clear {
+		b.WriteString(ansiClear)
+	}
+	fmt.Fprintf(&b, "%s  %s\n\n", paint(opts.color, ansiBold, opts.url), time.Now().Format(time.TimeOnly))
+	if snapshot == nil {
+		fmt.Fprintf(&b, "%s\n", paint(opts.color, ansiRed, fmt.Sprintf("Error fetching the feeds %s", fetchErr)))
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+
+	var rows []topRow
+	if len(opts.stations) > 0 {
+		for _, key := range opts.stations {
+			if station, ok := snapshot.Find(key); ok {
+				rows = append(rows, topRow{station: station})
+			}
+		}
+	} else {
+		for _, station := range snapshot.Stations {
+			rows = append(rows, topRow{station: station})
+		}
+	}
+	for i := range rows {
+		points := trends.Points(rows[i].station.StationId)
+		if len(points) > 1 {
+			rows[i].trend = points[len(points)-1].BikesAvailable - points[0].BikesAvailable
+		}
+	}
+	less, err := topOrder(opts.sortBy)
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if opts.reverse {
+			return less(rows[j], rows[i])
+		}
+		return less(rows[i], rows[j])
+	})
+
+	empty, full := 0, 0
+	for _, row := range rows {
+		if status := row.station.Status; status != nil {
+			if status.BikesAvailable == 0 {
+				empty++
+			}
+			if status.DocksAvailable == 0 {
+				full++
+			}
+		}
+	}
+	fmt.Fprintf(&b, "%d stations, %s empty, %s full\n\n", len(rows),
+		paint(opts.color, ansiRed, fmt.Sprint(empty)), paint(opts.color, ansiRed, fmt.Sprint(full)))
+	if opts.rows > 0 && len(rows) > opts.rows {
+		rows = rows[:opts.rows]
+	}
+
+	fmt.Fprintf(&b, "%s\n", paint(opts.color, ansiBold, fmt.Sprintf("%s %s %5s %6s %5s %6s  %s", pad("STATION", 12), pad("NAME", 36), "BIKES", "EBIKES", "DOCKS", "TREND", "STATUS")))
+	for _, row := range rows {
+		station := row.station
+		id := string(station.ShortName)
+		if id == "" {
+			id = station.StationId
+		}
+		status := station.Status
+		if status == nil {
+			fmt.Fprintf(&b, "%s %s %s\n", pad(id, 12), pad(string(station.Name), 36), paint(opts.color, ansiDim, "no status"))
+			continue
+		}
+		trend := ""
+		if row.trend != 0 {
+			trend = fmt.Sprintf("%+d", row.trend)
+		}
+		var state []string
+		if status.IsInstalled == 0 {
+			state = append(state, "not installed")
+		}
+		if status.IsRenting == 0 {
+			state = append(state, "not renting")
+		}
+		if status.IsReturning == 0 {
+			state = append(state, "not returning")
+		}
+		fmt.Fprintf(&b, "%s %s %s %s %s %6s  %s\n",
+			pad(id, 12),
+			pad(string(station.Name), 36),
+			paint(opts.color, availabilityColor(status.BikesAvailable), fmt.Sprintf("%5d", status.BikesAvailable)),
+			paint(opts.color, availabilityColor(status.EBikesAvailable), fmt.Sprintf("%6d", status.EBikesAvailable)),
+			paint(opts.color, availabilityColor(status.DocksAvailable), fmt.Sprintf("%5d", status.DocksAvailable)),
+			trend,
+			paint(opts.color, ansiRed, strings.Join(state, ", ")),
+		)
+	}
+	if fetchErr != nil {
+		fmt.Fprintf(&b, "\n%s\n", paint(opts.color, ansiRed, fmt.Sprintf("Error fetching the feeds %s, showing the last fetched", fetchErr)))
+	}
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// / Return the ordering of rows sorted by column, ascending.
+func topOrder(column string) (func(a, b topRow) bool, error) {
+	value := map[string]func(r topRow) int{
+		"bikes":  func(r topRow) int { return r.station.Status.BikesAvailable },
+		"ebikes": func(r topRow) int { return r.station.Status.EBikesAvailable },
+		"docks":  func(r topRow) int { return r.station.Status.DocksAvailable },
+		"trend":  func(r topRow) int { return r.trend },
+	}
+	if column == "name" {
+		return func(a, b topRow) bool { return a.station.Name < b.station.Name }, nil
+	}
+	f, ok := value[column]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort column %q", column)
+	}
+	return func(a, b topRow) bool {
+		// stations without a status last
+		if a.station.Status == nil || b.station.Status == nil {
+			return a.station.Status != nil && b.station.Status == nil
+		}
+		return f(a) < f(b)
+	}, nil
+}
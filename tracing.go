@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// / tracer starts the spans of sampling cycles and sinks. Until tracing is
+// / configured it is a no-op.
+var tracer = otel.Tracer("github.com/patrickod/baywheels-exporter")
+
+type TracingConfig struct {
+	Endpoint string
+	// Protocol is either "grpc" or "http/protobuf", as in
+	// OTEL_EXPORTER_OTLP_PROTOCOL.
+	Protocol string
+	Insecure bool
+	Headers  map[string]string
+	// SampleRatio is the fraction of cycles traced.
+	SampleRatio float64
+}
+
+// / Export traces of every sampling cycle to an OTLP collector, with a span
+// / per feed fetched and sink, so slow cycles can be attributed to a feed,
+// / the CDN in front of it or a destination. Spans are batched and exported
+// / in the background; the returned provider must be shut down to flush
+// / them.
+func NewTracing(ctx context.Context, config TracingConfig) (*sdktrace.TracerProvider, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch config.Protocol {
+	case "grpc":
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(config.Endpoint), otlptracegrpc.WithHeaders(config.Headers)}
+		if config.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		exporter, err = otlptracegrpc.New(ctx, opts...)
+	case "http/protobuf":
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(config.Endpoint), otlptracehttp.WithHeaders(config.Headers)}
+		if config.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unknown OTLP protocol %q", config.Protocol)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if config.SampleRatio < 0 || config.SampleRatio > 1 {
+		return nil, fmt.Errorf("sample ratio must be between 0 and 1, got %g", config.SampleRatio)
+	}
+
+	res, err := otelResource()
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider, nil
+}
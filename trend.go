@@ -0,0 +1,76 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// / TrendPoint is a station's availability at one sampling cycle.
+type TrendPoint struct {
+	Time            time.Time `json:"time"`
+	BikesAvailable  int       `json:"num_bikes_available"`
+	EBikesAvailable int       `json:"num_ebikes_available"`
+	DocksAvailable  int       `json:"num_docks_available"`
+}
+
+// / Trends keeps the most recent availability of every station in fixed size
+// / ring buffers, so short term trends can be shown without a metrics
+// / backend.
+type Trends struct {
+	mu       sync.Mutex
+	size     int
+	stations map[string]*trendRing
+}
+
+type trendRing struct {
+	points []TrendPoint
+	next   int
+}
+
+func NewTrends(size int) *Trends {
+	return &Trends{size: size, stations: make(map[string]*trendRing)}
+}
+
+// / Record the availability of every station in snapshot.
+func (t *Trends) Record(snapshot *Snapshot) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, station := range snapshot.Stations {
+		if station.Status == nil {
+			continue
+		}
+		ring, ok := t.stations[station.StationId]
+		if !ok {
+			ring = &trendRing{points: make([]TrendPoint, 0, t.size)}
+			t.stations[station.StationId] = ring
+		}
+		point := TrendPoint{
+			Time:            snapshot.Time,
+			BikesAvailable:  station.Status.BikesAvailable,
+			EBikesAvailable: station.Status.EBikesAvailable,
+			DocksAvailable:  station.Status.DocksAvailable,
+		}
+		if len(ring.points) < t.size {
+			ring.points = append(ring.points, point)
+		} else {
+			ring.points[ring.next] = point
+		}
+		ring.next = (ring.next + 1) % t.size
+	}
+}
+
+// / Return a copy of a station's points, oldest first.
+func (t *Trends) Points(id string) []TrendPoint {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ring, ok := t.stations[id]
+	if !ok {
+		return nil
+	}
+	if len(ring.points) < t.size {
+		return append([]TrendPoint(nil), ring.points...)
+	}
+	return append(append([]TrendPoint(nil), ring.points[ring.next:]...), ring.points[:ring.next]...)
+}
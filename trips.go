@@ -0,0 +1,211 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// / tripFlow is the number of bikes that left and arrived at a station in
+// / one sample, and the number of its docks that were freed or filled.
+type tripFlow struct {
+	time              time.Time
+	departed, arrived int
+	docks             int
+}
+
+// / tripRate is the outflow and inflow of a station in bikes per hour.
+type tripRate struct {
+	outflow, inflow float64
+}
+
+// / TripMetrics estimates station usage from the changes in bikes available
+// / between consecutive samples. Only the net change per sample is visible,
+// / so a bike rented and another returned within the same interval cancel
+// / out and the counters are a lower bound of the actual trips.
+// /
+// / Changes of at least RebalancingThreshold bikes in a single sample are
+// / implausible for riders and counted as rebalancing by the operator
+// / instead of as trips.
+type TripMetrics struct {
+	// Window is the sliding window the flux rates are averaged over.
+	Window time.Duration
+	// RebalancingThreshold is the change in bikes available within one
+	// sample that is considered rebalancing; disabled if zero.
+	RebalancingThreshold int
+
+	station_bikes_departed_total prometheus.CounterVec
+	station_bikes_arrived_total  prometheus.CounterVec
+	station_outflow_rate         prometheus.GaugeVec
+	station_inflow_rate          prometheus.GaugeVec
+	station_dock_changes_total   prometheus.CounterVec
+	station_dock_turnover_rate   prometheus.GaugeVec
+	station_rebalancing_events   prometheus.CounterVec
+	station_last_rebalancing     prometheus.GaugeVec
+
+	start time.Time
+	flows map[string][]tripFlow
+	// rates of each station as of the last Observe
+	rates map[string]tripRate
+}
+
+func NewTripMetrics(reg prometheus.Registerer) *TripMetrics {
+	m := &TripMetrics{
+		Window:               time.Hour,
+		RebalancingThreshold: 8,
+		station_bikes_departed_total: *prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "station_bikes_departed_total",
+			Help: "Estimated number of bikes checked out of the station",
+		},
+			[]string{"station_id", "name"},
+		),
+		station_bikes_arrived_total: *prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "station_bikes_arrived_total",
+			Help: "Estimated number of bikes returned to the station",
+		},
+			[]string{"station_id", "name"},
+		),
+		station_outflow_rate: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "station_outflow_bikes_per_hour",
+			Help: "Estimated bikes checked out of the station per hour, averaged over the flux window",
+		},
+			[]string{"station_id", "name"},
+		),
+		station_inflow_rate: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "station_inflow_bikes_per_hour",
+			Help: "Estimated bikes returned to the station per hour, averaged over the flux window",
+		},
+			[]string{"station_id", "name"},
+		),
+		station_dock_changes_total: *prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "station_dock_changes_total",
+			Help: "Estimated number of times a dock of the station was freed or filled",
+		},
+			[]string{"station_id", "name"},
+		),
+		station_dock_turnover_rate: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "station_dock_turnover_per_hour",
+			Help: "Estimated dock changes per dock of the station per hour, averaged over the flux window",
+		},
+			[]string{"station_id", "name"},
+		),
+		station_rebalancing_events: *prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "station_rebalancing_events_total",
+			Help: "Number of rebalancings of the station, by direction (dropoff or pickup)",
+		},
+			[]string{"station_id", "name", "direction"},
+		),
+		station_last_rebalancing: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "station_last_rebalancing_bikes",
+			Help: "Change in bikes available of the station's most recent rebalancing, negative for pickups",
+		},
+			[]string{"station_id", "name"},
+		),
+		flows: make(map[string][]tripFlow),
+		rates: make(map[string]tripRate),
+	}
+	reg.MustRegister(m.station_bikes_departed_total)
+	reg.MustRegister(m.station_bikes_arrived_total)
+	reg.MustRegister(m.station_outflow_rate)
+	reg.MustRegister(m.station_inflow_rate)
+	reg.MustRegister(m.station_dock_changes_total)
+	reg.MustRegister(m.station_dock_turnover_rate)
+	reg.MustRegister(m.station_rebalancing_events)
+	reg.MustRegister(m.station_last_rebalancing)
+
+	return m
+}
+
+// / Count the bikes that left or arrived at each station in changes and
+// / update the flux rates of every station in snapshot. Stations appearing
+// / or disappearing from station_status have nothing to compare against and
+// / are skipped.
+func (m *TripMetrics) Observe(snapshot *Snapshot, changes []StationChange) {
+	if m.start.IsZero() {
+		m.start = snapshot.Time
+	}
+
+	for _, change := range changes {
+		if change.Previous == nil || change.Current == nil {
+			continue
+		}
+		labels := stationLabels(change.StationId, change.Name)
+
+		// docks change state with rebalancing as much as with trips
+		docks := change.Current.DocksAvailable - change.Previous.DocksAvailable
+		flow := tripFlow{time: snapshot.Time, docks: max(docks, -docks)}
+		if flow.docks > 0 {
+			m.station_dock_changes_total.With(labels).Add(float64(flow.docks))
+		}
+
+		delta := change.Current.BikesAvailable - change.Previous.BikesAvailable
+		switch {
+		case m.RebalancingThreshold > 0 && max(delta, -delta) >= m.RebalancingThreshold:
+			direction := "dropoff"
+			if delta < 0 {
+				direction = "pickup"
+			}
+			m.station_rebalancing_events.With(prometheus.Labels{
+				"station_id": labels["station_id"],
+				"name":       labels["name"],
+				"direction":  direction,
+			}).Inc()
+			m.station_last_rebalancing.With(labels).Set(float64(delta))
+		case delta < 0:
+			flow.departed = -delta
+			m.station_bikes_departed_total.With(labels).Add(float64(flow.departed))
+		case delta > 0:
+			flow.arrived = delta
+			m.station_bikes_arrived_total.With(labels).Add(float64(flow.arrived))
+		}
+		if flow.departed == 0 && flow.arrived == 0 && flow.docks == 0 {
+			continue
+		}
+		m.flows[change.StationId] = append(m.flows[change.StationId], flow)
+	}
+
+	// until the exporter has been running for a full window the rates are
+	// averaged over the time it has been running
+	window := min(m.Window, snapshot.Time.Sub(m.start))
+	cutoff := snapshot.Time.Add(-m.Window)
+	clear(m.rates)
+	for _, station := range snapshot.Stations {
+		if station.Status == nil {
+			continue
+		}
+		flows := m.flows[station.StationId]
+		for len(flows) > 0 && !flows[0].time.After(cutoff) {
+			flows = flows[1:]
+		}
+		if len(flows) == 0 {
+			delete(m.flows, station.StationId)
+		} else {
+			m.flows[station.StationId] = flows
+		}
+
+		var departed, arrived, docks int
+		for _, flow := range flows {
+			departed += flow.departed
+			arrived += flow.arrived
+			docks += flow.docks
+		}
+		// stations only known from station_status have no capacity
+		capacity := station.Capacity
+		if capacity == 0 {
+			capacity = station.Status.BikesAvailable + station.Status.BikesDisabled + station.Status.DocksAvailable + station.Status.DocksDisabled
+		}
+		var outflow, inflow, turnover float64
+		if window > 0 {
+			outflow = float64(departed) / window.Hours()
+			inflow = float64(arrived) / window.Hours()
+			if capacity > 0 {
+				turnover = float64(docks) / float64(capacity) / window.Hours()
+			}
+		}
+		m.rates[station.StationId] = tripRate{outflow: outflow, inflow: inflow}
+		labels := stationLabels(station.StationId, string(station.Name))
+		m.station_outflow_rate.With(labels).Set(outflow)
+		m.station_inflow_rate.With(labels).Set(inflow)
+		m.station_dock_turnover_rate.With(labels).Set(turnover)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/patrickod/baywheels-exporter/pkg/gbfs"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// / UpstreamAuth configures how the GBFS feeds are fetched for providers,
+// / such as partner endpoints, that require an API key or sit behind an
+// / OAuth2 gateway. Secrets are read from files, e.g. mounted secrets, or
+// / the environment rather than given as flags, so they don't show up in
+// / the process arguments.
+type UpstreamAuth struct {
+	APIKeyHeader string
+	APIKeyQuery  string
+	APIKeyFile   string
+
+	// TokenURL enables the OAuth2 client credentials flow.
+	TokenURL         string
+	ClientID         string
+	ClientSecretFile string
+	Scopes           string
+	// Params are extra parameters of token requests, e.g. audience.
+	Params map[string]string
+}
+
+func (a *UpstreamAuth) Flags(fs *flag.FlagSet) {
+	fs.StringVar(&a.APIKeyHeader, "gbfs.api-key-header", "", "Header to send the GBFS API key in, e.g. Authorization or X-Api-Key")
+	fs.StringVar(&a.APIKeyQuery, "gbfs.api-key-query", "", "Query parameter to send the GBFS API key in, e.g. key")
+	fs.StringVar(&a.APIKeyFile, "gbfs.api-key-file", "", "File to read the GBFS API key from; GBFS_API_KEY if unset")
+	fs.StringVar(&a.TokenURL, "gbfs.oauth2.token-url", "", "OAuth2 token endpoint to get access tokens for the GBFS feeds from with the client credentials flow")
+	fs.StringVar(&a.ClientID, "gbfs.oauth2.client-id", "", "OAuth2 client ID")
+	fs.StringVar(&a.ClientSecretFile, "gbfs.oauth2.client-secret-file", "", "File to read the OAuth2 client secret from; GBFS_OAUTH2_CLIENT_SECRET if unset")
+	fs.StringVar(&a.Scopes, "gbfs.oauth2.scopes", "", "Comma separated OAuth2 scopes to request")
+	a.Params = labelsFlag{}
+	fs.Var(labelsFlag(a.Params), "gbfs.oauth2.param", "Extra parameter of OAuth2 token requests as name=value, e.g. audience=...; may be repeated")
+}
+
+// / Return the http.Client to fetch the feeds with, or nil for the default
+// / one if they are public.
+func (a *UpstreamAuth) Client(reg prometheus.Registerer) (*http.Client, error) {
+	if a.APIKeyHeader == "" && a.APIKeyQuery == "" && a.TokenURL == "" {
+		return nil, nil
+	}
+	var transport http.RoundTripper = http.DefaultTransport
+	if a.APIKeyHeader != "" || a.APIKeyQuery != "" {
+		key, err := readSecret(a.APIKeyFile, "GBFS_API_KEY")
+		if err != nil {
+			return nil, err
+		}
+		if key == "" {
+			return nil, errors.New("no API key, set -gbfs.api-key-file or GBFS_API_KEY")
+		}
+		transport = &gbfs.APIKey{Key: key, Header: a.APIKeyHeader, Query: a.APIKeyQuery}
+	}
+	if a.TokenURL != "" {
+		secret, err := readSecret(a.ClientSecretFile, "GBFS_OAUTH2_CLIENT_SECRET")
+		if err != nil {
+			return nil, err
+		}
+		config := &clientcredentials.Config{
+			ClientID:       a.ClientID,
+			ClientSecret:   secret,
+			TokenURL:       a.TokenURL,
+			EndpointParams: url.Values{},
+		}
+		if a.Scopes != "" {
+			config.Scopes = strings.Split(a.Scopes, ",")
+		}
+		for name, value := range a.Params {
+			config.EndpointParams.Set(name, value)
+		}
+		transport = &oauth2.Transport{
+			Source: oauth2.ReuseTokenSource(nil, newTokenSource(config, reg)),
+			Base:   transport,
+		}
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// / Return the secret in file, or if file is empty the environment variable
+// / env.
+func readSecret(file, env string) (string, error) {
+	if file == "" {
+		return os.Getenv(env), nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// / tokenSource fetches a new OAuth2 access token every time it is asked
+// / for one, counting the fetches and failures; wrapped in a
+// / ReuseTokenSource it is only asked once the cached token expires.
+type tokenSource struct {
+	config *clientcredentials.Config
+	ctx    context.Context
+
+	token_fetches prometheus.Counter
+	token_errors  prometheus.Counter
+	token_expiry  prometheus.Gauge
+}
+
+func newTokenSource(config *clientcredentials.Config, reg prometheus.Registerer) *tokenSource {
+	s := &tokenSource{
+		config: config,
+		// token requests would otherwise never time out
+		ctx: context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Timeout: 30 * time.Second}),
+		token_fetches: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "baywheels_exporter_oauth2_token_fetches_total",
+			Help: "Number of OAuth2 access tokens fetched for the GBFS feeds",
+		}),
+		token_errors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "baywheels_exporter_oauth2_token_errors_total",
+			Help: "Number of failed OAuth2 access token requests for the GBFS feeds",
+		}),
+		token_expiry: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "baywheels_exporter_oauth2_token_expiry_timestamp_seconds",
+			Help: "Unix time the current OAuth2 access token for the GBFS feeds expires, 0 if it doesn't",
+		}),
+	}
+	reg.MustRegister(s.token_fetches)
+	reg.MustRegister(s.token_errors)
+	reg.MustRegister(s.token_expiry)
+
+	return s
+}
+
+func (s *tokenSource) Token() (*oauth2.Token, error) {
+	token, err := s.config.Token(s.ctx)
+	if err != nil {
+		s.token_errors.Inc()
+		return nil, err
+	}
+	s.token_fetches.Inc()
+	if token.Expiry.IsZero() {
+		s.token_expiry.Set(0)
+	} else {
+		s.token_expiry.Set(float64(token.Expiry.Unix()))
+	}
+	return token, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"github.com/patrickod/baywheels-exporter/pkg/gbfs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// / vehicleTypeKey identifies a system_vehicles_available series.
+type vehicleTypeKey struct {
+	id, formFactor, propulsion, location string
+}
+
+// / VehicleTypeMetrics exports system wide totals of the available vehicles
+// / per vehicle type, joining vehicle_types with the vehicle types available
+// / at each station and those of the free bikes.
+type VehicleTypeMetrics struct {
+	system_vehicles_available prometheus.GaugeVec
+
+	keys map[vehicleTypeKey]bool
+}
+
+func NewVehicleTypeMetrics(reg prometheus.Registerer) *VehicleTypeMetrics {
+	m := &VehicleTypeMetrics{
+		system_vehicles_available: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "system_vehicles_available",
+			Help: "Number of vehicles available across the system, by vehicle type and whether they are docked or free floating",
+		},
+			[]string{"vehicle_type_id", "form_factor", "propulsion_type", "location"},
+		),
+		keys: make(map[vehicleTypeKey]bool),
+	}
+	reg.MustRegister(m.system_vehicles_available)
+
+	return m
+}
+
+// / Sum the vehicles available in snapshot by type. Stations of systems that
+// / don't break their availability down by vehicle type are counted as
+// / classic and electric bicycles from num_bikes_available and
+// / num_ebikes_available, without a vehicle_type_id.
+func (m *VehicleTypeMetrics) Observe(snapshot *Snapshot, vehicleTypes []gbfs.VehicleType) {
+	types := make(map[string]gbfs.VehicleType, len(vehicleTypes))
+	for _, vt := range vehicleTypes {
+		types[vt.VehicleTypeId] = vt
+	}
+	key := func(id, location string) vehicleTypeKey {
+		vt := types[id]
+		return vehicleTypeKey{id: id, formFactor: vt.FormFactor, propulsion: vt.PropulsionType, location: location}
+	}
+
+	counts := make(map[vehicleTypeKey]int)
+	for _, station := range snapshot.Stations {
+		status := station.Status
+		if status == nil {
+			continue
+		}
+		if len(status.VehicleTypesAvailable) == 0 {
+			counts[vehicleTypeKey{formFactor: "bicycle", propulsion: "human", location: "docked"}] += status.BikesAvailable - status.EBikesAvailable
+			counts[vehicleTypeKey{formFactor: "bicycle", propulsion: "electric_assist", location: "docked"}] += status.EBikesAvailable
+			continue
+		}
+		for _, available := range status.VehicleTypesAvailable {
+			counts[key(available.VehicleTypeId, "docked")] += available.Count
+		}
+	}
+	for _, bike := range snapshot.Bikes {
+		if bike.IsDisabled == 0 && bike.IsReserved == 0 {
+			counts[key(bike.VehicleTypeId, "free_floating")]++
+		}
+	}
+
+	for k, count := range counts {
+		m.system_vehicles_available.WithLabelValues(k.id, k.formFactor, k.propulsion, k.location).Set(float64(count))
+	}
+	// drop the types that are no longer seen
+	for k := range m.keys {
+		if _, ok := counts[k]; !ok {
+			m.system_vehicles_available.DeleteLabelValues(k.id, k.formFactor, k.propulsion, k.location)
+			delete(m.keys, k)
+		}
+	}
+	for k := range counts {
+		m.keys[k] = true
+	}
+}
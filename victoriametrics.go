@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type VictoriaMetricsConfig struct {
+	// URL of a single-node server, e.g. http://localhost:8428, or of a
+	// cluster tenant, e.g. http://vminsert:8480/insert/0/prometheus.
+	URL string
+	// Format is either "json" for /api/v1/import or "influx" for the line
+	// protocol at /influx/write.
+	Format      string
+	Username    string
+	Password    string
+	BearerToken string
+	// Labels are added to every series with VictoriaMetrics' extra_label.
+	Labels map[string]string
+}
+
+// / VictoriaMetrics is a Sink importing each cycle's samples straight into
+// / VictoriaMetrics, for single-node setups without a remote_write
+// / intermediary.
+type VictoriaMetrics struct {
+	config VictoriaMetricsConfig
+	client *http.Client
+}
+
+func NewVictoriaMetrics(config VictoriaMetricsConfig) (*VictoriaMetrics, error) {
+	if config.Format != "json" && config.Format != "influx" {
+		return nil, fmt.Errorf("format must be json or influx, got %q", config.Format)
+	}
+	return &VictoriaMetrics{config: config, client: &http.Client{Timeout: 30 * time.Second}}, nil
+}
+
+func (v *VictoriaMetrics) Name() string {
+	return "victoriametrics"
+}
+
+// / vmSeries is a line of the /api/v1/import JSON format.
+type vmSeries struct {
+	Metric     map[string]string `json:"metric"`
+	Values     []float64         `json:"values"`
+	Timestamps []int64           `json:"timestamps"`
+}
+
+func (v *VictoriaMetrics) Send(ctx context.Context, cycle *Cycle) error {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, sample := range flattenFamilies(cycle.Families, cycle.Time) {
+		// neither format can represent NaN or infinite values
+		if isExporterMetric(sample.Name) || math.IsNaN(sample.Value) || math.IsInf(sample.Value, 0) {
+			continue
+		}
+		if v.config.Format == "influx" {
+			writeLine(&body, sample)
+			continue
+		}
+		metric := make(map[string]string, len(sample.Labels)+1)
+		metric["__name__"] = sample.Name
+		for _, l := range sample.Labels {
+			metric[l.Name] = l.Value
+		}
+		if err := enc.Encode(vmSeries{
+			Metric:     metric,
+			Values:     []float64{sample.Value},
+			Timestamps: []int64{sample.Timestamp.UnixMilli()},
+		}); err != nil {
+			return err
+		}
+	}
+
+	query := url.Values{}
+	for name, value := range v.config.Labels {
+		query.Add("extra_label", name+"="+value)
+	}
+	path := "/api/v1/import"
+	if v.config.Format == "influx" {
+		path = "/influx/write"
+		query.Set("precision", "s")
+	}
+	endpoint := strings.TrimRight(v.config.URL, "/") + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if v.config.Format == "influx" {
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	if v.config.Username != "" {
+		req.SetBasicAuth(v.config.Username, v.config.Password)
+	} else if v.config.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+v.config.BearerToken)
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("server returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/patrickod/baywheels-exporter/pkg/gbfs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// / Run `watch <station_id|short_name>`, polling a station every -interval
+// / and printing a line whenever its availability changes, and with -notify
+// / a desktop notification when -metric drops below -below, as a Slack or
+// / Discord notifier's watch would post.
+func runWatch(args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s watch [flags] <station_id|short_name>\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	gbfsURL := fs.String("gbfs.url", BaywheelsURI, "Base URL of the GBFS feeds, or the URL of the system's gbfs.json")
+	interval := fs.Duration("interval", 30*time.Second, "How often to poll the station")
+	metric := fs.String("metric", "bikes", "Count the threshold is on: bikes, ebikes or docks")
+	below := fs.Int("below", 1, "Notify when -metric drops below this")
+	notify := fs.Bool("notify", false, "Show a desktop notification when -metric drops below -below")
+	cooldown := fs.Duration("cooldown", 0, "Minimum time between two notifications")
+	color := fs.Bool("color", isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == "", "Colour the output; the default on terminals unless NO_COLOR is set")
+	var upstream UpstreamAuth
+	upstream.Flags(fs)
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected a single station, got %d arguments", fs.NArg())
+	}
+	if _, ok := notifierMetrics[*metric]; !ok {
+		return fmt.Errorf("unknown metric %q", *metric)
+	}
+	if *interval <= 0 {
+		return fmt.Errorf("-interval must be positive, got %s", *interval)
+	}
+	client, err := upstream.Client(prometheus.NewRegistry())
+	if err != nil {
+		return err
+	}
+	c := gbfs.NewClient(*gbfsURL, client)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	snapshot, err := fetchSnapshot(ctx, c, false)
+	if err != nil {
+		return err
+	}
+	station, ok := snapshot.Find(fs.Arg(0))
+	if !ok {
+		return fmt.Errorf("unknown station %q", fs.Arg(0))
+	}
+	// the threshold is tracked as by a notifier, so both fire alike
+	nt := &notifier{
+		config:  NotifierConfig{Cooldown: *cooldown},
+		watches: []*watch{{config: WatchConfig{Station: station.StationId, Metric: *metric, Below: *below}}},
+	}
+	nt.observe(snapshot)
+	fmt.Printf("%s %s %s: %s\n", snapshot.Time.Format(time.TimeOnly), stationLabel(station), station.Name, describeStatus(station.Status))
+
+	prev := station.Status
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(*interval):
+		}
+		snapshot, err := fetchSnapshot(ctx, c, false)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			log.Printf("Error fetching the feeds %s\n", err)
+			continue
+		}
+		current, ok := snapshot.Station(station.StationId)
+		if !ok || current.Status == nil {
+			if prev != nil {
+				fmt.Printf("%s %s is no longer listed\n", snapshot.Time.Format(time.TimeOnly), stationLabel(station))
+			}
+			prev = nil
+			continue
+		}
+		if changes := statusChanges(prev, current.Status); changes != "" {
+			fmt.Printf("%s %s\n", snapshot.Time.Format(time.TimeOnly), changes)
+		}
+		prev = current.Status
+
+		for _, msg := range nt.observe(snapshot) {
+			fmt.Printf("%s %s\n", snapshot.Time.Format(time.TimeOnly), paint(*color, ansiRed+ansiBold, msg))
+			if *notify {
+				if err := desktopNotification("Bay Wheels", msg); err != nil {
+					log.Printf("Error showing notification %s\n", err)
+				}
+			}
+		}
+	}
+}
+
+// / Return the short_name of a station, or its station_id if it has none.
+func stationLabel(station Station) string {
+	if station.ShortName != "" {
+		return string(station.ShortName)
+	}
+	return station.StationId
+}
+
+func describeStatus(status *gbfs.StationStatus) string {
+	if status == nil {
+		return "no status"
+	}
+	s := fmt.Sprintf("%d bikes, %d ebikes, %d docks", status.BikesAvailable, status.EBikesAvailable, status.DocksAvailable)
+	if status.IsRenting == 0 {
+		s += ", not renting"
+	}
+	if status.IsReturning == 0 {
+		s += ", not returning"
+	}
+	return s
+}
+
+// / Describe how a station's availability changed, e.g. "bikes 3 → 2 (-1)",
+// / or return "" if it didn't.
+func statusChanges(prev, cur *gbfs.StationStatus) string {
+	if prev == nil {
+		return describeStatus(cur)
+	}
+	var changes []string
+	for _, name := range []string{"bikes", "ebikes", "docks"} {
+		value := notifierMetrics[name].value
+		if before, after := value(prev), value(cur); before != after {
+			changes = append(changes, fmt.Sprintf("%s %d → %d (%+d)", name, before, after, after-before))
+		}
+	}
+	if prev.IsRenting != cur.IsRenting {
+		if cur.IsRenting == 0 {
+			changes = append(changes, "stopped renting")
+		} else {
+			changes = append(changes, "resumed renting")
+		}
+	}
+	if prev.IsReturning != cur.IsReturning {
+		if cur.IsReturning == 0 {
+			changes = append(changes, "stopped returning")
+		} else {
+			changes = append(changes, "resumed returning")
+		}
+	}
+	return strings.Join(changes, ", ")
+}
+
+// / Show a desktop notification with notify-send on Linux and the BSDs or
+// / osascript on macOS.
+func desktopNotification(title, msg string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %q with title %q", msg, title)
+		cmd = exec.Command("osascript", "-e", script)
+	case "windows":
+		return fmt.Errorf("desktop notifications aren't supported on windows")
+	default:
+		cmd = exec.Command("notify-send", title, msg)
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil && len(out) > 0 {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return err
+}
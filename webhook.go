@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/patrickod/baywheels-exporter/pkg/gbfs"
+)
+
+// / webhookConditions are the station states a webhook can be notified of,
+// / both when a station enters and when it leaves them.
+var webhookConditions = map[string]func(*gbfs.StationStatus) bool{
+	"empty":       func(s *gbfs.StationStatus) bool { return s.BikesAvailable == 0 },
+	"full":        func(s *gbfs.StationStatus) bool { return s.DocksAvailable == 0 },
+	"not_renting": func(s *gbfs.StationStatus) bool { return s.IsRenting == 0 },
+}
+
+type WebhookConfig struct {
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+	// Stations to notify about; all stations if empty.
+	Stations []string `yaml:"stations"`
+	// Events are the webhookConditions to notify about; all if empty.
+	Events []string `yaml:"events"`
+	// Debounce is how long a station must stay in or out of a state before
+	// the change is notified, so stations flapping around a threshold don't
+	// flood the endpoint.
+	Debounce time.Duration `yaml:"debounce"`
+}
+
+// / WebhookEvent is the JSON body POSTed when a station enters (Active) or
+// / leaves (!Active) one of the webhookConditions.
+type WebhookEvent struct {
+	Time      time.Time           `json:"time"`
+	Event     string              `json:"event"`
+	Active    bool                `json:"active"`
+	StationId string              `json:"station_id"`
+	Name      string              `json:"name"`
+	Status    *gbfs.StationStatus `json:"status"`
+}
+
+// / debouncer tracks whether a condition is considered active and since when
+// / it has been observed otherwise.
+type debouncer struct {
+	active  bool
+	pending time.Time
+}
+
+// / Observe the condition at t, reporting whether its debounced state has
+// / changed. The first observation only establishes the state, so a restart
+// / doesn't notify every station that is already empty.
+func (d *debouncer) observe(observed bool, t time.Time, delay time.Duration, first bool) bool {
+	if first || observed == d.active {
+		d.active = observed
+		d.pending = time.Time{}
+		return false
+	}
+	if d.pending.IsZero() {
+		d.pending = t
+	}
+	if t.Sub(d.pending) < delay {
+		return false
+	}
+	d.active = observed
+	d.pending = time.Time{}
+	return true
+}
+
+type webhook struct {
+	config WebhookConfig
+	events []string
+	state  map[string]*debouncer
+}
+
+// / Webhooks is a Sink POSTing a WebhookEvent to each configured URL when a
+// / station crosses one of the webhookConditions.
+type Webhooks struct {
+	// Leading, if set, reports whether this replica is the leader. Standby
+	// replicas keep observing every cycle but don't post, so one promoted
+	// to leader fires the transitions of the current state rather than of
+	// the state it last saw.
+	Leading func() bool
+
+	hooks  []*webhook
+	client *http.Client
+}
+
+func NewWebhooks(configs []WebhookConfig) (*Webhooks, error) {
+	w := &Webhooks{client: &http.Client{}}
+	for _, config := range configs {
+		if config.URL == "" {
+			return nil, errors.New("webhook without a url")
+		}
+		events := config.Events
+		if len(events) == 0 {
+			for event := range webhookConditions {
+				events = append(events, event)
+			}
+			slices.Sort(events)
+		}
+		for _, event := range events {
+			if webhookConditions[event] == nil {
+				return nil, fmt.Errorf("unknown webhook event %q", event)
+			}
+		}
+		w.hooks = append(w.hooks, &webhook{config: config, events: events, state: make(map[string]*debouncer)})
+	}
+	return w, nil
+}
+
+func (w *Webhooks) Name() string {
+	return "webhook"
+}
+
+func (w *Webhooks) Send(ctx context.Context, cycle *Cycle) error {
+	var errs []error
+	for _, hook := range w.hooks {
+		events := hook.observe(cycle.Snapshot)
+		if w.Leading != nil && !w.Leading() {
+			continue
+		}
+		for _, event := range events {
+			if err := w.post(ctx, hook.config, event); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", hook.config.URL, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h *webhook) observe(snapshot *Snapshot) []WebhookEvent {
+	var events []WebhookEvent
+	for _, station := range snapshot.Stations {
+		if station.Status == nil {
+			continue
+		}
+		if len(h.config.Stations) > 0 && !slices.Contains(h.config.Stations, station.StationId) {
+			continue
+		}
+		for _, event := range h.events {
+			key := station.StationId + "/" + event
+			d, seen := h.state[key]
+			if !seen {
+				d = &debouncer{}
+				h.state[key] = d
+			}
+			if d.observe(webhookConditions[event](station.Status), snapshot.Time, h.config.Debounce, !seen) {
+				events = append(events, WebhookEvent{
+					Time:      snapshot.Time,
+					Event:     event,
+					Active:    d.active,
+					StationId: station.StationId,
+					Name:      string(station.Name),
+					Status:    station.Status,
+				})
+			}
+		}
+	}
+	return events
+}
+
+func (w *Webhooks) post(ctx context.Context, config WebhookConfig, event WebhookEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, w.client, config.URL, config.Headers, body)
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "baywheels-exporter")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsWriteTimeout = 10 * time.Second
+	wsPingInterval = 30 * time.Second
+)
+
+var upgrader = websocket.Upgrader{
+	// the stream is read-only public data, so any origin may subscribe
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// / Stream station changes to a WebSocket client as JSON messages, one per
+// / changed station. Clients can limit the stream to particular stations by
+// / repeating the station_id query parameter.
+func handleWebSocket(feed *ChangeFeed) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filter := stationFilter(r)
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// Upgrade has already replied to the client
+			return
+		}
+		defer conn.Close()
+
+		changes, unsubscribe := feed.Subscribe()
+		defer unsubscribe()
+
+		// we never expect messages from the client, but have to read to
+		// process control frames and notice when it goes away
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			for {
+				if _, _, err := conn.NextReader(); err != nil {
+					return
+				}
+			}
+		}()
+
+		ping := time.NewTicker(wsPingInterval)
+		defer ping.Stop()
+		for {
+			select {
+			case <-closed:
+				return
+			case <-ping.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+					return
+				}
+			case change := <-changes:
+				if !filter(change.StationId) {
+					continue
+				}
+				conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+				if err := conn.WriteJSON(change); err != nil {
+					log.Printf("Error writing to WebSocket client %s\n", err)
+					return
+				}
+			}
+		}
+	})
+}
+
+// / Build a predicate from the station_id query parameters of r, matching
+// / every station if none are given.
+func stationFilter(r *http.Request) func(string) bool {
+	ids := r.URL.Query()["station_id"]
+	if len(ids) == 0 {
+		return func(string) bool { return true }
+	}
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	return func(id string) bool { return wanted[id] }
+}
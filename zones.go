@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// / Zone is a named area stations and bikes are aggregated into, made of one
+// / or more polygons. The first ring of each polygon is its outline and any
+// / further rings are holes, with positions as longitude, latitude like
+// / GeoJSON.
+type Zone struct {
+	Name     string
+	Polygons [][][][2]float64
+}
+
+// / Load the zones from a GeoJSON FeatureCollection of Polygon and
+// / MultiPolygon features, named by their nameProperty property.
+func LoadZones(path, nameProperty string) ([]Zone, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fc struct {
+		Features []struct {
+			Geometry struct {
+				Type        string          `json:"type"`
+				Coordinates json.RawMessage `json:"coordinates"`
+			} `json:"geometry"`
+			Properties map[string]any `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(data, &fc); err != nil {
+		return nil, err
+	}
+
+	var zones []Zone
+	for i, feature := range fc.Features {
+		name, ok := feature.Properties[nameProperty].(string)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("feature %d has no %q property", i, nameProperty)
+		}
+		zone := Zone{Name: name}
+		switch feature.Geometry.Type {
+		case "Polygon":
+			var polygon [][][2]float64
+			if err := json.Unmarshal(feature.Geometry.Coordinates, &polygon); err != nil {
+				return nil, fmt.Errorf("zone %s: %w", name, err)
+			}
+			zone.Polygons = [][][][2]float64{polygon}
+		case "MultiPolygon":
+			if err := json.Unmarshal(feature.Geometry.Coordinates, &zone.Polygons); err != nil {
+				return nil, fmt.Errorf("zone %s: %w", name, err)
+			}
+		default:
+			return nil, fmt.Errorf("zone %s: unsupported geometry %q", name, feature.Geometry.Type)
+		}
+		zones = append(zones, zone)
+	}
+	return zones, nil
+}
+
+// / Report whether a coordinate lies within the zone.
+func (z *Zone) Contains(lat, lon float64) bool {
+	for _, polygon := range z.Polygons {
+		if len(polygon) == 0 || !ringContains(polygon[0], lat, lon) {
+			continue
+		}
+		inHole := false
+		for _, hole := range polygon[1:] {
+			if ringContains(hole, lat, lon) {
+				inHole = true
+				break
+			}
+		}
+		if !inHole {
+			return true
+		}
+	}
+	return false
+}
+
+// / Ray casting point in polygon test of a single ring.
+func ringContains(ring [][2]float64, lat, lon float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+		if (yi > lat) != (yj > lat) && lon < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// / ZoneMetrics aggregates the availability of the stations and free bikes
+// / within each of Zones, so city-level dashboards don't need a series per
+// / station. Zones may overlap, in which case a station counts towards each.
+type ZoneMetrics struct {
+	Zones []Zone
+
+	zone_stations         prometheus.GaugeVec
+	zone_capacity         prometheus.GaugeVec
+	zone_bikes_available  prometheus.GaugeVec
+	zone_ebikes_available prometheus.GaugeVec
+	zone_docks_available  prometheus.GaugeVec
+	zone_free_bikes       prometheus.GaugeVec
+}
+
+func NewZoneMetrics(reg prometheus.Registerer) *ZoneMetrics {
+	m := &ZoneMetrics{
+		zone_stations: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "zone_stations",
+			Help: "Number of stations within the zone",
+		},
+			[]string{"zone"},
+		),
+		zone_capacity: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "zone_capacity",
+			Help: "Total bike capacity of the stations within the zone",
+		},
+			[]string{"zone"},
+		),
+		zone_bikes_available: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "zone_bikes_available",
+			Help: "Number of bikes available at the stations within the zone",
+		},
+			[]string{"zone"},
+		),
+		zone_ebikes_available: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "zone_ebikes_available",
+			Help: "Number of ebikes available at the stations within the zone",
+		},
+			[]string{"zone"},
+		),
+		zone_docks_available: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "zone_docks_available",
+			Help: "Number of docks available at the stations within the zone",
+		},
+			[]string{"zone"},
+		),
+		zone_free_bikes: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "zone_free_bikes_available",
+			Help: "Number of free bikes within the zone that are neither disabled nor reserved",
+		},
+			[]string{"zone"},
+		),
+	}
+	reg.MustRegister(m.zone_stations)
+	reg.MustRegister(m.zone_capacity)
+	reg.MustRegister(m.zone_bikes_available)
+	reg.MustRegister(m.zone_ebikes_available)
+	reg.MustRegister(m.zone_docks_available)
+	reg.MustRegister(m.zone_free_bikes)
+
+	return m
+}
+
+func (m *ZoneMetrics) Observe(snapshot *Snapshot) {
+	for i := range m.Zones {
+		zone := &m.Zones[i]
+		var stations, capacity, bikes, ebikes, docks, free int
+		for _, station := range snapshot.Stations {
+			// stations only known from station_status have no location
+			if station.Lat == 0 && station.Lon == 0 || !zone.Contains(station.Lat, station.Lon) {
+				continue
+			}
+			stations++
+			capacity += station.Capacity
+			if station.Status != nil {
+				bikes += station.Status.BikesAvailable
+				ebikes += station.Status.EBikesAvailable
+				docks += station.Status.DocksAvailable
+			}
+		}
+		for _, bike := range snapshot.Bikes {
+			if bike.IsDisabled == 0 && bike.IsReserved == 0 && zone.Contains(bike.Lat, bike.Lon) {
+				free++
+			}
+		}
+
+		labels := prometheus.Labels{"zone": zone.Name}
+		m.zone_stations.With(labels).Set(float64(stations))
+		m.zone_capacity.With(labels).Set(float64(capacity))
+		m.zone_bikes_available.With(labels).Set(float64(bikes))
+		m.zone_ebikes_available.With(labels).Set(float64(ebikes))
+		m.zone_docks_available.With(labels).Set(float64(docks))
+		m.zone_free_bikes.With(labels).Set(float64(free))
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestRingContains(t *testing.T) {
+	// positions are longitude, latitude and rings closed like GeoJSON
+	square := [][2]float64{{-122.5, 37.7}, {-122.4, 37.7}, {-122.4, 37.8}, {-122.5, 37.8}, {-122.5, 37.7}}
+	// an L with the top right quarter cut out
+	l := [][2]float64{{0, 0}, {2, 0}, {2, 1}, {1, 1}, {1, 2}, {0, 2}, {0, 0}}
+	// the same L counterclockwise and left open
+	open := [][2]float64{{0, 0}, {0, 2}, {1, 2}, {1, 1}, {2, 1}, {2, 0}}
+
+	tests := []struct {
+		name     string
+		ring     [][2]float64
+		lat, lon float64
+		want     bool
+	}{
+		{"square center", square, 37.75, -122.45, true},
+		{"square west", square, 37.75, -122.55, false},
+		{"square east", square, 37.75, -122.35, false},
+		{"square north", square, 37.85, -122.45, false},
+		{"square south", square, 37.65, -122.45, false},
+		{"swapped coordinates", square, -122.45, 37.75, false},
+		{"l bottom right", l, 0.5, 1.5, true},
+		{"l top left", l, 1.5, 0.5, true},
+		{"l notch", l, 1.5, 1.5, false},
+		{"l ray through a vertex", l, 1, 0.5, true},
+		{"l ray through a vertex outside", l, 1, -0.5, false},
+		{"open ring", open, 0.5, 1.5, true},
+		{"open ring notch", open, 1.5, 1.5, false},
+		{"empty ring", nil, 0.5, 0.5, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ringContains(tt.ring, tt.lat, tt.lon); got != tt.want {
+				t.Errorf("ringContains(%v, %v, %v) = %v, want %v", tt.ring, tt.lat, tt.lon, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestZoneContains(t *testing.T) {
+	outline := [][2]float64{{0, 0}, {4, 0}, {4, 4}, {0, 4}, {0, 0}}
+	hole := [][2]float64{{1, 1}, {3, 1}, {3, 3}, {1, 3}, {1, 1}}
+	island := [][2]float64{{10, 10}, {11, 10}, {11, 11}, {10, 11}, {10, 10}}
+	zone := Zone{Name: "test", Polygons: [][][][2]float64{{outline, hole}, {island}}}
+
+	tests := []struct {
+		name     string
+		lat, lon float64
+		want     bool
+	}{
+		{"outline", 0.5, 0.5, true},
+		{"hole", 2, 2, false},
+		{"second polygon", 10.5, 10.5, true},
+		{"between polygons", 7, 7, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := zone.Contains(tt.lat, tt.lon); got != tt.want {
+				t.Errorf("Contains(%v, %v) = %v, want %v", tt.lat, tt.lon, got, tt.want)
+			}
+		})
+	}
+}